@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/internal/bench"
+	"github.com/steam-os-monitor/monitor/internal/config"
+)
+
+// runBench implements `monitor bench`: wait for a trigger, capture all
+// collectors at a high rate for a fixed duration and write a report file
+func runBench(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	configPath := flags.String("config", getDefaultConfigPath(), "Path to configuration file")
+	duration := flags.Duration("duration", 60*time.Second, "Length of the capture")
+	interval := flags.Duration("interval", 100*time.Millisecond, "Time between samples")
+	trigger := flags.String("trigger", bench.TriggerGame, "What starts the capture: game, hotkey or delay")
+	delay := flags.Duration("delay", 5*time.Second, "Wait before capturing with -trigger=delay")
+	label := flags.String("label", "", "Description stored in the report, e.g. the SteamOS or Proton version")
+	output := flags.String("o", "", "Report file (default <log_dir>/bench/bench-<time>.json)")
+	flags.Parse(args)
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := bench.NewRunner().Run(ctx, bench.Options{
+		Duration: *duration,
+		Interval: *interval,
+		Trigger:  *trigger,
+		Delay:    *delay,
+		Label:    *label,
+	})
+	if err != nil {
+		return err
+	}
+
+	reportPath := *output
+	if reportPath == "" {
+		name := fmt.Sprintf("bench-%s.json", report.StartTime.Format("20060102-150405"))
+		reportPath = filepath.Join(cfg.LogDir, "bench", name)
+	}
+	if err := bench.WriteReport(reportPath, report); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	bench.PrintSummary(os.Stdout, report)
+	fmt.Printf("\nReport written to %s\n", reportPath)
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/steam-os-monitor/monitor/internal/bench"
+)
+
+// runCompare implements `monitor compare runA runB`: report the differences
+// between two benchmark reports as text or JSON
+func runCompare(args []string) error {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Write the comparison as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: monitor compare [-json] runA.json runB.json")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return fmt.Errorf("compare needs exactly two benchmark reports")
+	}
+
+	runA, err := bench.LoadReport(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	runB, err := bench.LoadReport(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	comparison := bench.Compare(runA, runB)
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(comparison)
+	}
+
+	bench.PrintComparison(os.Stdout, comparison)
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/steam-os-monitor/monitor/internal/config"
 	"github.com/steam-os-monitor/monitor/internal/logger"
@@ -12,6 +13,24 @@ import (
 )
 
 func main() {
+	// Dispatch subcommands before parsing the GUI flags
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "bench":
+			runCommand(runBench, os.Args[2:])
+			return
+		case "compare":
+			runCommand(runCompare, os.Args[2:])
+			return
+		case "storage":
+			runCommand(runStorage, os.Args[2:])
+			return
+		case "query":
+			runCommand(runQuery, os.Args[2:])
+			return
+		}
+	}
+
 	// Parse command line flags
 	configPath := flag.String("config", getDefaultConfigPath(), "Path to configuration file")
 	flag.Parse()
@@ -23,8 +42,18 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Open the metric history, which outlives the logger writing to it
+	history, err := openHistory(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening history: %v\n", err)
+		os.Exit(1)
+	}
+	if history != nil {
+		defer history.Close()
+	}
+
 	// Initialize logger
-	log, err := logger.NewLogger(cfg.LogDir, cfg.LogFormat)
+	log, err := newLogger(cfg, history)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error initializing logger: %v\n", err)
 		os.Exit(1)
@@ -32,7 +61,7 @@ func main() {
 	defer log.Close()
 
 	// Create and show window
-	window, err := ui.NewWindow(cfg, log)
+	window, err := ui.NewWindow(cfg, log, history)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating window: %v\n", err)
 		os.Exit(1)
@@ -42,6 +71,23 @@ func main() {
 	window.ShowAndRun()
 }
 
+// rotationPolicy converts a configured rotation policy to the logger's
+func rotationPolicy(p config.RotationPolicy) logger.RotationPolicy {
+	limit := func(value int, unit int64) int64 {
+		if value < 0 {
+			return 0
+		}
+		return int64(value) * unit
+	}
+	return logger.RotationPolicy{
+		MaxSize:      limit(p.MaxSizeMB, 1024*1024),
+		MaxAge:       time.Duration(limit(p.MaxAgeHours, int64(time.Hour))),
+		Compression:  p.Compression,
+		Retention:    time.Duration(limit(p.RetentionDays, int64(24*time.Hour))),
+		MaxTotalSize: limit(p.MaxTotalMB, 1024*1024),
+	}
+}
+
 func getDefaultConfigPath() string {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -50,3 +96,10 @@ func getDefaultConfigPath() string {
 	return filepath.Join(homeDir, ".steam-os-monitor", "config.yaml")
 }
 
+// runCommand runs a subcommand and exits with a non-zero status on failure
+func runCommand(command func(args []string) error, args []string) {
+	if err := command(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/internal/config"
+	"github.com/steam-os-monitor/monitor/internal/tsdb"
+)
+
+// labelFlags collects repeated -label key=value flags
+type labelFlags tsdb.Labels
+
+func (l labelFlags) String() string {
+	var pairs []string
+	for k, v := range l {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (l labelFlags) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("label must be key=value, got %q", value)
+	}
+	l[k] = v
+	return nil
+}
+
+// querySeries is the JSON form of a series in `monitor query -json`
+type querySeries struct {
+	Name       string        `json:"name"`
+	Labels     tsdb.Labels   `json:"labels,omitempty"`
+	Resolution time.Duration `json:"resolution_ns,omitempty"`
+	Points     []queryPoint  `json:"points,omitempty"`
+}
+
+// queryPoint is a point of a querySeries. Min and max are only given for
+// rollups, where value is the average.
+type queryPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+	Min   *float64  `json:"min,omitempty"`
+	Max   *float64  `json:"max,omitempty"`
+}
+
+// runQuery implements `monitor query`: print the recorded values of the
+// series matching a name pattern, e.g. `monitor query -from 2h 'cpu.*'`.
+// The store is opened read-only, so the monitor can keep running.
+func runQuery(args []string) error {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	configPath := flags.String("config", getDefaultConfigPath(), "Path to configuration file")
+	dir := flags.String("dir", "", "History directory (default: log_dir/tsdb from the config)")
+	from := flags.String("from", "1h", "Start of the range, as a duration ago (e.g. 30m) or RFC 3339 time")
+	to := flags.String("to", "now", "End of the range, as a duration ago or RFC 3339 time")
+	resolution := flags.String("resolution", "auto", "Resolution: raw, a rollup such as 1m or 1h, or auto to fit the range")
+	list := flags.Bool("list", false, "List the matching series without their values")
+	asJSON := flags.Bool("json", false, "Write the result as JSON")
+	labels := labelFlags{}
+	flags.Var(labels, "label", "Only series with this label, as key=value (repeatable)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: monitor query [flags] [series pattern]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return fmt.Errorf("expected at most one series pattern")
+	}
+
+	if *dir == "" {
+		cfg, err := config.LoadConfig(*configPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		*dir = historyDir(cfg)
+	}
+	if _, err := os.Stat(*dir); err != nil {
+		return fmt.Errorf("no history in %s: %w", *dir, err)
+	}
+
+	now := time.Now()
+	start, err := parseQueryTime(*from, now)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+	end, err := parseQueryTime(*to, now)
+	if err != nil {
+		return fmt.Errorf("invalid -to: %w", err)
+	}
+
+	db, err := tsdb.Open(*dir, tsdb.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	matcher := tsdb.Matcher{Name: flags.Arg(0), Labels: tsdb.Labels(labels)}
+	var series []tsdb.Series
+	switch {
+	case *list:
+		series = db.ListSeries(matcher)
+	case *resolution == "auto":
+		series, err = db.Query(matcher, start, end)
+	case *resolution == "raw":
+		series, err = db.QueryResolution(matcher, start, end, 0)
+	default:
+		var d time.Duration
+		if d, err = time.ParseDuration(*resolution); err != nil {
+			return fmt.Errorf("invalid -resolution: %w", err)
+		}
+		series, err = db.QueryResolution(matcher, start, end, d)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		result := make([]querySeries, 0, len(series))
+		for _, s := range series {
+			qs := querySeries{Name: s.Name, Labels: s.Labels, Resolution: s.Resolution}
+			for _, p := range s.Points {
+				qp := queryPoint{Time: p.Time, Value: p.Value}
+				if s.Resolution > 0 {
+					min, max := p.Min, p.Max
+					qp.Min, qp.Max = &min, &max
+				}
+				qs.Points = append(qs.Points, qp)
+			}
+			result = append(result, qs)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+
+	printSeries(series, *list)
+	return nil
+}
+
+// printSeries writes each series' key followed by its points, with the
+// min and max of rollups
+func printSeries(series []tsdb.Series, keysOnly bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, s := range series {
+		if keysOnly {
+			fmt.Fprintln(w, s.Key())
+			continue
+		}
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if s.Resolution > 0 {
+			fmt.Fprintf(w, "%s (%s avg/min/max)\n", s.Key(), s.Resolution)
+		} else {
+			fmt.Fprintln(w, s.Key())
+		}
+		for _, p := range s.Points {
+			if s.Resolution > 0 {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", p.Time.Format(time.RFC3339),
+					formatValue(p.Value), formatValue(p.Min), formatValue(p.Max))
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t\n", p.Time.Format(time.RFC3339), formatValue(p.Value))
+		}
+	}
+	w.Flush()
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// parseQueryTime reads "now", a duration before now or an RFC 3339 time
+func parseQueryTime(value string, now time.Time) (time.Time, error) {
+	if value == "now" {
+		return now, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/internal/collector"
+	"github.com/steam-os-monitor/monitor/internal/config"
+	"github.com/steam-os-monitor/monitor/internal/exporter"
+	"github.com/steam-os-monitor/monitor/internal/logger"
+	"github.com/steam-os-monitor/monitor/internal/tsdb"
+)
+
+// historyDir returns where the time-series store is kept
+func historyDir(cfg *config.Config) string {
+	return filepath.Join(cfg.LogDir, "tsdb")
+}
+
+// openHistory opens the time-series store for writing, or returns nil if
+// it is disabled
+func openHistory(cfg *config.Config) (*tsdb.DB, error) {
+	if !cfg.History.Enabled {
+		return nil, nil
+	}
+	options := tsdb.Options{Retention: retentionDays(cfg.History.RetentionDays)}
+	for _, rollup := range cfg.History.Rollups {
+		options.Rollups = append(options.Rollups, tsdb.Rollup{
+			Resolution: time.Duration(rollup.ResolutionSeconds) * time.Second,
+			Retention:  retentionDays(rollup.RetentionDays),
+		})
+	}
+	db, err := tsdb.Open(historyDir(cfg), options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	return db, nil
+}
+
+// retentionDays converts a retention in days, where 0 or less keeps
+// everything
+func retentionDays(days int) time.Duration {
+	if days <= 0 {
+		return 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// newLogger creates the logger with the configured sinks, or a single file
+// sink in the log directory if none are configured. With a history store,
+// metrics are also written to it, and the enabled exporters get them too.
+func newLogger(cfg *config.Config, history *tsdb.DB) (*logger.Logger, error) {
+	sinkConfigs := cfg.Sinks
+	if len(sinkConfigs) == 0 {
+		sinkConfigs = []config.Sink{{Type: "file", Format: cfg.LogFormat, Dir: cfg.LogDir}}
+	}
+
+	var sinks []logger.Sink
+	closeAll := func() {
+		for _, sink := range sinks {
+			sink.Close()
+		}
+	}
+	fileDirs := make(map[string]bool)
+	for _, sc := range sinkConfigs {
+		for _, metricType := range sc.Metrics {
+			if !logger.ValidMetricType(metricType) {
+				closeAll()
+				return nil, fmt.Errorf("unknown metric type %q in %s sink", metricType, sc.Type)
+			}
+		}
+
+		sink, err := newSink(cfg, sc, fileDirs)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		// Write from a separate goroutine so slow storage doesn't hold up
+		// collection
+		sink = logger.NewAsyncSink(sink, logger.AsyncOptions{
+			QueueSize:     sc.QueueSize,
+			FlushInterval: time.Duration(sc.FlushIntervalMs) * time.Millisecond,
+			Overflow:      sc.Overflow,
+		})
+		if len(sc.Metrics) > 0 {
+			sink = logger.NewFilterSink(sink, sc.Metrics...)
+		}
+		sinks = append(sinks, sink)
+	}
+	if history != nil {
+		sinks = append(sinks, logger.NewAsyncSink(logger.NewTSDBSink(history), logger.AsyncOptions{}))
+	}
+	if prom := cfg.Exporter.Prometheus; prom.Enabled {
+		sink, err := exporter.NewPrometheus(prom.Listen, prom.Path)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if otlp := cfg.Exporter.OTLP; otlp.Enabled {
+		resource := exporter.SystemResource(collector.ReadSystemInfo())
+		for key, value := range otlp.ResourceAttributes {
+			resource[key] = value
+		}
+		sink, err := exporter.NewOTLP(exporter.OTLPOptions{
+			Endpoint:    otlp.Endpoint,
+			Encoding:    otlp.Encoding,
+			Compression: otlp.Compression,
+			Headers:     otlp.Headers,
+			Resource:    resource,
+			Interval:    time.Duration(otlp.IntervalSeconds) * time.Second,
+			Timeout:     time.Duration(otlp.TimeoutSeconds) * time.Second,
+			BufferDir:   otlp.BufferDir,
+			BufferSize:  int64(otlp.BufferMaxMB) * 1024 * 1024,
+		})
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	return logger.NewLoggerWithSinks(sinks...), nil
+}
+
+// newSink creates one configured sink. File sinks get the configured log
+// rotation; two of them can't share a directory.
+func newSink(cfg *config.Config, sc config.Sink, fileDirs map[string]bool) (logger.Sink, error) {
+	switch sc.Type {
+	case "stdout":
+		return logger.NewStreamSink(os.Stdout, sc.Format), nil
+	case "http":
+		return logger.NewInfluxHTTPSink(sc.Endpoint, sc.Headers), nil
+	case "udp":
+		return logger.NewInfluxUDPSink(sc.Endpoint)
+	case "file":
+		dir := filepath.Clean(sc.Dir)
+		if fileDirs[dir] {
+			return nil, fmt.Errorf("more than one file sink writes to %s", dir)
+		}
+		fileDirs[dir] = true
+
+		sink, err := logger.NewFileSink(dir, sc.Format)
+		if err != nil {
+			return nil, err
+		}
+		for _, metricType := range logger.MetricTypes {
+			if err := sink.SetRotation(metricType, rotationPolicy(cfg.LogRotation.For(metricType))); err != nil {
+				sink.Close()
+				return nil, fmt.Errorf("failed to configure log rotation: %w", err)
+			}
+		}
+		return sink, nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", sc.Type)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/steam-os-monitor/monitor/internal/collector"
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// storageReport is the JSON form of `monitor storage`
+type storageReport struct {
+	Libraries     []metrics.SteamLibraryStats `json:"libraries"`
+	Storage       []metrics.AppStorage        `json:"storage"`
+	Orphaned      []metrics.OrphanedStorage   `json:"orphaned"`
+	OrphanedBytes uint64                      `json:"orphaned_bytes"`
+	Unreachable   []string                    `json:"unreachable_libraries,omitempty"`
+}
+
+// runStorage implements `monitor storage`: report per-game disk usage and
+// the Proton prefixes and shader caches left behind by uninstalled apps.
+// Nothing is deleted.
+func runStorage(args []string) error {
+	flags := flag.NewFlagSet("storage", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Write the report as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: monitor storage [-json]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	// The first collection schedules the directory scans, the second one
+	// picks up their results
+	steam := collector.NewSteamCollector()
+	if _, err := steam.Collect(); err != nil {
+		return err
+	}
+	steam.WaitForSizes()
+	stats, err := steam.Collect()
+	if err != nil {
+		return err
+	}
+	inventory, _ := steam.Inventory()
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(storageReport{
+			Libraries:     stats.Libraries,
+			Storage:       inventory.Storage,
+			Orphaned:      inventory.Orphaned,
+			OrphanedBytes: stats.OrphanedBytes,
+			Unreachable:   inventory.Unreachable,
+		})
+	}
+
+	printStorage(stats, inventory)
+	return nil
+}
+
+// printStorage writes the storage breakdown and leftovers as tables
+func printStorage(stats *metrics.SteamStats, inventory *metrics.SteamInventory) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintln(w, "App\tName\tTotal GB\tInstall GB\tShaders GB\tPrefix GB\tWorkshop GB\t")
+	for _, app := range inventory.Storage {
+		fmt.Fprintf(w, "%s\t%s\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t\n",
+			app.AppID, app.Name, gigabytes(app.TotalBytes), gigabytes(app.InstallBytes),
+			gigabytes(app.ShaderCacheBytes), gigabytes(app.CompatDataBytes), gigabytes(app.WorkshopBytes))
+	}
+	w.Flush()
+
+	if len(inventory.Unreachable) > 0 {
+		fmt.Println("\nLeftovers not checked, these libraries couldn't be read:")
+		for _, library := range inventory.Unreachable {
+			fmt.Println("  " + library)
+		}
+		return
+	}
+	if len(inventory.Orphaned) == 0 {
+		fmt.Println("\nNo leftover prefixes or shader caches found")
+		return
+	}
+
+	fmt.Printf("\nLeftovers of uninstalled apps: %.2f GB in %d directories\n",
+		gigabytes(stats.OrphanedBytes), len(inventory.Orphaned))
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Kind\tApp\tSize GB\tPath")
+	for _, orphan := range inventory.Orphaned {
+		fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\n", orphan.Kind, orphan.AppID, gigabytes(orphan.Bytes), orphan.Path)
+	}
+	w.Flush()
+}
+
+func gigabytes(bytes uint64) float64 {
+	return float64(bytes) / (1024 * 1024 * 1024)
+}
@@ -1,9 +1,10 @@
 module github.com/steam-os-monitor/monitor
 
-go 1.21
+go 1.22
 
 require (
 	fyne.io/fyne/v2 v2.4.5
+	github.com/klauspost/compress v1.18.0
 	github.com/shirou/gopsutil/v3 v3.23.12
 	github.com/sirupsen/logrus v1.9.3
 	gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,271 @@
+package bench
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/internal/collector"
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// Triggers that start a benchmark capture
+const (
+	TriggerGame   = "game"   // start once a running game is detected
+	TriggerHotkey = "hotkey" // start when Enter is pressed
+	TriggerDelay  = "delay"  // start after a fixed delay
+)
+
+// Options configures a benchmark run
+type Options struct {
+	Duration time.Duration // length of the capture
+	Interval time.Duration // time between samples
+	Trigger  string        // one of the Trigger constants
+	Delay    time.Duration // wait before capturing with TriggerDelay
+	Label    string        // free-form description stored in the report
+	Input    io.Reader     // read for TriggerHotkey, defaults to stdin
+	Output   io.Writer     // progress messages, defaults to stdout
+}
+
+// steamInterval is the least time between Steam samples. Collecting Steam
+// metrics reads the library folders, which is too slow for every sample;
+// samples in between repeat the last rates.
+const steamInterval = time.Second
+
+// Runner captures benchmark runs from the system collectors
+type Runner struct {
+	cpuCollector     *collector.CPUCollector
+	memoryCollector  *collector.MemoryCollector
+	diskCollector    *collector.DiskCollector
+	networkCollector *collector.NetworkCollector
+	gameCollector    *collector.GameCollector
+	steamCollector   *collector.SteamCollector
+	sensorCollector  *collector.SensorCollector
+
+	lastDiskIO   map[string][2]uint64 // read and written bytes by device
+	lastDiskTime time.Time
+	lastSteam    *metrics.SteamStats
+}
+
+// NewRunner creates a new benchmark runner
+func NewRunner() *Runner {
+	return &Runner{
+		// A zero interval keeps CPU sampling from blocking the capture loop
+		cpuCollector:     collector.NewCPUCollectorWithInterval(0),
+		memoryCollector:  collector.NewMemoryCollector(),
+		diskCollector:    collector.NewDiskCollector(),
+		networkCollector: collector.NewNetworkCollector(),
+		gameCollector:    collector.NewGameCollector(),
+		steamCollector:   collector.NewSteamCollector(),
+		sensorCollector:  collector.NewSensorCollector(),
+	}
+}
+
+// Run waits for the trigger and then records for the configured duration
+func (r *Runner) Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.Duration <= 0 {
+		return nil, fmt.Errorf("benchmark duration must be positive")
+	}
+	if opts.Interval <= 0 {
+		return nil, fmt.Errorf("benchmark interval must be positive")
+	}
+	if opts.Input == nil {
+		opts.Input = os.Stdin
+	}
+	if opts.Output == nil {
+		opts.Output = os.Stdout
+	}
+
+	if err := r.waitForTrigger(ctx, opts); err != nil {
+		return nil, err
+	}
+
+	return r.record(ctx, opts)
+}
+
+// waitForTrigger blocks until the configured trigger fires
+func (r *Runner) waitForTrigger(ctx context.Context, opts Options) error {
+	switch opts.Trigger {
+	case TriggerDelay:
+		fmt.Fprintf(opts.Output, "Starting benchmark in %s...\n", opts.Delay)
+		select {
+		case <-time.After(opts.Delay):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+	case TriggerHotkey:
+		fmt.Fprintln(opts.Output, "Press Enter to start the benchmark...")
+		pressed := make(chan error, 1)
+		go func() {
+			_, err := bufio.NewReader(opts.Input).ReadString('\n')
+			pressed <- err
+		}()
+		select {
+		case err := <-pressed:
+			if err != nil && err != io.EOF {
+				return fmt.Errorf("failed to read hotkey: %w", err)
+			}
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+	case TriggerGame:
+		fmt.Fprintln(opts.Output, "Waiting for a game to start...")
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			if stats, err := r.gameCollector.Collect(); err == nil && stats.AppID != "" {
+				fmt.Fprintf(opts.Output, "Detected %s\n", stats.GameName)
+				return nil
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+	default:
+		return fmt.Errorf("unknown benchmark trigger %q", opts.Trigger)
+	}
+}
+
+// record samples all collectors every interval for the configured duration
+func (r *Runner) record(ctx context.Context, opts Options) (*Report, error) {
+	host, _ := os.Hostname()
+	report := &Report{
+		Version:   ReportVersion,
+		Label:     opts.Label,
+		Host:      host,
+		Trigger:   opts.Trigger,
+		StartTime: time.Now(),
+		Interval:  float64(opts.Interval) / float64(time.Millisecond),
+	}
+
+	fmt.Fprintf(opts.Output, "Recording for %s...\n", opts.Duration)
+
+	// Prime the collectors of rates so the first sample covers one interval
+	r.cpuCollector.Collect()
+	r.networkCollector.Collect()
+	r.diskRates()
+	r.gameCollector.Collect()
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	deadline := time.After(opts.Duration)
+
+	for done := false; !done; {
+		select {
+		case <-ticker.C:
+			report.Samples = append(report.Samples, r.sample(report))
+		case <-deadline:
+			done = true
+		case <-ctx.Done():
+			// An interrupted run still produces a report of what was captured
+			done = true
+		}
+	}
+
+	report.Duration = time.Since(report.StartTime).Seconds()
+	report.computeStatistics()
+
+	return report, nil
+}
+
+// sample captures one round of metrics
+func (r *Runner) sample(report *Report) Sample {
+	now := time.Now()
+	s := Sample{
+		Offset: now.Sub(report.StartTime).Seconds(),
+	}
+
+	if cpuStats, err := r.cpuCollector.Collect(); err == nil {
+		s.CPUPercent = cpuStats.OverallPercent
+	}
+	if memStats, err := r.memoryCollector.Collect(); err == nil {
+		s.MemoryPercent = memStats.UsedPercent
+	}
+	s.DiskReadRate, s.DiskWriteRate = r.diskRates()
+	if netStats, err := r.networkCollector.Collect(); err == nil {
+		for _, n := range netStats {
+			s.NetworkRecvRate += n.SpeedRecv
+			s.NetworkSendRate += n.SpeedSent
+		}
+	}
+	if gameStats, err := r.gameCollector.Collect(); err == nil {
+		s.FPS = gameStats.FPS
+		s.FrameTime = gameStats.FrameTime
+		s.FrameTimes = r.gameCollector.FrameTimes()
+		r.noteGame(report, gameStats)
+	}
+	if r.lastSteam == nil || now.Sub(r.lastSteam.Timestamp) >= steamInterval {
+		if steamStats, err := r.steamCollector.Collect(); err == nil {
+			r.lastSteam = steamStats
+		}
+	}
+	if r.lastSteam != nil {
+		s.SteamDownloadRate = r.lastSteam.DownloadSpeed
+		s.SteamDiskWriteRate = r.lastSteam.DiskWriteSpeed
+	}
+	if sensorStats, err := r.sensorCollector.Collect(); err == nil {
+		s.CPUTemp = sensorStats.CPUTemp
+		s.GPUTemp = sensorStats.GPUTemp
+		s.CPUClock = sensorStats.CPUClock
+		s.GPUClock = sensorStats.GPUClock
+		s.APUPower = sensorStats.APUPower
+		if sensorStats.HasBattery {
+			s.BatteryPercent = sensorStats.BatteryPercent
+		}
+	}
+
+	return s
+}
+
+// diskRates returns the bytes read and written per second on all disks
+// since the last call
+func (r *Runner) diskRates() (float64, float64) {
+	diskStats, err := r.diskCollector.Collect()
+	if err != nil {
+		return 0, 0
+	}
+	now := time.Now()
+	current := make(map[string][2]uint64)
+	for _, d := range diskStats {
+		// A device mounted in several places is counted once
+		current[d.Device] = [2]uint64{d.ReadBytes, d.WriteBytes}
+	}
+
+	var read, written float64
+	if elapsed := now.Sub(r.lastDiskTime).Seconds(); r.lastDiskIO != nil && elapsed > 0 {
+		for device, counters := range current {
+			last, ok := r.lastDiskIO[device]
+			if !ok || counters[0] < last[0] || counters[1] < last[1] {
+				continue
+			}
+			read += float64(counters[0]-last[0]) / elapsed
+			written += float64(counters[1]-last[1]) / elapsed
+		}
+	}
+	r.lastDiskIO, r.lastDiskTime = current, now
+	return read, written
+}
+
+// noteGame records the benchmarked game the first time one is seen
+func (r *Runner) noteGame(report *Report, stats *metrics.GamePerformanceStats) {
+	if report.Game.AppID != "" || stats.AppID == "" {
+		return
+	}
+	report.Game = GameInfo{
+		AppID:             stats.AppID,
+		Name:              stats.GameName,
+		Proton:            stats.Proton,
+		CompatTool:        stats.CompatTool,
+		CompatToolVersion: stats.CompatToolVersion,
+	}
+}
@@ -0,0 +1,324 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// tCritical95 are the two-sided 95% critical values of Student's t
+// distribution by degrees of freedom; beyond the last it is about 1.96
+var tCritical95 = []struct {
+	df float64
+	t  float64
+}{
+	{1, 12.706}, {2, 4.303}, {3, 3.182}, {4, 2.776}, {5, 2.571},
+	{6, 2.447}, {7, 2.365}, {8, 2.306}, {9, 2.262}, {10, 2.228},
+	{12, 2.179}, {15, 2.131}, {20, 2.086}, {25, 2.060}, {30, 2.042},
+	{40, 2.021}, {60, 2.000}, {120, 1.980},
+}
+
+// Comparison holds the differences between two benchmark runs
+type Comparison struct {
+	A               RunInfo       `json:"a"`
+	B               RunInfo       `json:"b"`
+	AlignedDuration float64       `json:"aligned_duration_s"`
+	Metrics         []MetricDelta `json:"metrics"`
+}
+
+// RunInfo identifies a compared run
+type RunInfo struct {
+	Label     string   `json:"label,omitempty"`
+	Game      GameInfo `json:"game"`
+	StartTime string   `json:"start_time"`
+	Duration  float64  `json:"duration_s"`
+	Samples   int      `json:"aligned_samples"`
+	// Lows and percentiles of runs with different frame time sources
+	// aren't comparable
+	FrameTimeSource string `json:"frame_time_source"`
+}
+
+// MetricDelta is the change of one metric from run A to run B. Significant
+// is nil for metrics without per-sample values to test against.
+type MetricDelta struct {
+	Name           string  `json:"name"`
+	Unit           string  `json:"unit"`
+	A              float64 `json:"a"`
+	B              float64 `json:"b"`
+	Delta          float64 `json:"delta"`
+	DeltaPercent   float64 `json:"delta_percent"`
+	HigherIsBetter bool    `json:"higher_is_better"`
+	T              float64 `json:"t,omitempty"`
+	Significant    *bool   `json:"significant,omitempty"`
+}
+
+// metricDef describes how to compare one metric between runs
+type metricDef struct {
+	name           string
+	unit           string
+	higherIsBetter bool
+	value          func(r *Report) float64
+	series         func(r *Report) []float64 // the values value averages; nil when not testable
+}
+
+var compareMetrics = []metricDef{
+	{"Average FPS", "fps", true, func(r *Report) float64 { return r.FPS.Avg }, sampleSeries(fpsSeries)},
+	{"1% low FPS", "fps", true, func(r *Report) float64 { return r.FPS.Low1Percent }, nil},
+	{"0.1% low FPS", "fps", true, func(r *Report) float64 { return r.FPS.Low01Percent }, nil},
+	{"Frame time mean", "ms", false, func(r *Report) float64 { return r.FrameTime.Mean }, frameTimeSeries},
+	{"Frame time p99", "ms", false, func(r *Report) float64 { return r.FrameTime.P99 }, nil},
+	{"Frame time variance", "ms²", false, func(r *Report) float64 { return r.FrameTime.StdDev * r.FrameTime.StdDev }, nil},
+	{"APU power", "W", false, func(r *Report) float64 { return r.APUPower.Avg }, sampleSeries(positiveSeries(func(s Sample) float64 { return s.APUPower }))},
+	{"CPU temp", "°C", false, func(r *Report) float64 { return r.CPUTemp.Avg }, sampleSeries(positiveSeries(func(s Sample) float64 { return s.CPUTemp }))},
+	{"GPU temp", "°C", false, func(r *Report) float64 { return r.GPUTemp.Avg }, sampleSeries(positiveSeries(func(s Sample) float64 { return s.GPUTemp }))},
+	{"Peak CPU temp", "°C", false, func(r *Report) float64 { return r.CPUTemp.Max }, nil},
+	{"Peak GPU temp", "°C", false, func(r *Report) float64 { return r.GPUTemp.Max }, nil},
+	{"CPU clock", "MHz", true, func(r *Report) float64 { return r.CPUClock.Avg }, sampleSeries(positiveSeries(func(s Sample) float64 { return s.CPUClock }))},
+	{"GPU clock", "MHz", true, func(r *Report) float64 { return r.GPUClock.Avg }, sampleSeries(positiveSeries(func(s Sample) float64 { return s.GPUClock }))},
+}
+
+func fpsSeries(s Sample) (float64, bool) {
+	return s.FPS, s.FrameTime > 0
+}
+
+// frameTimeSeries returns the frame times the report's statistics are
+// computed from: every frame's if known, the sampled ones otherwise
+func frameTimeSeries(r *Report) []float64 {
+	var frames []float64
+	for _, s := range r.Samples {
+		frames = append(frames, s.FrameTimes...)
+	}
+	if len(frames) > 0 {
+		return frames
+	}
+	return extract(r.Samples, func(s Sample) (float64, bool) { return s.FrameTime, s.FrameTime > 0 })
+}
+
+// sampleSeries returns the values of a per-sample field
+func sampleSeries(field func(s Sample) (float64, bool)) func(r *Report) []float64 {
+	return func(r *Report) []float64 {
+		return extract(r.Samples, field)
+	}
+}
+
+func positiveSeries(field func(s Sample) float64) func(s Sample) (float64, bool) {
+	return func(s Sample) (float64, bool) {
+		v := field(s)
+		return v, v > 0
+	}
+}
+
+// Compare aligns two runs to their common duration and computes deltas
+func Compare(a, b *Report) *Comparison {
+	aligned := math.Min(a.Duration, b.Duration)
+	alignedA := a.trimmed(aligned)
+	alignedB := b.trimmed(aligned)
+
+	c := &Comparison{
+		A:               runInfo(alignedA, a.Duration),
+		B:               runInfo(alignedB, b.Duration),
+		AlignedDuration: aligned,
+	}
+
+	for _, def := range compareMetrics {
+		delta := MetricDelta{
+			Name:           def.name,
+			Unit:           def.unit,
+			A:              def.value(alignedA),
+			B:              def.value(alignedB),
+			HigherIsBetter: def.higherIsBetter,
+		}
+		delta.Delta = delta.B - delta.A
+		if delta.A != 0 {
+			delta.DeltaPercent = delta.Delta / math.Abs(delta.A) * 100
+		}
+
+		if def.series != nil {
+			if t, critical, ok := welchT(def.series(alignedA), def.series(alignedB)); ok {
+				significant := math.Abs(t) > critical
+				delta.T = t
+				delta.Significant = &significant
+			}
+		}
+
+		c.Metrics = append(c.Metrics, delta)
+	}
+
+	return c
+}
+
+// trimmed returns a copy of the report restricted to the first duration
+// seconds with its statistics recomputed
+func (r *Report) trimmed(duration float64) *Report {
+	t := *r
+	t.Samples = nil
+	for _, s := range r.Samples {
+		if s.Offset <= duration {
+			t.Samples = append(t.Samples, s)
+		}
+	}
+	t.Duration = duration
+	t.computeStatistics()
+	return &t
+}
+
+func runInfo(r *Report, fullDuration float64) RunInfo {
+	return RunInfo{
+		Label:     r.Label,
+		Game:      r.Game,
+		StartTime: r.StartTime.Format("2006-01-02 15:04:05"),
+		Duration:  fullDuration,
+		Samples:   len(r.Samples),
+
+		FrameTimeSource: r.FrameTime.Source,
+	}
+}
+
+func extract(samples []Sample, series func(s Sample) (float64, bool)) []float64 {
+	var values []float64
+	for _, s := range samples {
+		if v, ok := series(s); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// welchT returns Welch's t statistic for the difference of two series'
+// means and the |t| beyond which it is significant at the 95% level.
+// Consecutive samples of a run are far from independent, e.g. a scene
+// stays demanding for many seconds, so each series counts with its
+// effective sample size rather than its length.
+func welchT(a, b []float64) (float64, float64, bool) {
+	if len(a) < 2 || len(b) < 2 {
+		return 0, 0, false
+	}
+
+	meanA, varA := meanVariance(a)
+	meanB, varB := meanVariance(b)
+	nA, nB := effectiveSize(a, meanA, varA), effectiveSize(b, meanB, varB)
+	if nA < 2 || nB < 2 {
+		return 0, 0, false
+	}
+	seA, seB := varA/nA, varB/nB
+	if seA+seB == 0 {
+		return 0, 0, false
+	}
+
+	// Welch–Satterthwaite degrees of freedom
+	df := (seA + seB) * (seA + seB) / (seA*seA/(nA-1) + seB*seB/(nB-1))
+	return (meanB - meanA) / math.Sqrt(seA+seB), tCritical(df), true
+}
+
+// effectiveSize returns how many independent values a series is worth,
+// n(1-ρ)/(1+ρ) for its lag-1 autocorrelation ρ
+func effectiveSize(values []float64, mean, variance float64) float64 {
+	n := float64(len(values))
+	if variance == 0 {
+		return n
+	}
+	var lagged float64
+	for i := 1; i < len(values); i++ {
+		lagged += (values[i] - mean) * (values[i-1] - mean)
+	}
+	rho := lagged / ((n - 1) * variance)
+	if rho <= 0 {
+		return n
+	}
+	return n * (1 - rho) / (1 + rho)
+}
+
+// tCritical returns the two-sided 95% critical value of t for df degrees
+// of freedom, rounding df down to the next tabulated value
+func tCritical(df float64) float64 {
+	if df > tCritical95[len(tCritical95)-1].df {
+		return 1.96
+	}
+	critical := tCritical95[0].t
+	for _, entry := range tCritical95 {
+		if df < entry.df {
+			break
+		}
+		critical = entry.t
+	}
+	return critical
+}
+
+// meanVariance returns the mean and unbiased sample variance of values
+func meanVariance(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, squares / float64(len(values)-1)
+}
+
+// PrintComparison writes a human-readable comparison table
+func PrintComparison(w io.Writer, c *Comparison) {
+	fmt.Fprintf(w, "A: %s  %s  (%.1f s, %s frame times)\n", c.A.StartTime, describeRun(c.A), c.A.Duration, c.A.FrameTimeSource)
+	fmt.Fprintf(w, "B: %s  %s  (%.1f s, %s frame times)\n", c.B.StartTime, describeRun(c.B), c.B.Duration, c.B.FrameTimeSource)
+	fmt.Fprintf(w, "Aligned to the first %.1f s (%d vs %d samples)\n", c.AlignedDuration, c.A.Samples, c.B.Samples)
+	if c.A.FrameTimeSource != c.B.FrameTimeSource {
+		fmt.Fprintln(w, "Frame times were measured differently, so lows and percentiles aren't comparable")
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "%-20s %12s %12s %12s %9s  %s\n", "Metric", "A", "B", "Delta", "Change", "Significance")
+	for _, m := range c.Metrics {
+		fmt.Fprintf(w, "%-20s %12s %12s %12s %8.1f%%  %s\n",
+			m.Name,
+			formatValue(m.A, m.Unit),
+			formatValue(m.B, m.Unit),
+			formatValue(m.Delta, m.Unit),
+			m.DeltaPercent,
+			describeSignificance(m))
+	}
+}
+
+func describeRun(r RunInfo) string {
+	desc := r.Game.Name
+	if desc == "" {
+		desc = "no game"
+	}
+	if r.Game.CompatTool != "" {
+		desc = fmt.Sprintf("%s on %s", desc, describeCompatTool(r.Game))
+	}
+	if r.Label != "" {
+		desc = fmt.Sprintf("%s [%s]", desc, r.Label)
+	}
+	return desc
+}
+
+// describeCompatTool names a game's compatibility tool with its version
+func describeCompatTool(game GameInfo) string {
+	if game.CompatToolVersion == "" || game.CompatToolVersion == game.CompatTool {
+		return game.CompatTool
+	}
+	return fmt.Sprintf("%s (%s)", game.CompatTool, game.CompatToolVersion)
+}
+
+func formatValue(v float64, unit string) string {
+	return fmt.Sprintf("%.2f %s", v, unit)
+}
+
+func describeSignificance(m MetricDelta) string {
+	if m.Significant == nil {
+		return "-"
+	}
+	if !*m.Significant {
+		return fmt.Sprintf("not significant (t=%.2f)", m.T)
+	}
+
+	better := (m.Delta > 0) == m.HigherIsBetter
+	verdict := "worse"
+	if better {
+		verdict = "better"
+	}
+	return fmt.Sprintf("significant, %s (t=%.2f)", verdict, m.T)
+}
@@ -0,0 +1,76 @@
+package bench
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// drifting returns n values that wander slowly around mean, like the
+// frame rate of a game moving through heavier and lighter scenes
+func drifting(seed int64, n int, mean float64) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	values := make([]float64, n)
+	x := 0.0
+	for i := range values {
+		x = 0.98*x + rng.NormFloat64()
+		values[i] = mean + x
+	}
+	return values
+}
+
+func TestWelchTAccountsForAutocorrelation(t *testing.T) {
+	a := drifting(1, 1200, 60)
+	b := drifting(2, 1200, 60)
+	meanA, _ := meanVariance(a)
+	meanB, _ := meanVariance(b)
+
+	tValue, critical, ok := welchT(a, b)
+	if ok && math.Abs(tValue) > critical {
+		t.Errorf("runs of equal mean (%.2f vs %.2f) reported as significant: t = %.2f, critical %.2f", meanA, meanB, tValue, critical)
+	}
+}
+
+func TestWelchTDetectsShift(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	a := make([]float64, 300)
+	b := make([]float64, 300)
+	for i := range a {
+		a[i] = 60 + rng.NormFloat64()
+		b[i] = 62 + rng.NormFloat64()
+	}
+
+	tValue, critical, ok := welchT(a, b)
+	if !ok || tValue < critical {
+		t.Errorf("welchT = %.2f, %.2f, %v, want significant increase", tValue, critical, ok)
+	}
+}
+
+func TestCompareFrameTimeTestsFrames(t *testing.T) {
+	run := func(frame float64) *Report {
+		r := &Report{Duration: 30}
+		for i := 0; i < 300; i++ {
+			// The sampled frame time is the same in both runs, only the
+			// frames MangoHud logged differ
+			frames := []float64{frame, frame + 1, frame - 1, frame}
+			r.Samples = append(r.Samples, Sample{Offset: float64(i) / 10, FPS: 60, FrameTime: 16.7, FrameTimes: frames})
+		}
+		r.computeStatistics()
+		return r
+	}
+
+	c := Compare(run(16), run(20))
+	for _, m := range c.Metrics {
+		if m.Name != "Frame time mean" {
+			continue
+		}
+		if m.A != 16 || m.B != 20 {
+			t.Errorf("frame time mean %v -> %v, want 16 -> 20", m.A, m.B)
+		}
+		if m.Significant == nil || !*m.Significant {
+			t.Errorf("frame time mean change not significant, t = %v", m.T)
+		}
+		return
+	}
+	t.Fatal("no frame time mean row")
+}
@@ -0,0 +1,343 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/internal/collector"
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// ReportVersion is the version of the benchmark report file format
+const ReportVersion = 1
+
+// histogramEdges are the frame time bucket boundaries in milliseconds,
+// chosen around common refresh rates (240, 120, 90, 60, 50, 40, 30, 20, 10 FPS)
+var histogramEdges = []float64{4.17, 8.33, 11.11, 16.67, 20, 25, 33.33, 50, 100}
+
+// Report is the self-contained result of a benchmark run
+type Report struct {
+	Version   int       `json:"version"`
+	Label     string    `json:"label,omitempty"`
+	Host      string    `json:"host"`
+	Trigger   string    `json:"trigger"`
+	StartTime time.Time `json:"start_time"`
+	Duration  float64   `json:"duration_s"`
+	Interval  float64   `json:"interval_ms"`
+	Game      GameInfo  `json:"game"`
+
+	FPS       FPSSummary       `json:"fps"`
+	FrameTime FrameTimeSummary `json:"frame_time"`
+	Histogram []Bucket         `json:"frame_time_histogram"`
+
+	CPUUsage     Summary `json:"cpu_usage_percent"`
+	MemoryUsage  Summary `json:"memory_usage_percent"`
+	CPUTemp      Summary `json:"cpu_temp_celsius"`
+	GPUTemp      Summary `json:"gpu_temp_celsius"`
+	CPUClock     Summary `json:"cpu_clock_mhz"`
+	GPUClock     Summary `json:"gpu_clock_mhz"`
+	APUPower     Summary `json:"apu_power_watts"`
+	BatteryDrain float64 `json:"battery_drain_percent"`
+
+	DiskRead       Summary `json:"disk_read_bytes_per_sec"`
+	DiskWrite      Summary `json:"disk_write_bytes_per_sec"`
+	NetworkRecv    Summary `json:"network_recv_bytes_per_sec"`
+	NetworkSend    Summary `json:"network_send_bytes_per_sec"`
+	SteamDownload  Summary `json:"steam_download_bytes_per_sec"`
+	SteamDiskWrite Summary `json:"steam_disk_write_bytes_per_sec"`
+
+	Samples []Sample `json:"samples"`
+}
+
+// GameInfo identifies the game that was benchmarked
+type GameInfo struct {
+	AppID             string `json:"app_id,omitempty"`
+	Name              string `json:"name,omitempty"`
+	Proton            bool   `json:"proton"`
+	CompatTool        string `json:"compat_tool,omitempty"`
+	CompatToolVersion string `json:"compat_tool_version,omitempty"`
+}
+
+// FPSSummary summarizes the frame rate over a run
+type FPSSummary struct {
+	Avg          float64 `json:"avg"`
+	Min          float64 `json:"min"`
+	Max          float64 `json:"max"`
+	Low1Percent  float64 `json:"low_1pct"`
+	Low01Percent float64 `json:"low_0_1pct"`
+}
+
+// FrameTimeSummary summarizes the frame times over a run in milliseconds.
+// Source is metrics.FrameTimeSourceMangoHud when they are those of the
+// individual frames, and metrics.FrameTimeSourceSampled when they are
+// derived from the FPS of each sample.
+type FrameTimeSummary struct {
+	Source  string  `json:"source"`
+	Samples int     `json:"samples"`
+	Min     float64 `json:"min_ms"`
+	Max     float64 `json:"max_ms"`
+	Mean    float64 `json:"mean_ms"`
+	Median  float64 `json:"median_ms"`
+	P95     float64 `json:"p95_ms"`
+	P99     float64 `json:"p99_ms"`
+	StdDev  float64 `json:"stddev_ms"`
+}
+
+// Bucket is one frame time histogram bucket. The last bucket has no upper bound.
+type Bucket struct {
+	LowerMs float64 `json:"lower_ms"`
+	UpperMs float64 `json:"upper_ms,omitempty"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+// Summary holds the minimum, average and maximum of a series
+type Summary struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+}
+
+// Sample is a single high-frequency capture during a run
+type Sample struct {
+	Offset         float64 `json:"offset_s"`
+	FPS            float64 `json:"fps"`
+	FrameTime      float64 `json:"frame_time_ms"`
+	CPUPercent     float64 `json:"cpu_percent"`
+	MemoryPercent  float64 `json:"memory_percent"`
+	CPUTemp        float64 `json:"cpu_temp_celsius"`
+	GPUTemp        float64 `json:"gpu_temp_celsius"`
+	CPUClock       float64 `json:"cpu_clock_mhz"`
+	GPUClock       float64 `json:"gpu_clock_mhz"`
+	APUPower       float64 `json:"apu_power_watts"`
+	BatteryPercent float64 `json:"battery_percent"`
+
+	DiskReadRate       float64 `json:"disk_read_bytes_per_sec"`
+	DiskWriteRate      float64 `json:"disk_write_bytes_per_sec"`
+	NetworkRecvRate    float64 `json:"network_recv_bytes_per_sec"`
+	NetworkSendRate    float64 `json:"network_send_bytes_per_sec"`
+	SteamDownloadRate  float64 `json:"steam_download_bytes_per_sec"`
+	SteamDiskWriteRate float64 `json:"steam_disk_write_bytes_per_sec"`
+
+	// FrameTimes are the frames rendered since the previous sample, when
+	// the game logs them to MangoHud
+	FrameTimes []float64 `json:"frame_times_ms,omitempty"`
+}
+
+// computeStatistics fills the report's summaries from its samples. The
+// frame time statistics and histogram are those of the individual frames
+// when the samples have them, and of the samples' frame times otherwise.
+func (r *Report) computeStatistics() {
+	var frameTimes, sampledFrameTimes, fps []float64
+	var cpuUsage, memUsage, cpuTemp, gpuTemp, cpuClock, gpuClock, power []float64
+	var diskRead, diskWrite, netRecv, netSend, steamDownload, steamDiskWrite []float64
+	var lastBattery float64
+	r.BatteryDrain = 0
+
+	for _, s := range r.Samples {
+		frameTimes = append(frameTimes, s.FrameTimes...)
+		if s.FrameTime > 0 {
+			sampledFrameTimes = append(sampledFrameTimes, s.FrameTime)
+			fps = append(fps, s.FPS)
+		}
+		diskRead = append(diskRead, s.DiskReadRate)
+		diskWrite = append(diskWrite, s.DiskWriteRate)
+		netRecv = append(netRecv, s.NetworkRecvRate)
+		netSend = append(netSend, s.NetworkSendRate)
+		steamDownload = append(steamDownload, s.SteamDownloadRate)
+		steamDiskWrite = append(steamDiskWrite, s.SteamDiskWriteRate)
+		cpuUsage = append(cpuUsage, s.CPUPercent)
+		memUsage = append(memUsage, s.MemoryPercent)
+		cpuTemp = appendPositive(cpuTemp, s.CPUTemp)
+		gpuTemp = appendPositive(gpuTemp, s.GPUTemp)
+		cpuClock = appendPositive(cpuClock, s.CPUClock)
+		gpuClock = appendPositive(gpuClock, s.GPUClock)
+		power = appendPositive(power, s.APUPower)
+
+		if s.BatteryPercent > 0 {
+			if lastBattery > s.BatteryPercent {
+				r.BatteryDrain += lastBattery - s.BatteryPercent
+			}
+			lastBattery = s.BatteryPercent
+		}
+	}
+
+	source := metrics.FrameTimeSourceMangoHud
+	if len(frameTimes) == 0 {
+		frameTimes = sampledFrameTimes
+		source = metrics.FrameTimeSourceSampled
+	}
+	ft := collector.ComputeFrameTimeStats(frameTimes)
+	r.FrameTime = FrameTimeSummary{
+		Source:  source,
+		Samples: ft.Samples,
+		Min:     ft.Min,
+		Max:     ft.Max,
+		Mean:    ft.Mean,
+		Median:  ft.Median,
+		P95:     ft.P95,
+		P99:     ft.P99,
+		StdDev:  ft.StdDev,
+	}
+
+	fpsSummary := summarize(fps)
+	r.FPS = FPSSummary{
+		Avg:          fpsSummary.Avg,
+		Min:          fpsSummary.Min,
+		Max:          fpsSummary.Max,
+		Low1Percent:  ft.Low1Percent,
+		Low01Percent: ft.Low01Percent,
+	}
+	r.Histogram = histogram(frameTimes)
+
+	r.CPUUsage = summarize(cpuUsage)
+	r.MemoryUsage = summarize(memUsage)
+	r.CPUTemp = summarize(cpuTemp)
+	r.GPUTemp = summarize(gpuTemp)
+	r.CPUClock = summarize(cpuClock)
+	r.GPUClock = summarize(gpuClock)
+	r.APUPower = summarize(power)
+	r.DiskRead = summarize(diskRead)
+	r.DiskWrite = summarize(diskWrite)
+	r.NetworkRecv = summarize(netRecv)
+	r.NetworkSend = summarize(netSend)
+	r.SteamDownload = summarize(steamDownload)
+	r.SteamDiskWrite = summarize(steamDiskWrite)
+}
+
+// appendPositive appends value only if the sensor produced a reading
+func appendPositive(values []float64, value float64) []float64 {
+	if value > 0 {
+		return append(values, value)
+	}
+	return values
+}
+
+// summarize returns the min, average and max of values
+func summarize(values []float64) Summary {
+	if len(values) == 0 {
+		return Summary{}
+	}
+
+	summary := Summary{Min: math.Inf(1), Max: math.Inf(-1)}
+	var sum float64
+	for _, v := range values {
+		sum += v
+		summary.Min = math.Min(summary.Min, v)
+		summary.Max = math.Max(summary.Max, v)
+	}
+	summary.Avg = sum / float64(len(values))
+	return summary
+}
+
+// histogram buckets frame times by histogramEdges
+func histogram(frameTimes []float64) []Bucket {
+	buckets := make([]Bucket, len(histogramEdges)+1)
+	lower := 0.0
+	for i, edge := range histogramEdges {
+		buckets[i] = Bucket{LowerMs: lower, UpperMs: edge}
+		lower = edge
+	}
+	buckets[len(histogramEdges)] = Bucket{LowerMs: lower}
+
+	for _, ft := range frameTimes {
+		i := 0
+		for i < len(histogramEdges) && ft >= histogramEdges[i] {
+			i++
+		}
+		buckets[i].Count++
+	}
+
+	if len(frameTimes) > 0 {
+		for i := range buckets {
+			buckets[i].Percent = float64(buckets[i].Count) * 100 / float64(len(frameTimes))
+		}
+	}
+	return buckets
+}
+
+// WriteReport writes a report as indented JSON, creating parent directories
+func WriteReport(path string, report *Report) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	return nil
+}
+
+// LoadReport reads a report written by WriteReport
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	if report.Version != ReportVersion {
+		return nil, fmt.Errorf("unsupported report version %d in %s", report.Version, path)
+	}
+
+	return &report, nil
+}
+
+// PrintSummary writes a human-readable summary of a report
+func PrintSummary(w io.Writer, r *Report) {
+	game := r.Game.Name
+	if game == "" {
+		game = "none detected"
+	}
+
+	fmt.Fprintf(w, "Benchmark: %s\n", r.StartTime.Format(time.RFC3339))
+	if r.Label != "" {
+		fmt.Fprintf(w, "Label:     %s\n", r.Label)
+	}
+	fmt.Fprintf(w, "Game:      %s\n", game)
+	if r.Game.CompatTool != "" {
+		fmt.Fprintf(w, "Runtime:   %s\n", describeCompatTool(r.Game))
+	}
+	fmt.Fprintf(w, "Duration:  %.1f s (%d samples every %.0f ms)\n", r.Duration, len(r.Samples), r.Interval)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "FPS:        avg %.1f  min %.1f  max %.1f  1%% low %.1f  0.1%% low %.1f\n",
+		r.FPS.Avg, r.FPS.Min, r.FPS.Max, r.FPS.Low1Percent, r.FPS.Low01Percent)
+	fmt.Fprintf(w, "Frame time: mean %.2f ms  median %.2f ms  p95 %.2f ms  p99 %.2f ms  stddev %.2f ms\n",
+		r.FrameTime.Mean, r.FrameTime.Median, r.FrameTime.P95, r.FrameTime.P99, r.FrameTime.StdDev)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Frame time histogram:")
+	for _, b := range r.Histogram {
+		label := fmt.Sprintf("%6.2f - %6.2f ms", b.LowerMs, b.UpperMs)
+		if b.UpperMs == 0 {
+			label = fmt.Sprintf("%6.2f ms and up  ", b.LowerMs)
+		}
+		fmt.Fprintf(w, "  %s  %5.1f%%  (%d)\n", label, b.Percent, b.Count)
+	}
+	fmt.Fprintln(w)
+	printSummaryLine(w, "CPU usage", r.CPUUsage, "%")
+	printSummaryLine(w, "Memory", r.MemoryUsage, "%")
+	printSummaryLine(w, "CPU temp", r.CPUTemp, "°C")
+	printSummaryLine(w, "GPU temp", r.GPUTemp, "°C")
+	printSummaryLine(w, "CPU clock", r.CPUClock, " MHz")
+	printSummaryLine(w, "GPU clock", r.GPUClock, " MHz")
+	printSummaryLine(w, "APU power", r.APUPower, " W")
+	fmt.Fprintf(w, "%-10s  %.1f%% drained\n", "Battery", r.BatteryDrain)
+}
+
+func printSummaryLine(w io.Writer, name string, s Summary, unit string) {
+	fmt.Fprintf(w, "%-10s  min %.1f%s  avg %.1f%s  max %.1f%s\n", name, s.Min, unit, s.Avg, unit, s.Max, unit)
+}
@@ -0,0 +1,51 @@
+package bench
+
+import (
+	"testing"
+
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+func TestComputeStatisticsFromFrames(t *testing.T) {
+	r := &Report{Samples: []Sample{
+		{FPS: 60, FrameTime: 16.67, FrameTimes: []float64{16, 17, 16}},
+		{FPS: 50, FrameTime: 20, FrameTimes: []float64{18, 40}},
+	}}
+	r.computeStatistics()
+
+	if r.FrameTime.Source != metrics.FrameTimeSourceMangoHud || r.FrameTime.Samples != 5 {
+		t.Errorf("frame time source %q of %d samples, want mangohud of 5", r.FrameTime.Source, r.FrameTime.Samples)
+	}
+	if r.FrameTime.Max != 40 {
+		t.Errorf("frame time max = %v, want 40", r.FrameTime.Max)
+	}
+	if r.FPS.Low1Percent != 25 {
+		t.Errorf("1%% low = %v, want 25", r.FPS.Low1Percent)
+	}
+	if r.FPS.Avg != 55 {
+		t.Errorf("average FPS = %v, want 55", r.FPS.Avg)
+	}
+
+	counts := 0
+	for _, b := range r.Histogram {
+		counts += b.Count
+	}
+	if counts != 5 {
+		t.Errorf("histogram holds %d frames, want 5", counts)
+	}
+}
+
+func TestComputeStatisticsFromSamples(t *testing.T) {
+	r := &Report{Samples: []Sample{
+		{FPS: 60, FrameTime: 16.67, DiskReadRate: 100, NetworkRecvRate: 10},
+		{FPS: 50, FrameTime: 20, DiskReadRate: 300, NetworkRecvRate: 30},
+	}}
+	r.computeStatistics()
+
+	if r.FrameTime.Source != metrics.FrameTimeSourceSampled || r.FrameTime.Samples != 2 {
+		t.Errorf("frame time source %q of %d samples, want sampled of 2", r.FrameTime.Source, r.FrameTime.Samples)
+	}
+	if r.DiskRead.Avg != 200 || r.NetworkRecv.Max != 30 {
+		t.Errorf("disk read %+v, network receive %+v", r.DiskRead, r.NetworkRecv)
+	}
+}
@@ -0,0 +1,188 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/internal/vdf"
+)
+
+// Steam app state flags stored in appmanifest StateFlags
+const (
+	appStateUpdateRequired = 1 << 1
+	appStateFullyInstalled = 1 << 2
+	appStateUpdateRunning  = 1 << 8
+	appStateUpdatePaused   = 1 << 9
+	appStateUpdateStarted  = 1 << 10
+	appStateDownloading    = 1 << 20
+	appStateStaging        = 1 << 21
+	appStateCommitting     = 1 << 22
+)
+
+// appStateNames maps state flags to the names Steam uses for them
+var appStateNames = []struct {
+	flag int64
+	name string
+}{
+	{1 << 0, "Uninstalled"},
+	{appStateUpdateRequired, "UpdateRequired"},
+	{appStateFullyInstalled, "FullyInstalled"},
+	{1 << 3, "Encrypted"},
+	{1 << 4, "Locked"},
+	{1 << 5, "FilesMissing"},
+	{1 << 6, "AppRunning"},
+	{1 << 7, "FilesCorrupt"},
+	{appStateUpdateRunning, "UpdateRunning"},
+	{appStateUpdatePaused, "UpdatePaused"},
+	{appStateUpdateStarted, "UpdateStarted"},
+	{1 << 11, "Uninstalling"},
+	{1 << 12, "BackupRunning"},
+	{1 << 16, "Reconfiguring"},
+	{1 << 17, "Validating"},
+	{1 << 18, "AddingFiles"},
+	{1 << 19, "Preallocating"},
+	{appStateDownloading, "Downloading"},
+	{appStateStaging, "Staging"},
+	{appStateCommitting, "Committing"},
+	{1 << 23, "UpdateStopping"},
+}
+
+// appManifest is the parsed content of a steamapps/appmanifest_<id>.acf file
+type appManifest struct {
+	AppID           string
+	Name            string
+	InstallDir      string
+	StateFlags      int64
+	SizeOnDisk      uint64
+	BytesDownloaded uint64
+	BytesToDownload uint64
+	BytesStaged     uint64
+	BytesToStage    uint64
+	LastUpdated     time.Time
+	Path            string
+	ModTime         time.Time
+}
+
+// readAppManifest parses a single appmanifest file
+func readAppManifest(path string) (*appManifest, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := vdf.ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	state := root.Get("AppState")
+
+	return &appManifest{
+		AppID:           state.GetString("appid"),
+		Name:            state.GetString("name"),
+		InstallDir:      state.GetString("installdir"),
+		StateFlags:      state.GetInt("StateFlags"),
+		SizeOnDisk:      state.GetUint("SizeOnDisk"),
+		BytesDownloaded: state.GetUint("BytesDownloaded"),
+		BytesToDownload: state.GetUint("BytesToDownload"),
+		BytesStaged:     state.GetUint("BytesStaged"),
+		BytesToStage:    state.GetUint("BytesToStage"),
+		LastUpdated:     time.Unix(state.GetInt("LastUpdated"), 0),
+		Path:            path,
+		ModTime:         info.ModTime(),
+	}, nil
+}
+
+// readAppManifests parses every appmanifest in a steamapps directory,
+// skipping files that can't be read, sorted by app ID. Manifests found in
+// cache with an unchanged mtime are reused instead of parsed again; cache
+// may be nil.
+func readAppManifests(steamappsDir string, cache map[string]*appManifest) ([]*appManifest, error) {
+	paths, err := filepath.Glob(filepath.Join(steamappsDir, "appmanifest_*.acf"))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []*appManifest
+	for _, path := range paths {
+		manifest, cached := cache[path]
+		if cached {
+			info, err := os.Stat(path)
+			if err != nil || !info.ModTime().Equal(manifest.ModTime) {
+				cached = false
+			}
+		}
+		if !cached {
+			manifest, err = readAppManifest(path)
+			if err != nil || manifest.AppID == "" {
+				continue
+			}
+			if cache != nil {
+				cache[path] = manifest
+			}
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	// Forget manifests of apps uninstalled from this directory
+	for path := range cache {
+		if filepath.Dir(path) == steamappsDir && !containsString(paths, path) {
+			delete(cache, path)
+		}
+	}
+
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].AppID < manifests[j].AppID
+	})
+	return manifests, nil
+}
+
+// Installed reports whether the app is fully installed
+func (m *appManifest) Installed() bool {
+	return m.StateFlags&appStateFullyInstalled != 0
+}
+
+// Updating reports whether the app has a download or update pending or running
+func (m *appManifest) Updating() bool {
+	const updating = appStateUpdateRequired | appStateUpdateRunning | appStateUpdatePaused |
+		appStateUpdateStarted | appStateDownloading | appStateStaging | appStateCommitting
+	return m.StateFlags&updating != 0
+}
+
+// IsTool reports whether the app is a Steam runtime or compatibility tool
+// rather than a game
+func (m *appManifest) IsTool() bool {
+	return strings.HasPrefix(m.Name, "Proton") ||
+		strings.HasPrefix(m.Name, "Steam Linux Runtime") ||
+		m.Name == "Steamworks Common Redistributables"
+}
+
+// DownloadPercent returns how much of the pending update has been downloaded
+func (m *appManifest) DownloadPercent() float64 {
+	if m.BytesToDownload == 0 {
+		return 0
+	}
+	return float64(m.BytesDownloaded) / float64(m.BytesToDownload) * 100
+}
+
+// StateNames returns the names of the flags set in StateFlags
+func (m *appManifest) StateNames() []string {
+	var names []string
+	for _, state := range appStateNames {
+		if m.StateFlags&state.flag != 0 {
+			names = append(names, state.name)
+		}
+	}
+	return names
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,288 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/internal/vdf"
+)
+
+// systemCompatToolsDir holds compatibility tools installed by the system
+// rather than the user
+const systemCompatToolsDir = "/usr/share/steam/compatibilitytools.d"
+
+// defaultCompatToolApp is the CompatToolMapping key Steam uses for the tool
+// applied to all titles without their own setting
+const defaultCompatToolApp = "0"
+
+// builtinProtonName matches Valve's versioned Proton tool names such as
+// proton_10, proton_9 or proton_513
+var builtinProtonName = regexp.MustCompile(`^proton_(\d+)$`)
+
+// legacyProtonMajors are the Proton majors whose tool names carry the minor
+// version after the major digit, e.g. proton_513 for Proton 5.13. From
+// Proton 7 on, names only give the major version.
+const legacyProtonMajors = "3456"
+
+// builtinCompatTools names Valve's tools that don't follow proton_<version>
+var builtinCompatTools = map[string]string{
+	"proton_experimental":       "Proton - Experimental",
+	"proton_hotfix":             "Proton Hotfix",
+	"steamlinuxruntime":         "Steam Linux Runtime",
+	"steamlinuxruntime_soldier": "Steam Linux Runtime - Soldier",
+	"steamlinuxruntime_sniper":  "Steam Linux Runtime - Sniper",
+}
+
+// compatTool is a compatibility tool Steam runs games with, e.g. a Proton
+// build from Valve or a custom one such as GE-Proton
+type compatTool struct {
+	Name        string // internal name used by CompatToolMapping
+	DisplayName string
+	Version     string
+	Path        string // install directory, "" if not found
+	Custom      bool   // installed in a compatibilitytools.d directory
+}
+
+// compatTools resolves which compatibility tool an app runs with from
+// Steam's config.vdf, the library folders and the installed tools. All are
+// reloaded when they change on disk.
+type compatTools struct {
+	steamDir      string
+	configModTime time.Time
+	mapping       map[string]string // app ID -> tool name
+	libraries     *libraryFolders
+	toolsModTime  map[string]time.Time
+	custom        map[string]*compatTool // by name
+	builtin       map[string]*compatTool // Valve's tools resolved so far, by name
+	versions      map[string]string      // tool directory -> version
+}
+
+// newCompatTools creates a resolver for the Steam installation in steamDir
+func newCompatTools(steamDir string) *compatTools {
+	return &compatTools{
+		steamDir:     steamDir,
+		mapping:      make(map[string]string),
+		libraries:    newLibraryFolders(steamDir),
+		toolsModTime: make(map[string]time.Time),
+		custom:       make(map[string]*compatTool),
+		builtin:      make(map[string]*compatTool),
+		versions:     make(map[string]string),
+	}
+}
+
+// refresh reloads the tool mapping, library folders and custom tools if
+// they changed
+func (t *compatTools) refresh() {
+	configPath := filepath.Join(t.steamDir, "config", "config.vdf")
+	if info, err := os.Stat(configPath); err == nil && !info.ModTime().Equal(t.configModTime) {
+		t.mapping = readCompatToolMapping(configPath)
+		t.configModTime = info.ModTime()
+	}
+
+	// Installing, updating or removing one of Valve's tools rewrites the
+	// library folders
+	if t.libraries.refresh() {
+		t.builtin = make(map[string]*compatTool)
+		t.versions = make(map[string]string)
+	}
+
+	changed := false
+	for _, dir := range t.toolDirs() {
+		var modTime time.Time
+		if info, err := os.Stat(dir); err == nil {
+			modTime = info.ModTime()
+		}
+		if !modTime.Equal(t.toolsModTime[dir]) {
+			t.toolsModTime[dir] = modTime
+			changed = true
+		}
+	}
+	if changed {
+		t.custom = make(map[string]*compatTool)
+		for _, dir := range t.toolDirs() {
+			readCustomCompatTools(dir, t.custom)
+		}
+		// A reinstalled tool may have a new version
+		t.builtin = make(map[string]*compatTool)
+		t.versions = make(map[string]string)
+	}
+}
+
+// toolDirs returns the compatibilitytools.d directories, user ones first so
+// they take precedence over system ones with the same name
+func (t *compatTools) toolDirs() []string {
+	return []string{
+		filepath.Join(t.steamDir, "compatibilitytools.d"),
+		systemCompatToolsDir,
+	}
+}
+
+// forApp returns the tool configured for an app, or nil if it has none.
+// The default tool only applies when the app is known to run through
+// Proton, since Steam uses it for Windows-only titles alone.
+func (t *compatTools) forApp(appID string, proton bool) *compatTool {
+	name := t.mapping[appID]
+	if name == "" && proton {
+		name = t.mapping[defaultCompatToolApp]
+	}
+	if name == "" {
+		return nil
+	}
+	return t.resolve(name)
+}
+
+// resolve looks up a tool by its internal name
+func (t *compatTools) resolve(name string) *compatTool {
+	if tool, exists := t.custom[name]; exists {
+		return tool
+	}
+	if tool, exists := t.builtin[name]; exists {
+		return tool
+	}
+
+	tool := &compatTool{Name: name, DisplayName: builtinCompatToolName(name)}
+	t.builtin[name] = tool
+	for _, library := range t.libraries.libraries {
+		dir := filepath.Join(library, "steamapps", "common", tool.DisplayName)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			tool.Path = dir
+			tool.Version = t.version(dir)
+			break
+		}
+	}
+	return tool
+}
+
+// fromPath identifies the tool installed in dir, as found in a game's
+// STEAM_COMPAT_TOOL_PATHS
+func (t *compatTools) fromPath(dir string) *compatTool {
+	dir = filepath.Clean(dir)
+	canonical := canonicalPath(dir)
+	for _, tool := range t.custom {
+		if tool.Path == dir || canonicalPath(tool.Path) == canonical {
+			return tool
+		}
+	}
+
+	// Valve's tools are installed as apps named after their display name
+	name := filepath.Base(dir)
+	for internal, display := range builtinCompatTools {
+		if display == name {
+			name = internal
+			break
+		}
+	}
+	return &compatTool{
+		Name:        name,
+		DisplayName: filepath.Base(dir),
+		Version:     t.version(dir),
+		Path:        dir,
+	}
+}
+
+// version returns the version recorded in a tool's version file
+func (t *compatTools) version(dir string) string {
+	if version, cached := t.versions[dir]; cached {
+		return version
+	}
+	version := readCompatToolVersion(dir)
+	t.versions[dir] = version
+	return version
+}
+
+// readCompatToolMapping reads the per-app tool selection from config.vdf
+func readCompatToolMapping(path string) map[string]string {
+	mapping := make(map[string]string)
+
+	root, err := vdf.ParseFile(path)
+	if err != nil {
+		return mapping
+	}
+	apps := root.Get("InstallConfigStore", "Software", "Valve", "Steam", "CompatToolMapping")
+	if apps == nil {
+		return mapping
+	}
+	for _, app := range apps.Children {
+		if name := app.GetString("name"); name != "" {
+			mapping[app.Key] = name
+		}
+	}
+	return mapping
+}
+
+// readCustomCompatTools adds the tools described by the compatibilitytool.vdf
+// files below dir to tools
+func readCustomCompatTools(dir string, tools map[string]*compatTool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		toolDir := filepath.Join(dir, entry.Name())
+		root, err := vdf.ParseFile(filepath.Join(toolDir, "compatibilitytool.vdf"))
+		if err != nil {
+			continue
+		}
+		declared := root.Get("compatibilitytools", "compat_tools")
+		if declared == nil {
+			continue
+		}
+		for _, decl := range declared.Children {
+			if _, exists := tools[decl.Key]; exists {
+				continue
+			}
+			path := toolDir
+			if installPath := decl.GetString("install_path"); installPath != "" && installPath != "." {
+				if filepath.IsAbs(installPath) {
+					path = installPath
+				} else {
+					path = filepath.Join(toolDir, installPath)
+				}
+			}
+			displayName := decl.GetString("display_name")
+			if displayName == "" {
+				displayName = decl.Key
+			}
+			tools[decl.Key] = &compatTool{
+				Name:        decl.Key,
+				DisplayName: displayName,
+				Version:     readCompatToolVersion(path),
+				Path:        filepath.Clean(path),
+				Custom:      true,
+			}
+		}
+	}
+}
+
+// readCompatToolVersion reads a tool's version file, which holds a build
+// timestamp followed by the version, e.g. "1712345678 proton-9.0-1"
+func readCompatToolVersion(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "version"))
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// builtinCompatToolName returns the display name, which is also the install
+// directory, of one of Valve's tools, e.g. "Proton 9.0" for proton_9
+func builtinCompatToolName(name string) string {
+	if display, exists := builtinCompatTools[name]; exists {
+		return display
+	}
+	if m := builtinProtonName.FindStringSubmatch(name); m != nil {
+		version := m[1]
+		if len(version) > 1 && strings.ContainsRune(legacyProtonMajors, rune(version[0])) {
+			return "Proton " + version[:1] + "." + version[1:]
+		}
+		return "Proton " + version + ".0"
+	}
+	return name
+}
@@ -0,0 +1,110 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuiltinCompatToolName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"proton_9", "Proton 9.0"},
+		{"proton_10", "Proton 10.0"},
+		{"proton_7", "Proton 7.0"},
+		{"proton_63", "Proton 6.3"},
+		{"proton_513", "Proton 5.13"},
+		{"proton_37", "Proton 3.7"},
+		{"proton_experimental", "Proton - Experimental"},
+		{"proton_hotfix", "Proton Hotfix"},
+		{"GE-Proton9-20", "GE-Proton9-20"},
+	}
+	for _, tt := range tests {
+		if got := builtinCompatToolName(tt.name); got != tt.want {
+			t.Errorf("builtinCompatToolName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func writeCompatToolMapping(t *testing.T, steamDir, name string, modTime time.Time) {
+	t.Helper()
+	path := filepath.Join(steamDir, "config", "config.vdf")
+	writeFile(t, path, `"InstallConfigStore"
+{
+	"Software"
+	{
+		"Valve"
+		{
+			"Steam"
+			{
+				"CompatToolMapping"
+				{
+					"620" { "name" "`+name+`" "config" "" "priority" "250" }
+				}
+			}
+		}
+	}
+}
+`)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeLibraryFolders(t *testing.T, steamDir string, modTime time.Time, libraries ...string) {
+	t.Helper()
+	content := "\"libraryfolders\"\n{\n"
+	for i, library := range libraries {
+		content += fmt.Sprintf("\t\"%d\" { \"path\" \"%s\" }\n", i, library)
+	}
+	path := filepath.Join(steamDir, "steamapps", "libraryfolders.vdf")
+	writeFile(t, path, content+"}\n")
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompatToolsReloadOnChange(t *testing.T) {
+	root := t.TempDir()
+	steamDir := filepath.Join(root, "Steam")
+	sdCard := filepath.Join(root, "mmcblk0p1")
+	modTime := time.Now().Add(-time.Hour)
+	writeCompatToolMapping(t, steamDir, "proton_9", modTime)
+	writeLibraryFolders(t, steamDir, modTime, steamDir)
+	tools := newCompatTools(steamDir)
+
+	forApp := func() *compatTool {
+		t.Helper()
+		tools.refresh()
+		tool := tools.forApp("620", false)
+		if tool == nil {
+			t.Fatal("forApp(620) = nil")
+		}
+		return tool
+	}
+
+	if tool := forApp(); tool.DisplayName != "Proton 9.0" || tool.Path != "" {
+		t.Errorf("before install: %+v, want Proton 9.0 without a path", tool)
+	}
+
+	// Steam lists the library and the tool in libraryfolders.vdf as it
+	// installs it; until then the resolved tool is reused
+	toolDir := filepath.Join(sdCard, "steamapps", "common", "Proton 9.0")
+	writeFile(t, filepath.Join(toolDir, "version"), "1712345678 proton-9.0-1\n")
+	if tool := forApp(); tool.Path != "" {
+		t.Errorf("with unchanged library folders: path %q, want the cached empty one", tool.Path)
+	}
+	modTime = modTime.Add(time.Second)
+	writeLibraryFolders(t, steamDir, modTime, steamDir, sdCard)
+	if tool := forApp(); tool.Path != toolDir || tool.Version != "proton-9.0-1" {
+		t.Errorf("after install: path %q, version %q, want %q, proton-9.0-1", tool.Path, tool.Version, toolDir)
+	}
+
+	writeCompatToolMapping(t, steamDir, "proton_experimental", modTime)
+	if tool := forApp(); tool.DisplayName != "Proton - Experimental" {
+		t.Errorf("after changing the tool: %q, want Proton - Experimental", tool.DisplayName)
+	}
+}
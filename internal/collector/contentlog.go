@@ -0,0 +1,232 @@
+package collector
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// contentLogRateTimeout is how long a logged transfer rate stays valid
+// without a newer rate line
+const contentLogRateTimeout = 10 * time.Second
+
+var (
+	contentLogLine     = regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})\] (.*)$`)
+	contentLogState    = regexp.MustCompile(`^AppID (\d+) state changed : (.*)$`)
+	contentLogUpdate   = regexp.MustCompile(`^AppID (\d+) update changed : (.*)$`)
+	contentLogFinished = regexp.MustCompile(`^AppID (\d+) (?:finished update|scheduler finished|update canceled)`)
+	contentLogRate     = regexp.MustCompile(`(?i)(download|disk write|disk) rate:\s*([\d.]+)\s*(Mbps|Kbps|GB/s|MB/s|KB/s|B/s)`)
+)
+
+// contentLog tails Steam's logs/content_log.txt to follow update jobs and
+// transfer rates
+type contentLog struct {
+	path    string
+	file    os.FileInfo // the file offset refers to
+	offset  int64
+	partial string
+	seq     int
+	apps    map[string]*contentLogApp
+
+	downloadRate     float64 // bytes per second
+	downloadRateTime time.Time
+	diskRate         float64 // bytes per second
+	diskRateTime     time.Time
+}
+
+// contentLogApp is the last known update state of an app
+type contentLogApp struct {
+	running  bool
+	queued   bool
+	queuedAt int
+}
+
+// newContentLog creates a tailer for the content log of a Steam install
+func newContentLog(path string) *contentLog {
+	return &contentLog{
+		path: path,
+		apps: make(map[string]*contentLogApp),
+	}
+}
+
+// update reads lines appended since the last call. A file that shrank or
+// was replaced was rotated by Steam, so it is read again from the start.
+func (l *contentLog) update() error {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < l.offset || (l.file != nil && !os.SameFile(l.file, info)) {
+		l.offset = 0
+		l.partial = ""
+		l.apps = make(map[string]*contentLogApp)
+	}
+	l.file = info
+	if info.Size() == l.offset {
+		return nil
+	}
+
+	if _, err := file.Seek(l.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		l.offset += int64(len(line))
+		if err != nil {
+			// Keep an incomplete last line until Steam finishes writing it
+			l.partial += line
+			break
+		}
+		l.parseLine(strings.TrimRight(l.partial+line, "\r\n"))
+		l.partial = ""
+	}
+
+	return nil
+}
+
+// parseLine applies a single log line to the tracked state
+func (l *contentLog) parseLine(line string) {
+	match := contentLogLine.FindStringSubmatch(line)
+	if match == nil {
+		return
+	}
+	timestamp, err := time.ParseInLocation("2006-01-02 15:04:05", match[1], time.Local)
+	if err != nil {
+		return
+	}
+	message := match[2]
+
+	if m := contentLogState.FindStringSubmatch(message); m != nil {
+		app := l.app(m[1])
+		app.running = strings.Contains(m[2], "Update Running")
+		queued := strings.Contains(m[2], "Update Queued") && !app.running
+		if queued && !app.queued {
+			l.seq++
+			app.queuedAt = l.seq
+		}
+		app.queued = queued
+		return
+	}
+
+	if m := contentLogUpdate.FindStringSubmatch(message); m != nil {
+		app := l.app(m[1])
+		app.running = !strings.HasPrefix(strings.TrimSpace(m[2]), "None")
+		if app.running {
+			app.queued = false
+		}
+		return
+	}
+
+	if m := contentLogFinished.FindStringSubmatch(message); m != nil {
+		delete(l.apps, m[1])
+		return
+	}
+
+	if m := contentLogRate.FindStringSubmatch(message); m != nil {
+		rate, err := strconv.ParseFloat(m[2], 64)
+		if err != nil {
+			return
+		}
+		rate *= rateUnitBytes(m[3])
+		if strings.EqualFold(m[1], "download") {
+			l.downloadRate, l.downloadRateTime = rate, timestamp
+		} else {
+			l.diskRate, l.diskRateTime = rate, timestamp
+		}
+	}
+}
+
+func (l *contentLog) app(appID string) *contentLogApp {
+	app, exists := l.apps[appID]
+	if !exists {
+		app = &contentLogApp{}
+		l.apps[appID] = app
+	}
+	return app
+}
+
+// activeApp returns the app whose update is running, or ""
+func (l *contentLog) activeApp() string {
+	for appID, app := range l.apps {
+		if app.running {
+			return appID
+		}
+	}
+	return ""
+}
+
+// activeCount returns the number of apps with a running update
+func (l *contentLog) activeCount() int {
+	count := 0
+	for _, app := range l.apps {
+		if app.running {
+			count++
+		}
+	}
+	return count
+}
+
+// queue returns the app IDs waiting for an update, in the order queued
+func (l *contentLog) queue() []string {
+	var queue []string
+	for appID, app := range l.apps {
+		if app.queued {
+			queue = append(queue, appID)
+		}
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		return l.apps[queue[i]].queuedAt < l.apps[queue[j]].queuedAt
+	})
+	return queue
+}
+
+// rates returns the current download and disk write rates in bytes per
+// second. Rates go stale when Steam stops logging them.
+func (l *contentLog) rates(now time.Time) (float64, float64, bool) {
+	if l.activeApp() == "" {
+		return 0, 0, false
+	}
+
+	var download, disk float64
+	fresh := false
+	if now.Sub(l.downloadRateTime) < contentLogRateTimeout {
+		download = l.downloadRate
+		fresh = true
+	}
+	if now.Sub(l.diskRateTime) < contentLogRateTimeout {
+		disk = l.diskRate
+		fresh = true
+	}
+	return download, disk, fresh
+}
+
+// rateUnitBytes converts a rate unit to bytes per second
+func rateUnitBytes(unit string) float64 {
+	switch unit {
+	case "Mbps":
+		return 1e6 / 8
+	case "Kbps":
+		return 1e3 / 8
+	case "GB/s":
+		return 1024 * 1024 * 1024
+	case "MB/s":
+		return 1024 * 1024
+	case "KB/s":
+		return 1024
+	default:
+		return 1
+	}
+}
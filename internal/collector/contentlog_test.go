@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func appendLog(t *testing.T, path, content string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func updateLog(t *testing.T, l *contentLog) {
+	t.Helper()
+	if err := l.update(); err != nil {
+		t.Fatalf("update() = %v", err)
+	}
+}
+
+func TestContentLogPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "content_log.txt")
+	l := newContentLog(path)
+
+	appendLog(t, path, "[2024-05-01 12:00:00] AppID 620 state changed : Update Re")
+	updateLog(t, l)
+	if app := l.activeApp(); app != "" {
+		t.Errorf("activeApp() with half a line = %q, want none", app)
+	}
+
+	appendLog(t, path, "quired,Update Running,\n[2024-05-01 12:00:01] AppID 570 state changed : Update Queued,\n")
+	updateLog(t, l)
+	if app := l.activeApp(); app != "620" {
+		t.Errorf("activeApp() = %q, want 620", app)
+	}
+	if queue := l.queue(); !reflect.DeepEqual(queue, []string{"570"}) {
+		t.Errorf("queue() = %q, want [570]", queue)
+	}
+}
+
+func TestContentLogTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "content_log.txt")
+	l := newContentLog(path)
+
+	appendLog(t, path, "[2024-05-01 12:00:00] AppID 620 state changed : Update Running,\n"+
+		"[2024-05-01 12:00:01] AppID 570 state changed : Update Queued,\n")
+	updateLog(t, l)
+
+	if err := os.WriteFile(path, []byte("[2024-05-01 13:00:00] AppID 440 update changed : Running Update\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	updateLog(t, l)
+	if app := l.activeApp(); app != "440" {
+		t.Errorf("activeApp() after truncation = %q, want 440", app)
+	}
+	if count := l.activeCount(); count != 1 {
+		t.Errorf("activeCount() after truncation = %d, want 1", count)
+	}
+	if queue := l.queue(); len(queue) != 0 {
+		t.Errorf("queue() after truncation = %q, want empty", queue)
+	}
+}
+
+func TestContentLogRotated(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "content_log.txt")
+	l := newContentLog(path)
+
+	appendLog(t, path, "[2024-05-01 12:00:00] AppID 620 state changed : Update Running,\n")
+	updateLog(t, l)
+
+	// Steam moves the full log aside and starts a new one, which can be
+	// longer than what was read from the old one by the next tick
+	if err := os.Rename(path, filepath.Join(dir, "content_log.previous.txt")); err != nil {
+		t.Fatal(err)
+	}
+	appendLog(t, path, "[2024-05-01 12:00:05] AppID 620 finished update (BytesDownloaded 1024)\n"+
+		"[2024-05-01 12:00:06] AppID 570 state changed : Update Running,\n"+
+		"[2024-05-01 12:00:07] Current download rate: 80.000 Mbps\n")
+	updateLog(t, l)
+
+	if app := l.activeApp(); app != "570" {
+		t.Errorf("activeApp() after rotation = %q, want 570", app)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 8, 0, time.Local)
+	if download, _, fresh := l.rates(now); !fresh || download != 10e6 {
+		t.Errorf("rates() after rotation = %v, %v, want 10e6 bytes/s", download, fresh)
+	}
+}
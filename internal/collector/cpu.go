@@ -9,23 +9,34 @@ import (
 )
 
 // CPUCollector collects CPU metrics
-type CPUCollector struct{}
+type CPUCollector struct {
+	interval time.Duration
+}
 
 // NewCPUCollector creates a new CPU collector
 func NewCPUCollector() *CPUCollector {
-	return &CPUCollector{}
+	return NewCPUCollectorWithInterval(time.Second)
+}
+
+// NewCPUCollectorWithInterval creates a CPU collector that measures usage
+// over the given interval. An interval of zero measures usage since the
+// previous call instead of blocking, which suits high-frequency sampling.
+func NewCPUCollectorWithInterval(interval time.Duration) *CPUCollector {
+	return &CPUCollector{
+		interval: interval,
+	}
 }
 
 // Collect gathers CPU statistics
 func (c *CPUCollector) Collect() (*metrics.CPUStats, error) {
 	// Get overall CPU percentage
-	overallPercent, err := cpu.Percent(time.Second, false)
+	overallPercent, err := cpu.Percent(c.interval, false)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get per-core CPU percentage
-	perCorePercent, err := cpu.Percent(time.Second, true)
+	perCorePercent, err := cpu.Percent(c.interval, true)
 	if err != nil {
 		return nil, err
 	}
@@ -48,4 +59,3 @@ func (c *CPUCollector) Collect() (*metrics.CPUStats, error) {
 
 	return stats, nil
 }
-
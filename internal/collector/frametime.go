@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"math"
+	"sort"
+)
+
+// defaultFrameTimeWindow is the number of frame time samples kept for statistics
+const defaultFrameTimeWindow = 1000
+
+// FrameTimeStats summarizes the frame times currently held in a window
+type FrameTimeStats struct {
+	Samples      int
+	Min          float64
+	Max          float64
+	Mean         float64
+	Median       float64
+	P95          float64
+	P99          float64
+	StdDev       float64
+	Low1Percent  float64 // FPS
+	Low01Percent float64 // FPS
+}
+
+// frameTimeWindow is a fixed-size ring buffer of frame times in milliseconds
+type frameTimeWindow struct {
+	samples []float64
+	next    int
+	full    bool
+}
+
+// newFrameTimeWindow creates a window holding up to size frame times
+func newFrameTimeWindow(size int) *frameTimeWindow {
+	if size <= 0 {
+		size = defaultFrameTimeWindow
+	}
+	return &frameTimeWindow{
+		samples: make([]float64, size),
+	}
+}
+
+// Add records a single frame time, evicting the oldest one when full
+func (w *frameTimeWindow) Add(frameTime float64) {
+	if frameTime <= 0 || math.IsNaN(frameTime) || math.IsInf(frameTime, 0) {
+		return
+	}
+	w.samples[w.next] = frameTime
+	w.next++
+	if w.next == len(w.samples) {
+		w.next = 0
+		w.full = true
+	}
+}
+
+// Len returns the number of frame times currently held
+func (w *frameTimeWindow) Len() int {
+	if w.full {
+		return len(w.samples)
+	}
+	return w.next
+}
+
+// Reset discards all recorded frame times
+func (w *frameTimeWindow) Reset() {
+	w.next = 0
+	w.full = false
+}
+
+// Values returns a copy of the recorded frame times, oldest first
+func (w *frameTimeWindow) Values() []float64 {
+	if !w.full {
+		return append([]float64(nil), w.samples[:w.next]...)
+	}
+	values := make([]float64, 0, len(w.samples))
+	values = append(values, w.samples[w.next:]...)
+	values = append(values, w.samples[:w.next]...)
+	return values
+}
+
+// Stats computes statistics over the recorded frame times
+func (w *frameTimeWindow) Stats() FrameTimeStats {
+	return ComputeFrameTimeStats(w.Values())
+}
+
+// ComputeFrameTimeStats computes frame time statistics for a set of frame
+// times in milliseconds. The 1% and 0.1% lows are the average FPS of the
+// slowest 1% and 0.1% of frames.
+func ComputeFrameTimeStats(frameTimes []float64) FrameTimeStats {
+	n := len(frameTimes)
+	if n == 0 {
+		return FrameTimeStats{}
+	}
+
+	sorted := append([]float64(nil), frameTimes...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, ft := range sorted {
+		sum += ft
+	}
+	mean := sum / float64(n)
+
+	var variance float64
+	for _, ft := range sorted {
+		variance += (ft - mean) * (ft - mean)
+	}
+	variance /= float64(n)
+
+	return FrameTimeStats{
+		Samples:      n,
+		Min:          sorted[0],
+		Max:          sorted[n-1],
+		Mean:         mean,
+		Median:       percentile(sorted, 50),
+		P95:          percentile(sorted, 95),
+		P99:          percentile(sorted, 99),
+		StdDev:       math.Sqrt(variance),
+		Low1Percent:  lowFPS(sorted, 0.01),
+		Low01Percent: lowFPS(sorted, 0.001),
+	}
+}
+
+// percentile returns the nearest-rank percentile of an ascending slice
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// lowFPS returns the average FPS of the slowest fraction of frames in an
+// ascending slice of frame times
+func lowFPS(sorted []float64, fraction float64) float64 {
+	count := int(math.Ceil(fraction * float64(len(sorted))))
+	if count < 1 {
+		count = 1
+	}
+
+	var sum float64
+	for _, ft := range sorted[len(sorted)-count:] {
+		sum += ft
+	}
+	avg := sum / float64(count)
+	if avg <= 0 {
+		return 0
+	}
+	return 1000.0 / avg
+}
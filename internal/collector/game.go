@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/steam-os-monitor/monitor/pkg/metrics"
@@ -13,13 +14,35 @@ import (
 
 // GameCollector collects game performance metrics
 // This integrates with gamescope and Steam APIs to get FPS and frame time data
+// Frame time statistics are computed over a rolling window of recent frames,
+// read from the game's MangoHud log when it writes one and otherwise
+// sampled from the FPS once per collection
 type GameCollector struct {
 	lastFrameTime time.Time
+	lastAppID     string
+	lastSource    string
+	frameTimes    *frameTimeWindow
+	mangoHud      *mangoHudLog
+	newFrameTimes []float64 // read from MangoHud by the last Collect
+	steamDir      string
+	procRoot      string
+	compatTools   *compatTools
+	shortcuts     *shortcuts
+
+	mu       sync.Mutex
+	appNames map[string]string // names from other sources, e.g. the Steam Web API
 }
 
 // NewGameCollector creates a new game collector
 func NewGameCollector() *GameCollector {
-	return &GameCollector{}
+	steamDir := defaultSteamDir()
+	return &GameCollector{
+		frameTimes:  newFrameTimeWindow(defaultFrameTimeWindow),
+		steamDir:    steamDir,
+		procRoot:    "/proc",
+		compatTools: newCompatTools(steamDir),
+		shortcuts:   newShortcuts(steamDir),
+	}
 }
 
 // Collect gathers game performance statistics
@@ -38,22 +61,112 @@ func (c *GameCollector) Collect() (*metrics.GamePerformanceStats, error) {
 		}
 	}
 
-	// Try to get game name from Steam
-	gameName, err := c.getCurrentGameName()
+	// Find the game Steam is running and resolve its name
+	game, err := findRunningGame(c.procRoot)
 	if err == nil {
-		stats.GameName = gameName
+		stats.AppID = game.AppID
+		stats.PID = game.PID
+		stats.Proton = game.Proton
+		stats.GameName = c.getGameName(game.AppID)
+		c.applyCompatTool(stats, game)
+	}
+
+	frameTimes := c.readMangoHud(game)
+	stats.FrameTimeSource = metrics.FrameTimeSourceSampled
+	if frameTimes != nil {
+		stats.FrameTimeSource = metrics.FrameTimeSourceMangoHud
 	}
 
-	// Frame time min/max would require tracking over time
-	// For now, set them to the current frame time
-	if stats.FrameTime > 0 {
-		stats.FrameTimeMin = stats.FrameTime
-		stats.FrameTimeMax = stats.FrameTime
+	// Frame times from a previous game, or measured differently, would
+	// skew the statistics
+	if stats.AppID != c.lastAppID || stats.FrameTimeSource != c.lastSource {
+		c.frameTimes.Reset()
+		c.lastAppID = stats.AppID
+		c.lastSource = stats.FrameTimeSource
 	}
 
+	if len(frameTimes) > 0 {
+		var sum float64
+		for _, ft := range frameTimes {
+			c.frameTimes.Add(ft)
+			sum += ft
+		}
+		// The frames since the last collection give the FPS when gamescope
+		// doesn't
+		if stats.FPS == 0 {
+			stats.FrameTime = sum / float64(len(frameTimes))
+			stats.FPS = 1000.0 / stats.FrameTime
+		}
+		c.lastFrameTime = stats.Timestamp
+	} else if frameTimes == nil && stats.FrameTime > 0 {
+		c.frameTimes.Add(stats.FrameTime)
+		c.lastFrameTime = stats.Timestamp
+	}
+	c.newFrameTimes = frameTimes
+	c.applyFrameTimeStats(stats)
+
 	return stats, nil
 }
 
+// readMangoHud returns the frame times the running game logged to MangoHud
+// since the last collection, or nil when it doesn't log any
+func (c *GameCollector) readMangoHud(game *runningGame) []float64 {
+	if game == nil || game.MangoHudDir == "" {
+		c.mangoHud = nil
+		return nil
+	}
+	if c.mangoHud == nil || c.mangoHud.dir != game.MangoHudDir {
+		c.mangoHud = newMangoHudLog(game.MangoHudDir)
+	}
+	frameTimes, err := c.mangoHud.Read()
+	if err != nil {
+		return nil
+	}
+	if frameTimes == nil {
+		frameTimes = []float64{}
+	}
+	return frameTimes
+}
+
+// FrameTimes returns the frame times of the individual frames read by the
+// last Collect. It is empty unless the game logs frame times to MangoHud.
+func (c *GameCollector) FrameTimes() []float64 {
+	return c.newFrameTimes
+}
+
+// applyFrameTimeStats fills the frame time statistics from the rolling window
+func (c *GameCollector) applyFrameTimeStats(stats *metrics.GamePerformanceStats) {
+	ft := c.frameTimes.Stats()
+	stats.FrameSamples = ft.Samples
+	stats.FrameTimeMin = ft.Min
+	stats.FrameTimeMax = ft.Max
+	stats.FrameTimeMean = ft.Mean
+	stats.FrameTimeMedian = ft.Median
+	stats.FrameTimeP95 = ft.P95
+	stats.FrameTimeP99 = ft.P99
+	stats.FrameTimeStdDev = ft.StdDev
+	stats.FPS1PercentLow = ft.Low1Percent
+	stats.FPS01PercentLow = ft.Low01Percent
+}
+
+// applyCompatTool fills the compatibility tool the game runs with. The tool
+// path in the game's environment is authoritative; the configured mapping is
+// the fallback when it is missing.
+func (c *GameCollector) applyCompatTool(stats *metrics.GamePerformanceStats, game *runningGame) {
+	c.compatTools.refresh()
+
+	var tool *compatTool
+	if game.ToolPath != "" {
+		tool = c.compatTools.fromPath(game.ToolPath)
+	} else {
+		tool = c.compatTools.forApp(game.AppID, game.Proton)
+	}
+	if tool != nil {
+		stats.CompatTool = tool.DisplayName
+		stats.CompatToolVersion = tool.Version
+	}
+}
+
 // getFPSFromGamescope attempts to get FPS from gamescope
 // This is a placeholder - actual implementation would need gamescope integration
 func (c *GameCollector) getFPSFromGamescope() (float64, error) {
@@ -91,34 +204,30 @@ func (c *GameCollector) getFPSFromGamescope() (float64, error) {
 	return 0, fmt.Errorf("could not get FPS from gamescope")
 }
 
-// getCurrentGameName attempts to get the current game name
-func (c *GameCollector) getCurrentGameName() (string, error) {
-	// Try to get from Steam process or environment
-	// This is a placeholder - actual implementation would query Steam API
+// SetAppNames provides names for apps without a local appmanifest, e.g.
+// games streamed from another PC, by app ID
+func (c *GameCollector) SetAppNames(names map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	// Check for Steam game name in environment
-	if gameName := os.Getenv("STEAM_GAME_NAME"); gameName != "" {
-		return gameName, nil
-	}
+	c.appNames = names
+}
 
-	// Try to get from Steam process
-	cmd := exec.Command("ps", "aux")
-	output, err := cmd.Output()
-	if err == nil {
-		lines := strings.Split(string(output), "\n")
-		for _, line := range lines {
-			if strings.Contains(line, "steam") && strings.Contains(line, "game") {
-				// Extract game name from process line
-				parts := strings.Fields(line)
-				for _, part := range parts {
-					if strings.Contains(part, ".exe") || strings.Contains(part, "game") {
-						return part, nil
-					}
-				}
-			}
-		}
+// getGameName resolves an app ID to the name in its appmanifest, the
+// user's shortcut for non-Steam games or the names set with SetAppNames,
+// falling back to the app ID itself
+func (c *GameCollector) getGameName(appID string) string {
+	if name, err := appManifestName(c.steamDir, appID); err == nil && name != "" {
+		return name
 	}
-
-	return "", fmt.Errorf("could not determine game name")
+	if shortcut := c.shortcuts.find(appID); shortcut != nil && shortcut.Name != "" {
+		return shortcut.Name
+	}
+	c.mu.Lock()
+	name := c.appNames[appID]
+	c.mu.Unlock()
+	if name != "" {
+		return name
+	}
+	return fmt.Sprintf("App %s", appID)
 }
-
@@ -0,0 +1,129 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/steam-os-monitor/monitor/internal/vdf"
+)
+
+// steamLibraries returns the root paths of all Steam library folders listed
+// in libraryfolders.vdf. The main Steam directory is always included first.
+func steamLibraries(steamDir string) []string {
+	libraries := []string{steamDir}
+	seen := map[string]bool{canonicalPath(steamDir): true}
+
+	for _, candidate := range libraryFolderFiles(steamDir) {
+		root, err := vdf.ParseFile(candidate)
+		if err != nil {
+			continue
+		}
+
+		folders := root.Get("libraryfolders")
+		if folders == nil {
+			continue
+		}
+		for _, folder := range folders.Children {
+			// Current format nests the path; the legacy format maps index to path
+			path := folder.GetString("path")
+			if path == "" {
+				path = folder.Value
+			}
+			if path == "" || !filepath.IsAbs(path) {
+				continue
+			}
+
+			canonical := canonicalPath(path)
+			if seen[canonical] {
+				continue
+			}
+			seen[canonical] = true
+			libraries = append(libraries, path)
+		}
+	}
+
+	return libraries
+}
+
+// libraryFolderFiles returns where libraryfolders.vdf can be. Older clients
+// keep the file in steamapps, newer ones also in config.
+func libraryFolderFiles(steamDir string) []string {
+	return []string{
+		filepath.Join(steamDir, "steamapps", "libraryfolders.vdf"),
+		filepath.Join(steamDir, "config", "libraryfolders.vdf"),
+	}
+}
+
+// libraryFolders caches the library list of a Steam installation. Steam
+// rewrites libraryfolders.vdf whenever a library is added or an app is
+// installed into one, so the list is only read again when it changes.
+type libraryFolders struct {
+	steamDir  string
+	modTimes  map[string]time.Time // by libraryfolders.vdf path
+	libraries []string
+}
+
+// newLibraryFolders creates a library list cache for steamDir
+func newLibraryFolders(steamDir string) *libraryFolders {
+	return &libraryFolders{
+		steamDir: steamDir,
+		modTimes: make(map[string]time.Time),
+	}
+}
+
+// refresh rereads the library list if a libraryfolders.vdf changed and
+// reports whether it did
+func (l *libraryFolders) refresh() bool {
+	changed := l.libraries == nil
+	for _, path := range libraryFolderFiles(l.steamDir) {
+		var modTime time.Time
+		if info, err := os.Stat(path); err == nil {
+			modTime = info.ModTime()
+		}
+		if !modTime.Equal(l.modTimes[path]) {
+			l.modTimes[path] = modTime
+			changed = true
+		}
+	}
+	if changed {
+		l.libraries = steamLibraries(l.steamDir)
+	}
+	return changed
+}
+
+// canonicalPath resolves symlinks so the same library isn't listed twice;
+// ~/.steam/steam usually links to ~/.local/share/Steam
+func canonicalPath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
+
+// findPartition returns the partition whose mount point contains path, or
+// nil if none does
+func findPartition(path string, partitions []disk.PartitionStat) *disk.PartitionStat {
+	path = canonicalPath(path)
+
+	var best *disk.PartitionStat
+	for i := range partitions {
+		mount := partitions[i].Mountpoint
+		if path != mount && !strings.HasPrefix(path, strings.TrimSuffix(mount, "/")+"/") {
+			continue
+		}
+		if best == nil || len(mount) > len(best.Mountpoint) {
+			best = &partitions[i]
+		}
+	}
+	return best
+}
+
+// libraryExists reports whether a library folder is currently reachable,
+// e.g. whether the microSD card holding it is inserted
+func libraryExists(library string) bool {
+	info, err := os.Stat(filepath.Join(library, "steamapps"))
+	return err == nil && info.IsDir()
+}
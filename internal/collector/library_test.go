@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSteamLibrariesMultiple(t *testing.T) {
+	root := t.TempDir()
+	steamDir := filepath.Join(root, "Steam")
+	sdCard := filepath.Join(root, "mmcblk0p1")
+	external := filepath.Join(root, "external")
+	writeFile(t, filepath.Join(steamDir, "steamapps", "libraryfolders.vdf"), `"libraryfolders"
+{
+	"0"
+	{
+		"path"		"`+steamDir+`"
+		"apps" { "620" "123" }
+	}
+	"1"
+	{
+		"path"		"`+sdCard+`"
+	}
+	"2"
+	{
+		"path"		"relative/library"
+	}
+}
+`)
+	// Legacy format, index mapped straight to the path
+	writeFile(t, filepath.Join(steamDir, "config", "libraryfolders.vdf"), `"LibraryFolders"
+{
+	"1"		"`+sdCard+`"
+	"2"		"`+external+`"
+}
+`)
+
+	got := steamLibraries(steamDir)
+	want := []string{steamDir, sdCard, external}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("steamLibraries() = %q, want %q", got, want)
+	}
+}
+
+func TestSteamLibrariesSymlinked(t *testing.T) {
+	root := t.TempDir()
+	steamDir := filepath.Join(root, "share", "Steam")
+	sdCard := filepath.Join(root, "media", "mmcblk0p1")
+	for _, dir := range []string{steamDir, sdCard} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// ~/.steam/steam links to the real install, as on SteamOS
+	steamLink := filepath.Join(root, "steam")
+	sdLink := filepath.Join(root, "sdcard")
+	for link, target := range map[string]string{steamLink: steamDir, sdLink: sdCard} {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(steamDir, "steamapps", "libraryfolders.vdf"), `"libraryfolders"
+{
+	"0" { "path" "`+steamDir+`" }
+	"1" { "path" "`+sdLink+`" }
+	"2" { "path" "`+sdCard+`" }
+}
+`)
+
+	got := steamLibraries(steamLink)
+	want := []string{steamLink, sdLink}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("steamLibraries() = %q, want %q", got, want)
+	}
+}
+
+func TestSteamLibrariesMissingFile(t *testing.T) {
+	steamDir := t.TempDir()
+
+	got := steamLibraries(steamDir)
+	want := []string{steamDir}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("steamLibraries() = %q, want %q", got, want)
+	}
+}
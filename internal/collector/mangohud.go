@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mangoHudActiveLog is how recently a MangoHud log must have been written
+// to be taken as the running game's
+const mangoHudActiveLog = 10 * time.Second
+
+// mangoHudOutputFolder returns the folder MangoHud writes logs to, from the
+// output_folder option in MANGOHUD_CONFIG
+func mangoHudOutputFolder(environ map[string]string) string {
+	for _, option := range strings.Split(environ["MANGOHUD_CONFIG"], ",") {
+		key, value, _ := strings.Cut(option, "=")
+		if strings.TrimSpace(key) == "output_folder" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// mangoHudLog follows the CSV log MangoHud writes while a game runs with
+// logging enabled. The log starts with a system summary, followed by a
+// header with a frametime column in milliseconds and a row per logged
+// frame; with log_interval=0 every frame is logged.
+type mangoHudLog struct {
+	dir       string
+	path      string
+	offset    int64
+	column    int    // of frametime, -1 until the header was read
+	remainder []byte // incomplete last line
+}
+
+// newMangoHudLog creates a reader of the newest log in dir
+func newMangoHudLog(dir string) *mangoHudLog {
+	return &mangoHudLog{dir: dir, column: -1}
+}
+
+// Read returns the frame times logged since the last call. It switches to
+// a newer log when MangoHud starts one, e.g. for the next logging run.
+func (l *mangoHudLog) Read() ([]float64, error) {
+	newest, err := newestMangoHudLog(l.dir)
+	if err != nil {
+		return nil, err
+	}
+	if newest != l.path {
+		l.path, l.offset, l.column, l.remainder = newest, 0, -1, nil
+	}
+
+	f, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(l.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	l.offset += int64(len(data))
+
+	data = append(l.remainder, data...)
+	end := bytes.LastIndexByte(data, '\n')
+	l.remainder = append([]byte(nil), data[end+1:]...)
+
+	var frameTimes []float64
+	for _, line := range strings.Split(string(data[:end+1]), "\n") {
+		row, err := csv.NewReader(strings.NewReader(line)).Read()
+		if err != nil {
+			continue
+		}
+		if l.column < 0 {
+			for i, name := range row {
+				if strings.TrimSpace(name) == "frametime" {
+					l.column = i
+				}
+			}
+			continue
+		}
+		if l.column >= len(row) {
+			continue
+		}
+		if ft, err := strconv.ParseFloat(strings.TrimSpace(row[l.column]), 64); err == nil && ft > 0 {
+			frameTimes = append(frameTimes, ft)
+		}
+	}
+	return frameTimes, nil
+}
+
+// newestMangoHudLog returns the most recently written CSV log in dir, if
+// it is still being written to
+func newestMangoHudLog(dir string) (string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.csv"))
+	if err != nil {
+		return "", err
+	}
+	var newest string
+	var newestTime time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if info.ModTime().After(newestTime) {
+			newest, newestTime = path, info.ModTime()
+		}
+	}
+	if newest == "" || time.Since(newestTime) > mangoHudActiveLog {
+		return "", os.ErrNotExist
+	}
+	return newest, nil
+}
@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMangoHudOutputFolder(t *testing.T) {
+	environ := map[string]string{"MANGOHUD_CONFIG": "fps_limit=60, output_folder=/home/deck/mangologs ,autostart_log=1"}
+	if got := mangoHudOutputFolder(environ); got != "/home/deck/mangologs" {
+		t.Errorf("mangoHudOutputFolder() = %q", got)
+	}
+	if got := mangoHudOutputFolder(map[string]string{}); got != "" {
+		t.Errorf("mangoHudOutputFolder() without MANGOHUD_CONFIG = %q", got)
+	}
+}
+
+func TestMangoHudLogRead(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Portal2_2024-01-02_15-04-05.csv")
+	appendLog := func(data string) {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	appendLog("os,cpu,gpu,ram,kernel,driver,cpuscheduler\n" +
+		"SteamOS,AMD Custom APU 0405,AMD Custom GPU 0405,16GB,6.1.52,Mesa 24.1,performance\n" +
+		"--------------------FRAME METRICS--------------------\n" +
+		"fps,frametime,cpu_load,gpu_load,elapsed\n" +
+		"60.1,16.6,20,80,1000\n" +
+		"59.8,16.7,21,")
+
+	log := newMangoHudLog(dir)
+	frameTimes, err := log.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{16.6}; !reflect.DeepEqual(frameTimes, want) {
+		t.Errorf("first Read() = %v, want %v", frameTimes, want)
+	}
+
+	// The incomplete row is completed by the next write
+	appendLog("81,2000\n30.2,33.1,25,90,3000\n")
+	frameTimes, err = log.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{16.7, 33.1}; !reflect.DeepEqual(frameTimes, want) {
+		t.Errorf("second Read() = %v, want %v", frameTimes, want)
+	}
+
+	frameTimes, err = log.Read()
+	if err != nil || len(frameTimes) != 0 {
+		t.Errorf("Read() without new rows = %v, %v", frameTimes, err)
+	}
+}
+
+func TestMangoHudLogWithoutLog(t *testing.T) {
+	if _, err := newMangoHudLog(t.TempDir()).Read(); err == nil {
+		t.Error("Read() without a log succeeded")
+	}
+}
@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/internal/vdf"
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// localConfigFile is the parsed playtime of one user's localconfig.vdf
+type localConfigFile struct {
+	modTime  time.Time
+	playtime []metrics.AppPlaytime
+}
+
+// localPlaytime reads the playtime Steam records per app in every user's
+// localconfig.vdf. The file is large and changes rarely, so each user's
+// copy is only parsed again after it changed.
+type localPlaytime struct {
+	steamDir string
+	files    map[string]*localConfigFile // by path
+}
+
+// newLocalPlaytime creates a playtime reader for the Steam installation in steamDir
+func newLocalPlaytime(steamDir string) *localPlaytime {
+	return &localPlaytime{
+		steamDir: steamDir,
+		files:    make(map[string]*localConfigFile),
+	}
+}
+
+// all returns the playtime of all users' apps, most recently played first
+func (p *localPlaytime) all() []metrics.AppPlaytime {
+	paths, _ := filepath.Glob(filepath.Join(p.steamDir, "userdata", "*", "config", "localconfig.vdf"))
+
+	var all []metrics.AppPlaytime
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		seen[path] = true
+
+		file, cached := p.files[path]
+		if !cached || !file.modTime.Equal(info.ModTime()) {
+			userID := filepath.Base(filepath.Dir(filepath.Dir(path)))
+			parsed, err := readLocalPlaytime(path, userID)
+			if err != nil {
+				continue
+			}
+			file = &localConfigFile{modTime: info.ModTime(), playtime: parsed}
+			p.files[path] = file
+		}
+		all = append(all, file.playtime...)
+	}
+
+	for path := range p.files {
+		if !seen[path] {
+			delete(p.files, path)
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].LastPlayed.After(all[j].LastPlayed)
+	})
+	return all
+}
+
+// readLocalPlaytime reads the Playtime, Playtime2wks and LastPlayed values
+// of the apps in one user's localconfig.vdf
+func readLocalPlaytime(path, userID string) ([]metrics.AppPlaytime, error) {
+	root, err := vdf.ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	apps := root.Get("UserLocalConfigStore", "Software", "Valve", "Steam", "apps")
+	if apps == nil {
+		return nil, nil
+	}
+
+	var result []metrics.AppPlaytime
+	for _, app := range apps.Children {
+		lastPlayed := app.GetInt("LastPlayed")
+		minutes := app.GetUint("Playtime")
+		if lastPlayed == 0 && minutes == 0 {
+			continue // Seen in the library but never played
+		}
+		playtime := metrics.AppPlaytime{
+			AppID:          app.Key,
+			UserID:         userID,
+			Playtime:       time.Duration(minutes) * time.Minute,
+			Playtime2Weeks: time.Duration(app.GetUint("Playtime2wks")) * time.Minute,
+		}
+		if lastPlayed > 0 {
+			playtime.LastPlayed = time.Unix(lastPlayed, 0)
+		}
+		result = append(result, playtime)
+	}
+	return result, nil
+}
@@ -0,0 +1,175 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// runningGame describes the game process Steam is currently running
+type runningGame struct {
+	AppID       string
+	PID         int
+	Proton      bool
+	ToolPath    string // compatibility tool directory from STEAM_COMPAT_TOOL_PATHS
+	MangoHudDir string // MangoHud's log folder, if the game runs with MangoHud logging
+}
+
+// steamProcess is a process started by Steam for an app
+type steamProcess struct {
+	pid     int
+	appID   string
+	gameID  string
+	comm    string
+	cmdline []string
+	environ map[string]string
+}
+
+// launcherProcesses are wrapper processes Steam starts around the game itself
+var launcherProcesses = map[string]bool{
+	"reaper":               true,
+	"steam-launch-wrapper": true,
+	"pressure-vessel-wrap": true,
+	"pv-bwrap":             true,
+	"pv-adverb":            true,
+	"srt-bwrap":            true,
+	"proton":               true,
+	"python3":              true,
+	"wineserver":           true,
+	"sh":                   true,
+	"bash":                 true,
+}
+
+// findRunningGame scans procRoot for processes launched by Steam for a game.
+// The reaper's AppId argument identifies the title; the newest non-launcher
+// process carrying the same SteamAppId/SteamGameId is reported as the game.
+func findRunningGame(procRoot string) (*runningGame, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var launchedAppID string
+	var processes []*steamProcess
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+
+		proc, err := readSteamProcess(procRoot, pid)
+		if err != nil || proc == nil {
+			continue
+		}
+
+		if proc.comm == "reaper" {
+			if appID := reaperAppID(proc.cmdline); appID != "" {
+				launchedAppID = shortcutAppID(appID)
+			}
+		}
+		processes = append(processes, proc)
+	}
+
+	appID := launchedAppID
+	if appID == "" {
+		// Without a reaper fall back to any process tagged with an app ID
+		for _, proc := range processes {
+			if proc.appID != "" && proc.appID != "0" {
+				appID = proc.appID
+				break
+			}
+			if proc.gameID != "" && proc.gameID != "0" {
+				appID = proc.gameID
+				break
+			}
+		}
+	}
+	if appID == "" {
+		return nil, fmt.Errorf("no Steam game running")
+	}
+
+	game := &runningGame{AppID: appID}
+	for _, proc := range processes {
+		if proc.appID != appID && proc.gameID != appID {
+			continue
+		}
+		if _, ok := proc.environ["STEAM_COMPAT_DATA_PATH"]; ok {
+			game.Proton = true
+		}
+		// The first entry is the tool the game runs with, later ones are
+		// the runtimes it is layered on
+		if paths := proc.environ["STEAM_COMPAT_TOOL_PATHS"]; paths != "" && game.ToolPath == "" {
+			game.ToolPath, _, _ = strings.Cut(paths, ":")
+		}
+		if dir := mangoHudOutputFolder(proc.environ); dir != "" && game.MangoHudDir == "" {
+			game.MangoHudDir = dir
+		}
+		if len(proc.cmdline) > 0 && filepath.Base(proc.cmdline[0]) == "proton" {
+			game.Proton = true
+		}
+		if launcherProcesses[proc.comm] {
+			continue
+		}
+		if proc.pid > game.PID {
+			game.PID = proc.pid
+		}
+	}
+
+	return game, nil
+}
+
+// readSteamProcess reads a process's identity from procRoot. It returns nil
+// for processes that were not started by Steam for an app.
+func readSteamProcess(procRoot string, pid int) (*steamProcess, error) {
+	dir := filepath.Join(procRoot, strconv.Itoa(pid))
+
+	environData, err := os.ReadFile(filepath.Join(dir, "environ"))
+	if err != nil {
+		return nil, err
+	}
+	environ := parseNullSeparatedEnv(environData)
+
+	comm, _ := os.ReadFile(filepath.Join(dir, "comm"))
+	cmdlineData, _ := os.ReadFile(filepath.Join(dir, "cmdline"))
+	cmdline := strings.Split(strings.TrimRight(string(cmdlineData), "\x00"), "\x00")
+
+	proc := &steamProcess{
+		pid:     pid,
+		appID:   environ["SteamAppId"],
+		gameID:  shortcutAppID(environ["SteamGameId"]),
+		comm:    strings.TrimSpace(string(comm)),
+		cmdline: cmdline,
+		environ: environ,
+	}
+
+	if proc.appID == "" && proc.gameID == "" && proc.comm != "reaper" {
+		return nil, nil
+	}
+	return proc, nil
+}
+
+// parseNullSeparatedEnv parses the contents of /proc/<pid>/environ
+func parseNullSeparatedEnv(data []byte) map[string]string {
+	environ := make(map[string]string)
+	for _, entry := range strings.Split(string(data), "\x00") {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			environ[key] = value
+		}
+	}
+	return environ
+}
+
+// reaperAppID extracts the AppId=<id> argument from a reaper command line
+func reaperAppID(cmdline []string) string {
+	for _, arg := range cmdline {
+		if arg == "--" {
+			break
+		}
+		if value, ok := strings.CutPrefix(arg, "AppId="); ok {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// writeProc adds a process to a fake /proc
+func writeProc(t *testing.T, procRoot string, pid int, comm string, cmdline []string, environ map[string]string) {
+	t.Helper()
+	dir := filepath.Join(procRoot, strconv.Itoa(pid))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	var env strings.Builder
+	for key, value := range environ {
+		env.WriteString(key + "=" + value + "\x00")
+	}
+	files := map[string]string{
+		"comm":    comm + "\n",
+		"cmdline": strings.Join(cmdline, "\x00") + "\x00",
+		"environ": env.String(),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindRunningGameNative(t *testing.T) {
+	procRoot := t.TempDir()
+	writeProc(t, procRoot, 1, "systemd", []string{"/sbin/init"}, map[string]string{"HOME": "/root"})
+	writeProc(t, procRoot, 100, "reaper", []string{"reaper", "SteamLaunch", "AppId=620", "--", "portal2.sh"}, map[string]string{"SteamAppId": "620"})
+	writeProc(t, procRoot, 101, "sh", []string{"/bin/sh", "portal2.sh"}, map[string]string{"SteamAppId": "620"})
+	writeProc(t, procRoot, 102, "portal2_linux", []string{"./portal2_linux"}, map[string]string{"SteamAppId": "620", "SteamGameId": "620"})
+	// Another app's leftover process isn't part of the game
+	writeProc(t, procRoot, 200, "other", []string{"./other"}, map[string]string{"SteamAppId": "440"})
+
+	game, err := findRunningGame(procRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.AppID != "620" || game.PID != 102 || game.Proton {
+		t.Errorf("findRunningGame() = %+v, want app 620 with PID 102 without Proton", game)
+	}
+}
+
+func TestFindRunningGameProtonShortcut(t *testing.T) {
+	procRoot := t.TempDir()
+	// Non-Steam shortcuts have SteamAppId 0 and their 32-bit app ID in the
+	// upper half of SteamGameId
+	gameID := strconv.FormatUint(uint64(3000000000)<<32|0x02000000, 10)
+	writeProc(t, procRoot, 300, "wineserver", []string{"wineserver"}, map[string]string{"SteamAppId": "0", "SteamGameId": gameID})
+	writeProc(t, procRoot, 301, "Game.exe", []string{"Z:\\Game.exe"}, map[string]string{
+		"SteamAppId":              "0",
+		"SteamGameId":             gameID,
+		"STEAM_COMPAT_DATA_PATH":  "/home/deck/.steam/steam/steamapps/compatdata/3000000000",
+		"STEAM_COMPAT_TOOL_PATHS": "/home/deck/.steam/root/compatibilitytools.d/GE-Proton9-20:/usr/lib/sniper",
+		"MANGOHUD_CONFIG":         "output_folder=/home/deck/mangologs",
+	})
+
+	game, err := findRunningGame(procRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := runningGame{
+		AppID:       "3000000000",
+		PID:         301,
+		Proton:      true,
+		ToolPath:    "/home/deck/.steam/root/compatibilitytools.d/GE-Proton9-20",
+		MangoHudDir: "/home/deck/mangologs",
+	}
+	if *game != want {
+		t.Errorf("findRunningGame() = %+v, want %+v", *game, want)
+	}
+}
+
+func TestFindRunningGameWithoutGame(t *testing.T) {
+	procRoot := t.TempDir()
+	writeProc(t, procRoot, 1, "systemd", []string{"/sbin/init"}, map[string]string{"HOME": "/root"})
+	writeProc(t, procRoot, 50, "steam", []string{"steam"}, map[string]string{"SteamAppId": "0"})
+
+	if game, err := findRunningGame(procRoot); err == nil {
+		t.Errorf("findRunningGame() = %+v without a game running", game)
+	}
+}
@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// SensorCollector collects thermal, clock, power and battery metrics
+type SensorCollector struct {
+	sysRoot string
+}
+
+// NewSensorCollector creates a new sensor collector
+func NewSensorCollector() *SensorCollector {
+	return &SensorCollector{
+		sysRoot: "/sys",
+	}
+}
+
+// Collect gathers sensor statistics
+func (c *SensorCollector) Collect() (*metrics.SensorStats, error) {
+	stats := &metrics.SensorStats{
+		Timestamp: time.Now(),
+	}
+
+	// Temperatures may come back together with warnings for unreadable sensors
+	temps, _ := host.SensorsTemperatures()
+	for _, temp := range temps {
+		switch {
+		case isCPUSensor(temp.SensorKey):
+			if temp.Temperature > stats.CPUTemp {
+				stats.CPUTemp = temp.Temperature
+			}
+		case strings.HasPrefix(temp.SensorKey, "amdgpu"):
+			if temp.Temperature > stats.GPUTemp {
+				stats.GPUTemp = temp.Temperature
+			}
+		}
+	}
+
+	c.collectGPU(stats)
+	stats.CPUClock = c.averageCPUClock()
+	c.collectBattery(stats)
+
+	return stats, nil
+}
+
+// isCPUSensor reports whether a gopsutil sensor key belongs to the CPU
+func isCPUSensor(key string) bool {
+	return strings.HasPrefix(key, "k10temp") ||
+		strings.HasPrefix(key, "zenpower") ||
+		strings.HasPrefix(key, "coretemp")
+}
+
+// collectGPU reads APU power and GPU clock from the amdgpu hwmon device
+func (c *SensorCollector) collectGPU(stats *metrics.SensorStats) {
+	hwmons, _ := filepath.Glob(filepath.Join(c.sysRoot, "class", "hwmon", "hwmon*"))
+	for _, hwmon := range hwmons {
+		if readSysString(filepath.Join(hwmon, "name")) != "amdgpu" {
+			continue
+		}
+
+		// power1_average is reported in microwatts; newer kernels use power1_input
+		if power, ok := readSysFloat(filepath.Join(hwmon, "power1_average")); ok {
+			stats.APUPower = power / 1e6
+		} else if power, ok := readSysFloat(filepath.Join(hwmon, "power1_input")); ok {
+			stats.APUPower = power / 1e6
+		}
+
+		// freq1_input is the shader clock in Hz
+		if freq, ok := readSysFloat(filepath.Join(hwmon, "freq1_input")); ok {
+			stats.GPUClock = freq / 1e6
+		}
+		return
+	}
+}
+
+// averageCPUClock returns the mean current frequency of all cores in MHz
+func (c *SensorCollector) averageCPUClock() float64 {
+	freqFiles, _ := filepath.Glob(filepath.Join(c.sysRoot, "devices", "system", "cpu", "cpu[0-9]*", "cpufreq", "scaling_cur_freq"))
+
+	var total float64
+	var count int
+	for _, freqFile := range freqFiles {
+		// scaling_cur_freq is reported in kHz
+		if freq, ok := readSysFloat(freqFile); ok {
+			total += freq / 1000
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
+
+// collectBattery reads charge level and power draw of the first battery
+func (c *SensorCollector) collectBattery(stats *metrics.SensorStats) {
+	batteries, _ := filepath.Glob(filepath.Join(c.sysRoot, "class", "power_supply", "BAT*"))
+	if len(batteries) == 0 {
+		return
+	}
+	battery := batteries[0]
+
+	if capacity, ok := readSysFloat(filepath.Join(battery, "capacity")); ok {
+		stats.BatteryPercent = capacity
+		stats.HasBattery = true
+	}
+	stats.BatteryStatus = readSysString(filepath.Join(battery, "status"))
+
+	// power_now is in microwatts; some batteries only report current and voltage
+	if power, ok := readSysFloat(filepath.Join(battery, "power_now")); ok {
+		stats.BatteryPower = power / 1e6
+	} else {
+		current, okCurrent := readSysFloat(filepath.Join(battery, "current_now"))
+		voltage, okVoltage := readSysFloat(filepath.Join(battery, "voltage_now"))
+		if okCurrent && okVoltage {
+			stats.BatteryPower = current * voltage / 1e12
+		}
+	}
+}
+
+// readSysString reads a sysfs attribute as a trimmed string
+func readSysString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readSysFloat reads a numeric sysfs attribute
+func readSysFloat(path string) (float64, bool) {
+	value, err := strconv.ParseFloat(readSysString(path), 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
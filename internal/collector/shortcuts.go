@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"hash/crc32"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/internal/vdf"
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// shortcutAppIDFlag is set on every non-Steam shortcut's 32-bit app ID
+const shortcutAppIDFlag = 0x80000000
+
+// shortcutFile is the parsed shortcuts.vdf of one Steam user
+type shortcutFile struct {
+	modTime   time.Time
+	shortcuts []metrics.SteamShortcut
+}
+
+// shortcuts reads the non-Steam shortcuts of every Steam user and caches
+// each user's file until it changes
+type shortcuts struct {
+	steamDir string
+	files    map[string]*shortcutFile // by path
+}
+
+// newShortcuts creates a shortcut reader for the Steam installation in steamDir
+func newShortcuts(steamDir string) *shortcuts {
+	return &shortcuts{
+		steamDir: steamDir,
+		files:    make(map[string]*shortcutFile),
+	}
+}
+
+// all returns the shortcuts of all users, ordered by user and name
+func (s *shortcuts) all() []metrics.SteamShortcut {
+	paths, _ := filepath.Glob(filepath.Join(s.steamDir, "userdata", "*", "config", "shortcuts.vdf"))
+
+	var all []metrics.SteamShortcut
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		seen[path] = true
+
+		file, cached := s.files[path]
+		if !cached || !file.modTime.Equal(info.ModTime()) {
+			userID := filepath.Base(filepath.Dir(filepath.Dir(path)))
+			parsed, err := readShortcuts(path, userID)
+			if err != nil {
+				continue
+			}
+			file = &shortcutFile{modTime: info.ModTime(), shortcuts: parsed}
+			s.files[path] = file
+		}
+		all = append(all, file.shortcuts...)
+	}
+
+	for path := range s.files {
+		if !seen[path] {
+			delete(s.files, path)
+		}
+	}
+	return all
+}
+
+// find returns the shortcut with the given app ID, or nil
+func (s *shortcuts) find(appID string) *metrics.SteamShortcut {
+	for _, shortcut := range s.all() {
+		if shortcut.AppID == appID {
+			return &shortcut
+		}
+	}
+	return nil
+}
+
+// readShortcuts parses one user's shortcuts.vdf
+func readShortcuts(path, userID string) ([]metrics.SteamShortcut, error) {
+	root, err := vdf.ParseBinaryFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	list := root.Get("shortcuts")
+	if list == nil {
+		return nil, nil
+	}
+
+	var result []metrics.SteamShortcut
+	for _, entry := range list.Children {
+		shortcut := metrics.SteamShortcut{
+			UserID:        userID,
+			Name:          entry.GetString("AppName"),
+			Exe:           unquote(entry.GetString("Exe")),
+			StartDir:      unquote(entry.GetString("StartDir")),
+			LaunchOptions: entry.GetString("LaunchOptions"),
+		}
+		// The ID is stored as a signed 32-bit integer
+		if value, err := strconv.ParseInt(entry.GetString("appid"), 10, 64); err == nil && value != 0 {
+			shortcut.AppID = strconv.FormatUint(uint64(uint32(value)), 10)
+		}
+		if shortcut.AppID == "" {
+			// Older clients didn't store the ID; it is derived from the
+			// quoted executable and the name
+			shortcut.AppID = legacyShortcutAppID(entry.GetString("Exe"), shortcut.Name)
+		}
+		result = append(result, shortcut)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+	})
+	return result, nil
+}
+
+// legacyShortcutAppID computes the app ID older Steam clients assigned to a
+// shortcut
+func legacyShortcutAppID(exe, name string) string {
+	id := crc32.ChecksumIEEE([]byte(exe+name)) | shortcutAppIDFlag
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// shortcutAppID reduces the 64-bit game ID Steam uses for a running
+// shortcut, e.g. in SteamGameId, to the shortcut's 32-bit app ID. Other IDs
+// are returned unchanged.
+func shortcutAppID(id string) string {
+	value, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || value <= math.MaxUint32 {
+		return id
+	}
+	return strconv.FormatUint(value>>32, 10)
+}
+
+// unquote strips the quotes Steam puts around executable paths
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadShortcutsAppIDs(t *testing.T) {
+	var doc bytes.Buffer
+	key := func(kind byte, name string) {
+		doc.WriteByte(kind)
+		doc.WriteString(name + "\x00")
+	}
+	str := func(name, value string) {
+		key(0x01, name)
+		doc.WriteString(value + "\x00")
+	}
+	key(0x00, "shortcuts")
+	key(0x00, "0")
+	key(0x02, "appid")
+	binary.Write(&doc, binary.LittleEndian, int32(-1294967296))
+	str("AppName", "RetroArch")
+	str("Exe", `"/usr/bin/retroarch"`)
+	doc.WriteByte(0x08)
+	key(0x00, "1")
+	// Older clients left the ID out
+	str("AppName", "Heroic")
+	str("Exe", `"/usr/bin/heroic"`)
+	doc.WriteByte(0x08)
+	doc.WriteString("\x08\x08")
+
+	path := filepath.Join(t.TempDir(), "shortcuts.vdf")
+	if err := os.WriteFile(path, doc.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	shortcuts, err := readShortcuts(path, "12345")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shortcuts) != 2 {
+		t.Fatalf("readShortcuts() = %+v, want 2 shortcuts", shortcuts)
+	}
+
+	// Sorted by name
+	heroic, retroArch := shortcuts[0], shortcuts[1]
+	if retroArch.AppID != "3000000000" || retroArch.Exe != "/usr/bin/retroarch" {
+		t.Errorf("RetroArch app ID %q, exe %q, want 3000000000, /usr/bin/retroarch", retroArch.AppID, retroArch.Exe)
+	}
+	if want := legacyShortcutAppID(`"/usr/bin/heroic"`, "Heroic"); heroic.AppID != want {
+		t.Errorf("Heroic app ID %q, want %q", heroic.AppID, want)
+	}
+}
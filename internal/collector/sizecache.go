@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// dirSizeQueueLength bounds how many directory scans can be waiting
+const dirSizeQueueLength = 256
+
+// dirSizeCache computes directory sizes in the background and caches them.
+// A cached size is rescanned when the caller's version for it changes (e.g.
+// the mtime of the app's manifest) or when it gets older than the maxAge
+// given by the caller. Scans run one at a time so the monitor never competes
+// with games for disk I/O more than necessary.
+type dirSizeCache struct {
+	mu      sync.Mutex
+	entries map[string]*dirSizeEntry
+	queue   chan dirSizeRequest
+	once    sync.Once
+	pending sync.WaitGroup
+}
+
+type dirSizeEntry struct {
+	size      uint64
+	version   time.Time
+	scannedAt time.Time
+	valid     bool
+	scanning  bool
+}
+
+type dirSizeRequest struct {
+	dir     string
+	version time.Time
+}
+
+// newDirSizeCache creates an empty directory size cache
+func newDirSizeCache() *dirSizeCache {
+	return &dirSizeCache{
+		entries: make(map[string]*dirSizeEntry),
+		queue:   make(chan dirSizeRequest, dirSizeQueueLength),
+	}
+}
+
+// Get returns the last known size of dir and whether one is known. If the
+// size is missing, its version changed or it is older than maxAge (zero
+// means no limit), a background rescan is scheduled and the stale size, if
+// any, is returned meanwhile.
+func (c *dirSizeCache) Get(dir string, version time.Time, maxAge time.Duration) (uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, exists := c.entries[dir]
+	if !exists {
+		entry = &dirSizeEntry{}
+		c.entries[dir] = entry
+	}
+
+	stale := !entry.valid || !entry.version.Equal(version) ||
+		(maxAge > 0 && time.Since(entry.scannedAt) > maxAge)
+	if stale && !entry.scanning {
+		select {
+		case c.queue <- dirSizeRequest{dir: dir, version: version}:
+			entry.scanning = true
+			c.pending.Add(1)
+			c.once.Do(func() { go c.worker() })
+		default:
+			// Queue full, try again on the next collection
+		}
+	}
+
+	return entry.size, entry.valid
+}
+
+// worker scans queued directories one at a time
+func (c *dirSizeCache) worker() {
+	for request := range c.queue {
+		size := dirSize(request.dir)
+
+		c.mu.Lock()
+		entry := c.entries[request.dir]
+		entry.size = size
+		entry.version = request.version
+		entry.scannedAt = time.Now()
+		entry.valid = true
+		entry.scanning = false
+		c.mu.Unlock()
+		c.pending.Done()
+	}
+}
+
+// Wait blocks until all scheduled scans have finished
+func (c *dirSizeCache) Wait() {
+	c.pending.Wait()
+}
+
+// dirSize returns the total size of the regular files below dir
+func dirSize(dir string) uint64 {
+	var total uint64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += uint64(info.Size())
+			}
+		}
+		return nil
+	})
+	return total
+}
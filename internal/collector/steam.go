@@ -3,45 +3,108 @@ package collector
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strconv"
-	"strings"
+	"reflect"
 	"time"
 
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/steam-os-monitor/monitor/internal/vdf"
 	"github.com/steam-os-monitor/monitor/pkg/metrics"
 )
 
 // SteamCollector collects Steam-specific metrics
 type SteamCollector struct {
-	steamDir string
+	steamDir     string
+	contentLog   *contentLog
+	lastProgress map[string]downloadProgress
+	sizes        *dirSizeCache
+	libraries    *libraryFolders
+	manifests    map[string]*appManifest // parsed appmanifests by path
+	compatTools  *compatTools
+	shortcuts    *shortcuts
+	playtime     *localPlaytime
+
+	inventory        *metrics.SteamInventory // of the last Collect
+	inventoryChanged bool
+}
+
+// downloadProgress is an app's transfer counters at one point in time
+type downloadProgress struct {
+	downloaded uint64
+	staged     uint64
+	at         time.Time
 }
 
 // NewSteamCollector creates a new Steam collector
 func NewSteamCollector() *SteamCollector {
+	return newSteamCollector(defaultSteamDir())
+}
+
+// newSteamCollector creates a Steam collector reading the installation in
+// steamDir
+func newSteamCollector(steamDir string) *SteamCollector {
+	return &SteamCollector{
+		steamDir:     steamDir,
+		contentLog:   newContentLog(filepath.Join(steamDir, "logs", "content_log.txt")),
+		lastProgress: make(map[string]downloadProgress),
+		sizes:        newDirSizeCache(),
+		libraries:    newLibraryFolders(steamDir),
+		manifests:    make(map[string]*appManifest),
+		compatTools:  newCompatTools(steamDir),
+		shortcuts:    newShortcuts(steamDir),
+		playtime:     newLocalPlaytime(steamDir),
+	}
+}
+
+// defaultSteamDir returns the Steam installation directory of the current user
+func defaultSteamDir() string {
 	steamDir := os.Getenv("HOME")
 	if steamDir == "" {
 		steamDir = "/home/deck"
 	}
-	steamDir = filepath.Join(steamDir, ".steam", "steam")
+	return filepath.Join(steamDir, ".steam", "steam")
+}
 
-	return &SteamCollector{
-		steamDir: steamDir,
+// SteamUserID returns the 64-bit Steam ID of the account that logged in
+// most recently, as recorded in config/loginusers.vdf
+func SteamUserID() (string, error) {
+	root, err := vdf.ParseFile(filepath.Join(defaultSteamDir(), "config", "loginusers.vdf"))
+	if err != nil {
+		return "", err
+	}
+	users := root.Get("users")
+	if users == nil || len(users.Children) == 0 {
+		return "", fmt.Errorf("no Steam users have logged in")
+	}
+	for _, user := range users.Children {
+		if user.GetString("MostRecent") == "1" {
+			return user.Key, nil
+		}
+	}
+	return users.Children[0].Key, nil
+}
+
+// appManifestName reads the name of an installed app from its appmanifest
+// in whichever library folder holds it
+func appManifestName(steamDir, appID string) (string, error) {
+	manifestName := fmt.Sprintf("appmanifest_%s.acf", appID)
+	for _, library := range steamLibraries(steamDir) {
+		manifest, err := readAppManifest(filepath.Join(library, "steamapps", manifestName))
+		if err == nil && manifest.Name != "" {
+			return manifest.Name, nil
+		}
 	}
+	return "", fmt.Errorf("no appmanifest with a name for app %s", appID)
 }
 
-// Collect gathers Steam statistics
+// Collect gathers Steam statistics. The per-app state read along the way
+// is returned by Inventory.
 func (c *SteamCollector) Collect() (*metrics.SteamStats, error) {
 	stats := &metrics.SteamStats{
 		DownloadProgress: make(map[string]float64),
 		Timestamp:        time.Now(),
 	}
-
-	// Get download speed from Steam
-	downloadSpeed, err := c.getDownloadSpeed()
-	if err == nil {
-		stats.DownloadSpeed = downloadSpeed
-	}
+	inventory := &metrics.SteamInventory{Timestamp: stats.Timestamp}
 
 	// Get upload speed
 	uploadSpeed, err := c.getUploadSpeed()
@@ -49,120 +112,216 @@ func (c *SteamCollector) Collect() (*metrics.SteamStats, error) {
 		stats.UploadSpeed = uploadSpeed
 	}
 
-	// Get active downloads count
-	activeDownloads, err := c.getActiveDownloads()
-	if err == nil {
-		stats.ActiveDownloads = activeDownloads
-	}
+	// Get per-library and per-app state, compatibility tools, library size and leftover
+	// prefixes and shader caches from the appmanifests
+	c.compatTools.refresh()
+	c.libraries.refresh()
+	c.collectLibraries(stats, inventory, c.libraries.libraries)
+	sortStorage(inventory.Storage)
+	sortOrphans(inventory.Orphaned)
 
-	// Get library size
-	librarySize, installedGames, err := c.getLibraryInfo()
-	if err == nil {
-		stats.LibrarySize = librarySize
-		stats.InstalledGames = installedGames
-	}
+	// Get non-Steam games from the users' shortcuts
+	inventory.Shortcuts = c.shortcuts.all()
 
-	// Get download progress for active downloads
-	progress, err := c.getDownloadProgress()
-	if err == nil {
-		stats.DownloadProgress = progress
-	}
+	// Get playtime Steam recorded locally
+	inventory.Playtime = c.playtime.all()
+
+	// Get update jobs and transfer rates
+	c.collectDownloads(stats, inventory)
+
+	c.inventoryChanged = c.inventory == nil || !sameInventory(c.inventory, inventory)
+	c.inventory = inventory
 
 	return stats, nil
 }
 
-// getDownloadSpeed attempts to get Steam download speed
-func (c *SteamCollector) getDownloadSpeed() (float64, error) {
-	// Try to read from Steam's download stats
-	// This is a placeholder - actual implementation would query Steam API or read Steam logs
-
-	// Check for Steam download stats file
-	downloadStatsFile := filepath.Join(c.steamDir, "logs", "download_stats.txt")
-	if data, err := os.ReadFile(downloadStatsFile); err == nil {
-		lines := strings.Split(string(data), "\n")
-		for _, line := range lines {
-			if strings.Contains(line, "speed") || strings.Contains(line, "bytes/sec") {
-				parts := strings.Fields(line)
-				for i, part := range parts {
-					if strings.Contains(part, "bytes/sec") || strings.Contains(part, "B/s") {
-						if i > 0 {
-							if speed, err := strconv.ParseFloat(parts[i-1], 64); err == nil {
-								return speed, nil
-							}
-						}
-					}
-				}
-			}
-		}
-	}
+// Inventory returns the per-app state read by the last Collect, and
+// whether it differs from that of the Collect before
+func (c *SteamCollector) Inventory() (*metrics.SteamInventory, bool) {
+	return c.inventory, c.inventoryChanged
+}
 
-	// Try to get from network interface if Steam is downloading
-	// This is a simplified approach
-	return 0, fmt.Errorf("could not get download speed")
+// sameInventory reports whether two inventories hold the same state. The
+// transfer counters of downloading apps move with every collection and
+// are left out; SteamStats carries the download progress.
+func sameInventory(a, b *metrics.SteamInventory) bool {
+	x, y := *a, *b
+	x.Timestamp, y.Timestamp = time.Time{}, time.Time{}
+	x.Apps, y.Apps = withoutTransfers(x.Apps), withoutTransfers(y.Apps)
+	return reflect.DeepEqual(x, y)
 }
 
-// getUploadSpeed attempts to get Steam upload speed
-func (c *SteamCollector) getUploadSpeed() (float64, error) {
-	// Similar to download speed
-	return 0, fmt.Errorf("could not get upload speed")
+func withoutTransfers(apps []metrics.SteamAppStats) []metrics.SteamAppStats {
+	stripped := make([]metrics.SteamAppStats, len(apps))
+	for i, app := range apps {
+		app.BytesDownloaded, app.BytesStaged = 0, 0
+		stripped[i] = app
+	}
+	return stripped
 }
 
-// getActiveDownloads gets the count of active downloads
-func (c *SteamCollector) getActiveDownloads() (int, error) {
-	// Try to query Steam process or API
-	cmd := exec.Command("ps", "aux")
-	output, err := cmd.Output()
-	if err != nil {
-		return 0, err
+// collectDownloads follows update jobs in the content log. Transfer rates
+// come from the log's rate lines, or from the appmanifest byte counters of
+// updating apps when Steam hasn't logged a recent rate.
+func (c *SteamCollector) collectDownloads(stats *metrics.SteamStats, inventory *metrics.SteamInventory) {
+	logRead := c.contentLog.update() == nil
+	if logRead {
+		stats.ActiveDownloads = c.contentLog.activeCount()
+		stats.UpdatingAppID = c.contentLog.activeApp()
+		stats.UpdateQueue = c.contentLog.queue()
 	}
 
-	count := 0
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "steam") && strings.Contains(line, "download") {
-			count++
+	manifestDownload, manifestDisk := c.manifestRates(stats.Timestamp, inventory.Apps)
+
+	if download, disk, fresh := c.contentLog.rates(stats.Timestamp); logRead && fresh {
+		stats.DownloadSpeed = download
+		stats.DiskWriteSpeed = disk
+		if disk == 0 {
+			stats.DiskWriteSpeed = manifestDisk
 		}
+	} else {
+		stats.DownloadSpeed = manifestDownload
+		stats.DiskWriteSpeed = manifestDisk
 	}
+}
+
+// manifestRates derives download and disk write rates from how far the
+// BytesDownloaded and BytesStaged counters of updating apps moved since the
+// previous collection
+func (c *SteamCollector) manifestRates(now time.Time, apps []metrics.SteamAppStats) (float64, float64) {
+	var download, disk float64
+	current := make(map[string]downloadProgress)
 
-	return count, nil
+	for _, app := range apps {
+		if app.BytesToDownload == 0 && app.BytesToStage == 0 {
+			continue
+		}
+		progress := downloadProgress{
+			downloaded: app.BytesDownloaded,
+			staged:     app.BytesStaged,
+			at:         now,
+		}
+		current[app.AppID] = progress
+
+		last, exists := c.lastProgress[app.AppID]
+		elapsed := progress.at.Sub(last.at).Seconds()
+		if !exists || elapsed <= 0 {
+			continue
+		}
+		if progress.downloaded > last.downloaded {
+			download += float64(progress.downloaded-last.downloaded) / elapsed
+		}
+		if progress.staged > last.staged {
+			disk += float64(progress.staged-last.staged) / elapsed
+		}
+	}
+
+	c.lastProgress = current
+	return download, disk
 }
 
-// getLibraryInfo gets library size and installed games count
-func (c *SteamCollector) getLibraryInfo() (uint64, int, error) {
-	libraryPath := filepath.Join(c.steamDir, "steamapps", "common")
-	
-	var totalSize uint64
-	var gameCount int
+// collectLibraries reads the appmanifests of every reachable library folder
+// and fills the per-library breakdown. Leftovers of uninstalled apps are
+// only looked for when every library could be read.
+func (c *SteamCollector) collectLibraries(stats *metrics.SteamStats, inventory *metrics.SteamInventory, libraries []string) {
+	partitions, _ := disk.Partitions(false)
+	known := make(map[string]bool)
+	var reachable []string
 
-	err := filepath.Walk(libraryPath, func(path string, info os.FileInfo, err error) error {
+	for _, library := range libraries {
+		if !libraryExists(library) {
+			inventory.Unreachable = append(inventory.Unreachable, library)
+			continue
+		}
+		manifests, err := readAppManifests(filepath.Join(library, "steamapps"), c.manifests)
 		if err != nil {
-			return nil // Skip errors
+			inventory.Unreachable = append(inventory.Unreachable, library)
+			continue
+		}
+		reachable = append(reachable, library)
+		for _, m := range manifests {
+			known[m.AppID] = true
+		}
+
+		libraryStats := metrics.SteamLibraryStats{
+			Path: library,
+		}
+		if partition := findPartition(library, partitions); partition != nil {
+			libraryStats.Device = partition.Device
+			libraryStats.MountPoint = partition.Mountpoint
+		}
+		if usage, err := disk.Usage(library); err == nil {
+			libraryStats.Free = usage.Free
+			libraryStats.Total = usage.Total
 		}
-		if info.IsDir() {
-			// Count game directories
-			parent := filepath.Dir(path)
-			if parent == libraryPath {
-				gameCount++
+		for _, m := range manifests {
+			libraryStats.Size += c.appSize(library, m)
+			if m.Installed() && !m.IsTool() {
+				libraryStats.GameCount++
 			}
-		} else {
-			totalSize += uint64(info.Size())
+			inventory.Storage = append(inventory.Storage, c.appStorage(library, m))
 		}
-		return nil
-	})
 
-	if err != nil {
-		return 0, 0, err
+		stats.LibrarySize += libraryStats.Size
+		stats.Libraries = append(stats.Libraries, libraryStats)
+		c.applyManifests(stats, inventory, library, manifests)
 	}
 
-	return totalSize, gameCount, nil
+	if len(inventory.Unreachable) == 0 {
+		c.collectOrphans(stats, inventory, reachable, known)
+	}
+}
+
+// applyManifests fills the per-app stats, installed game count and download
+// progress from the parsed appmanifests of one library, along with the
+// compatibility tool selected for each app
+func (c *SteamCollector) applyManifests(stats *metrics.SteamStats, inventory *metrics.SteamInventory, library string, manifests []*appManifest) {
+	for _, m := range manifests {
+		app := metrics.SteamAppStats{
+			AppID:           m.AppID,
+			LibraryPath:     library,
+			Name:            m.Name,
+			InstallDir:      m.InstallDir,
+			SizeOnDisk:      m.SizeOnDisk,
+			StateFlags:      m.StateFlags,
+			State:           m.StateNames(),
+			BytesDownloaded: m.BytesDownloaded,
+			BytesToDownload: m.BytesToDownload,
+			BytesStaged:     m.BytesStaged,
+			BytesToStage:    m.BytesToStage,
+		}
+		if tool := c.compatTools.forApp(m.AppID, false); tool != nil {
+			app.CompatTool = tool.DisplayName
+			app.CompatToolVersion = tool.Version
+		}
+		inventory.Apps = append(inventory.Apps, app)
+
+		if m.Installed() && !m.IsTool() {
+			stats.InstalledGames++
+		}
+		if m.Updating() && m.BytesToDownload > 0 {
+			stats.DownloadProgress[m.AppID] = m.DownloadPercent()
+		}
+	}
 }
 
-// getDownloadProgress gets download progress for active downloads
-func (c *SteamCollector) getDownloadProgress() (map[string]float64, error) {
-	progress := make(map[string]float64)
-	
-	// This would require Steam API integration
-	// For now, return empty map
-	return progress, nil
+// getUploadSpeed attempts to get Steam upload speed
+func (c *SteamCollector) getUploadSpeed() (float64, error) {
+	// Similar to download speed
+	return 0, fmt.Errorf("could not get upload speed")
 }
 
+// appSize returns the disk usage of an app's install directory. Steam keeps
+// SizeOnDisk in the manifest; only when it is missing is the directory
+// scanned, in the background and again only once the manifest changes.
+func (c *SteamCollector) appSize(library string, m *appManifest) uint64 {
+	if m.SizeOnDisk > 0 {
+		return m.SizeOnDisk
+	}
+	if m.InstallDir == "" {
+		return 0
+	}
+
+	size, _ := c.sizes.Get(filepath.Join(library, "steamapps", "common", m.InstallDir), m.ModTime, 0)
+	return size
+}
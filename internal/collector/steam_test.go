@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeAppManifest(t *testing.T, steamDir, name string, downloaded int, modTime time.Time) {
+	t.Helper()
+	path := filepath.Join(steamDir, "steamapps", "appmanifest_620.acf")
+	manifest := fmt.Sprintf(`"AppState"
+{
+	"appid"		"620"
+	"name"		"%s"
+	"installdir"		"Portal 2"
+	"StateFlags"		"1026"
+	"SizeOnDisk"		"1000"
+	"BytesToDownload"		"500"
+	"BytesDownloaded"		"%d"
+}
+`, name, downloaded)
+	if err := os.WriteFile(path, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSteamInventoryChanged(t *testing.T) {
+	steamDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(steamDir, "steamapps"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-time.Hour)
+	writeAppManifest(t, steamDir, "Portal 2", 100, modTime)
+	c := newSteamCollector(steamDir)
+
+	collect := func(step string, wantChanged bool) {
+		t.Helper()
+		if _, err := c.Collect(); err != nil {
+			t.Fatal(err)
+		}
+		inventory, changed := c.Inventory()
+		if inventory == nil || len(inventory.Apps) != 1 {
+			t.Fatalf("%s: inventory %+v, want one app", step, inventory)
+		}
+		if changed != wantChanged {
+			t.Errorf("%s: changed = %v, want %v", step, changed, wantChanged)
+		}
+	}
+
+	collect("first collection", true)
+	collect("unchanged", false)
+
+	// Download progress alone isn't a change of the inventory
+	modTime = modTime.Add(time.Second)
+	writeAppManifest(t, steamDir, "Portal 2", 200, modTime)
+	collect("download progress", false)
+
+	modTime = modTime.Add(time.Second)
+	writeAppManifest(t, steamDir, "Portal 2: Remastered", 200, modTime)
+	collect("renamed", true)
+	collect("unchanged after rename", false)
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// storageMaxAge is how long a measured shader cache, prefix or workshop
+// directory size is trusted before it is rescanned in the background. They
+// grow while a game runs without its manifest changing, but checking them
+// for changes on every collection would cost more I/O than the breakdown is
+// worth.
+const storageMaxAge = 10 * time.Minute
+
+// orphanKinds are the per-app directories below steamapps that outlive an
+// uninstall
+var orphanKinds = []string{"compatdata", "shadercache"}
+
+// appStorage attributes the disk usage of an app across its install
+// directory, shader cache, Proton prefix and workshop content
+func (c *SteamCollector) appStorage(library string, m *appManifest) metrics.AppStorage {
+	steamapps := filepath.Join(library, "steamapps")
+
+	storage := metrics.AppStorage{
+		AppID:            m.AppID,
+		Name:             m.Name,
+		LibraryPath:      library,
+		InstallBytes:     c.appSize(library, m),
+		ShaderCacheBytes: c.cachedDirSize(filepath.Join(steamapps, "shadercache", m.AppID), m.ModTime),
+		CompatDataBytes:  c.cachedDirSize(filepath.Join(steamapps, "compatdata", m.AppID), m.ModTime),
+		WorkshopBytes:    c.cachedDirSize(filepath.Join(steamapps, "workshop", "content", m.AppID), m.ModTime),
+	}
+	storage.TotalBytes = storage.InstallBytes + storage.ShaderCacheBytes +
+		storage.CompatDataBytes + storage.WorkshopBytes
+
+	return storage
+}
+
+// cachedDirSize returns the background-measured size of dir, or 0 if it
+// doesn't exist or hasn't been measured yet. It is measured again once the
+// app's manifest changes, e.g. with an update or launch, or the size is
+// older than storageMaxAge. A zero manifestTime is for directories without
+// an app.
+func (c *SteamCollector) cachedDirSize(dir string, manifestTime time.Time) uint64 {
+	size, _ := c.sizes.Get(dir, manifestTime, storageMaxAge)
+	return size
+}
+
+// sortStorage orders the storage breakdown by total footprint, largest first
+func sortStorage(storage []metrics.AppStorage) {
+	sort.SliceStable(storage, func(i, j int) bool {
+		return storage[i].TotalBytes > storage[j].TotalBytes
+	})
+}
+
+// collectOrphans reports compatdata and shadercache directories in the
+// libraries whose app has no appmanifest in any of them. Apps are matched
+// across libraries since a prefix doesn't always live next to the game, so
+// known must hold the apps of every configured library.
+func (c *SteamCollector) collectOrphans(stats *metrics.SteamStats, inventory *metrics.SteamInventory, libraries []string, known map[string]bool) {
+	for _, library := range libraries {
+		for _, kind := range orphanKinds {
+			dir := filepath.Join(library, "steamapps", kind)
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				appID := entry.Name()
+				if !entry.IsDir() || known[appID] || !orphanCandidate(appID) {
+					continue
+				}
+				path := filepath.Join(dir, appID)
+				orphan := metrics.OrphanedStorage{
+					Kind:        kind,
+					AppID:       appID,
+					LibraryPath: library,
+					Path:        path,
+					Bytes:       c.cachedDirSize(path, time.Time{}),
+				}
+				stats.OrphanedBytes += orphan.Bytes
+				inventory.Orphaned = append(inventory.Orphaned, orphan)
+			}
+		}
+	}
+}
+
+// orphanCandidate reports whether a compatdata or shadercache entry belongs
+// to a Steam app that should have an appmanifest. Prefix 0 is Steam's own
+// and non-Steam shortcuts never have a manifest.
+func orphanCandidate(appID string) bool {
+	id, err := strconv.ParseUint(appID, 10, 64)
+	return err == nil && id != 0 && id < shortcutAppIDFlag
+}
+
+// sortOrphans orders leftover directories by size, largest first
+func sortOrphans(orphans []metrics.OrphanedStorage) {
+	sort.SliceStable(orphans, func(i, j int) bool {
+		return orphans[i].Bytes > orphans[j].Bytes
+	})
+}
+
+// WaitForSizes blocks until the directory sizes scheduled by previous
+// collections have been measured, so a following Collect reports complete
+// storage figures. Used by one-shot reports rather than the live UI.
+func (c *SteamCollector) WaitForSizes() {
+	c.sizes.Wait()
+}
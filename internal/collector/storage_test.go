@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDirSizeCacheMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "fozpipelinesv6", "cache.foz"), "1234")
+	cache := newDirSizeCache()
+	version := time.Now()
+
+	if _, ok := cache.Get(dir, version, time.Hour); ok {
+		t.Error("Get() of an unmeasured directory reported a size")
+	}
+	cache.Wait()
+	if size, ok := cache.Get(dir, version, time.Hour); !ok || size != 4 {
+		t.Fatalf("Get() = %d, %v, want 4", size, ok)
+	}
+
+	// Shader caches grow without their directory or the manifest changing
+	writeFile(t, filepath.Join(dir, "fozpipelinesv6", "more.foz"), "5678")
+	cache.Get(dir, version, time.Hour)
+	cache.Wait()
+	if size, _ := cache.Get(dir, version, time.Hour); size != 4 {
+		t.Errorf("Get() within maxAge = %d, want the cached 4", size)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if size, _ := cache.Get(dir, version, time.Millisecond); size != 4 {
+		t.Errorf("Get() past maxAge = %d, want the stale 4 until rescanned", size)
+	}
+	cache.Wait()
+	if size, _ := cache.Get(dir, version, time.Hour); size != 8 {
+		t.Errorf("Get() after the rescan = %d, want 8", size)
+	}
+
+	// A new manifest version rescans regardless of age
+	if err := os.Remove(filepath.Join(dir, "fozpipelinesv6", "more.foz")); err != nil {
+		t.Fatal(err)
+	}
+	cache.Get(dir, version.Add(time.Second), time.Hour)
+	cache.Wait()
+	if size, _ := cache.Get(dir, version.Add(time.Second), time.Hour); size != 4 {
+		t.Errorf("Get() after a version change = %d, want 4", size)
+	}
+}
+
+func TestOrphansWithUnreachableLibrary(t *testing.T) {
+	root := t.TempDir()
+	steamDir := filepath.Join(root, "Steam")
+	sdCard := filepath.Join(root, "mmcblk0p1")
+	writeFile(t, filepath.Join(steamDir, "steamapps", "libraryfolders.vdf"), `"libraryfolders"
+{
+	"0" { "path" "`+steamDir+`" }
+	"1" { "path" "`+sdCard+`" }
+}
+`)
+	// The game is installed on the card, its prefix is on internal storage
+	writeFile(t, filepath.Join(steamDir, "steamapps", "compatdata", "1245620", "pfx", "user.reg"), "x")
+	writeFile(t, filepath.Join(steamDir, "steamapps", "shadercache", "570", "fozpipelinesv6", "cache.foz"), "x")
+	c := newSteamCollector(steamDir)
+
+	collect := func() ([]string, []string) {
+		t.Helper()
+		if _, err := c.Collect(); err != nil {
+			t.Fatal(err)
+		}
+		inventory, _ := c.Inventory()
+		var orphans []string
+		for _, orphan := range inventory.Orphaned {
+			orphans = append(orphans, orphan.AppID)
+		}
+		return orphans, inventory.Unreachable
+	}
+
+	orphans, unreachable := collect()
+	if len(orphans) != 0 || len(unreachable) != 1 || unreachable[0] != sdCard {
+		t.Errorf("with the card removed: orphans %q, unreachable %q, want none and %q", orphans, unreachable, sdCard)
+	}
+
+	writeFile(t, filepath.Join(sdCard, "steamapps", "appmanifest_1245620.acf"), `"AppState"
+{
+	"appid"		"1245620"
+	"name"		"ELDEN RING"
+	"installdir"		"ELDEN RING"
+	"StateFlags"		"4"
+	"SizeOnDisk"		"1000"
+}
+`)
+	orphans, unreachable = collect()
+	if len(orphans) != 1 || orphans[0] != "570" || len(unreachable) != 0 {
+		t.Errorf("with the card inserted: orphans %q, unreachable %q, want [570] and none", orphans, unreachable)
+	}
+}
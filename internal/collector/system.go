@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// Marketing names of the Steam Deck models by DMI product name
+var deckModels = map[string]string{
+	"Jupiter": "Steam Deck LCD",
+	"Galileo": "Steam Deck OLED",
+}
+
+// ReadSystemInfo identifies the device from DMI and the OS from
+// /etc/os-release. Fields that can't be read are left empty.
+func ReadSystemInfo() *metrics.SystemInfo {
+	return readSystemInfo("/sys", "/etc/os-release")
+}
+
+func readSystemInfo(sysRoot, osRelease string) *metrics.SystemInfo {
+	info := &metrics.SystemInfo{}
+	info.Host, _ = os.Hostname()
+
+	dmi := filepath.Join(sysRoot, "devices", "virtual", "dmi", "id")
+	info.Vendor = readSysString(filepath.Join(dmi, "sys_vendor"))
+	info.Model = readSysString(filepath.Join(dmi, "product_name"))
+	info.ModelName = info.Model
+	if name, ok := deckModels[info.Model]; ok {
+		info.ModelName = name
+	}
+
+	release := readOSRelease(osRelease)
+	info.OSName = release["NAME"]
+	info.OSVersion = release["VERSION_ID"]
+	info.OSBuildID = release["BUILD_ID"]
+	return info
+}
+
+// readOSRelease parses the KEY=value lines of an os-release file
+func readOSRelease(path string) map[string]string {
+	values := make(map[string]string)
+	file, err := os.Open(path)
+	if err != nil {
+		return values
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		values[key] = strings.Trim(value, `"'`)
+	}
+	return values
+}
@@ -2,30 +2,138 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the application configuration
 type Config struct {
-	RefreshRate int      `yaml:"refresh_rate"` // milliseconds
-	LogDir      string   `yaml:"log_dir"`
-	LogFormat   string   `yaml:"log_format"` // "json" or "csv"
-	Widgets     Widgets `yaml:"widgets"`
-	Theme       Theme    `yaml:"theme"`
-	Steam       Steam    `yaml:"steam"`
+	RefreshRate int         `yaml:"refresh_rate"` // milliseconds
+	LogDir      string      `yaml:"log_dir"`
+	LogFormat   string      `yaml:"log_format"` // "json", "csv" or "influx"
+	LogRotation LogRotation `yaml:"log_rotation"`
+	Sinks       []Sink      `yaml:"sinks,omitempty"` // defaults to a single file sink
+	History     History     `yaml:"history"`
+	Exporter    Exporter    `yaml:"exporter"`
+	Widgets     Widgets     `yaml:"widgets"`
+	Theme       Theme       `yaml:"theme"`
+	Steam       Steam       `yaml:"steam"`
 }
 
 // Widgets configuration
 type Widgets struct {
-	ShowCPU     bool `yaml:"show_cpu"`
-	ShowMemory  bool `yaml:"show_memory"`
-	ShowDisk    bool `yaml:"show_disk"`
-	ShowNetwork bool `yaml:"show_network"`
-	ShowGame    bool `yaml:"show_game"`
-	ShowSteam   bool `yaml:"show_steam"`
+	ShowCPU      bool `yaml:"show_cpu"`
+	ShowMemory   bool `yaml:"show_memory"`
+	ShowDisk     bool `yaml:"show_disk"`
+	ShowNetwork  bool `yaml:"show_network"`
+	ShowGame     bool `yaml:"show_game"`
+	ShowSteam    bool `yaml:"show_steam"`
+	ShowStorage  bool `yaml:"show_storage"`
+	ShowPlaytime bool `yaml:"show_playtime"`
+}
+
+// LogRotation configures rotation, compression and retention of the metric
+// logs. PerMetric overrides individual settings for one metric type, e.g.
+// "disk"; fields left at zero fall back to the general setting.
+type LogRotation struct {
+	RotationPolicy `yaml:",inline"`
+	PerMetric      map[string]RotationPolicy `yaml:"per_metric"`
+}
+
+// RotationPolicy configuration. A negative limit disables it.
+type RotationPolicy struct {
+	MaxSizeMB     int    `yaml:"max_size_mb"`    // rotate once a log reaches this size
+	MaxAgeHours   int    `yaml:"max_age_hours"`  // rotate once a log has been written to this long
+	Compression   string `yaml:"compression"`    // "gzip", "zstd" or "none"
+	RetentionDays int    `yaml:"retention_days"` // delete rotated logs older than this
+	MaxTotalMB    int    `yaml:"max_total_mb"`   // delete the oldest rotated logs beyond this total
+}
+
+// For returns the rotation policy of a metric type with its overrides applied
+func (r LogRotation) For(metricType string) RotationPolicy {
+	policy := r.RotationPolicy
+	override, exists := r.PerMetric[metricType]
+	if !exists {
+		return policy
+	}
+	if override.MaxSizeMB != 0 {
+		policy.MaxSizeMB = override.MaxSizeMB
+	}
+	if override.MaxAgeHours != 0 {
+		policy.MaxAgeHours = override.MaxAgeHours
+	}
+	if override.Compression != "" {
+		policy.Compression = override.Compression
+	}
+	if override.RetentionDays != 0 {
+		policy.RetentionDays = override.RetentionDays
+	}
+	if override.MaxTotalMB != 0 {
+		policy.MaxTotalMB = override.MaxTotalMB
+	}
+	return policy
+}
+
+// Sink configures one destination of the logged metrics. Each sink is
+// written to from its own goroutine through a bounded queue.
+type Sink struct {
+	Type            string            `yaml:"type"`                        // "file", "stdout", "http" or "udp"
+	Format          string            `yaml:"format,omitempty"`            // "json", "csv" or "influx", defaults to log_format; http and udp sinks only write influx
+	Dir             string            `yaml:"dir,omitempty"`               // file sinks, defaults to log_dir
+	Endpoint        string            `yaml:"endpoint,omitempty"`          // http sinks: write URL; udp sinks: host:port
+	Headers         map[string]string `yaml:"headers,omitempty"`           // http sinks, e.g. Authorization
+	Metrics         []string          `yaml:"metrics,omitempty"`           // metric types to pass on, defaults to all
+	QueueSize       int               `yaml:"queue_size,omitempty"`        // samples waiting to be written, defaults to 1024
+	FlushIntervalMs int               `yaml:"flush_interval_ms,omitempty"` // defaults to 1000
+	Overflow        string            `yaml:"overflow,omitempty"`          // "drop" (default) or "block" when the queue is full
+}
+
+// History configures the time-series store kept in log_dir/tsdb
+type History struct {
+	Enabled       bool            `yaml:"enabled"`
+	RetentionDays int             `yaml:"retention_days"` // of the raw points; a negative value keeps everything, 0 is rejected
+	Rollups       []HistoryRollup `yaml:"rollups"`        // an empty list disables rollups
+}
+
+// HistoryRollup keeps the min, average and max of every series per
+// interval, each a multiple of the previous rollup's
+type HistoryRollup struct {
+	ResolutionSeconds int `yaml:"resolution_seconds"`
+	RetentionDays     int `yaml:"retention_days"` // a negative value keeps everything, 0 is rejected
+}
+
+// Exporter configures making the metrics available to monitoring systems
+type Exporter struct {
+	Prometheus PrometheusExporter `yaml:"prometheus"`
+	OTLP       OTLPExporter       `yaml:"otlp"`
+}
+
+// PrometheusExporter configures the HTTP listener serving the latest
+// metrics for Prometheus to scrape
+type PrometheusExporter struct {
+	Enabled bool   `yaml:"enabled"`
+	Listen  string `yaml:"listen"` // host:port, defaults to :9188
+	Path    string `yaml:"path"`   // defaults to /metrics
+}
+
+// OTLPExporter configures pushing the metrics to an OpenTelemetry endpoint
+// over OTLP/HTTP
+type OTLPExporter struct {
+	Enabled            bool              `yaml:"enabled"`
+	Endpoint           string            `yaml:"endpoint"`            // defaults to http://localhost:4318/v1/metrics
+	Encoding           string            `yaml:"encoding"`            // "protobuf" (default) or "json"
+	Compression        string            `yaml:"compression"`         // "gzip" (default) or "none"
+	Headers            map[string]string `yaml:"headers"`             // e.g. an Authorization header
+	ResourceAttributes map[string]string `yaml:"resource_attributes"` // added to the detected host, device and OS
+	IntervalSeconds    int               `yaml:"interval_seconds"`    // how often metrics are pushed, defaults to 10
+	TimeoutSeconds     int               `yaml:"timeout_seconds"`     // per request, defaults to 10
+	BufferDir          string            `yaml:"buffer_dir"`          // defaults to log_dir/otlp
+	BufferMaxMB        int               `yaml:"buffer_max_mb"`       // defaults to 100
 }
 
 // Theme configuration
@@ -40,7 +148,9 @@ type Theme struct {
 
 // Steam configuration
 type Steam struct {
-	APIKey string `yaml:"api_key"`
+	APIKey  string `yaml:"api_key"`
+	SteamID string `yaml:"steam_id"` // defaults to the most recent local login
+	APIURL  string `yaml:"api_url"`  // defaults to the public Steam Web API
 }
 
 // LoadConfig loads configuration from file or creates default
@@ -50,13 +160,51 @@ func LoadConfig(configPath string) (*Config, error) {
 		RefreshRate: 1000, // 1 second
 		LogDir:      getDefaultLogDir(),
 		LogFormat:   "json",
+		LogRotation: LogRotation{
+			RotationPolicy: RotationPolicy{
+				MaxSizeMB:     50,
+				MaxAgeHours:   24,
+				Compression:   "gzip",
+				RetentionDays: 14,
+				MaxTotalMB:    500,
+			},
+			// Session summaries are few and make up the playtime history,
+			// so they are kept in one log for good
+			PerMetric: map[string]RotationPolicy{
+				"sessions": {MaxSizeMB: -1, MaxAgeHours: -1, RetentionDays: -1, MaxTotalMB: -1},
+			},
+		},
+		History: History{
+			Enabled:       true,
+			RetentionDays: 1,
+			Rollups: []HistoryRollup{
+				{ResolutionSeconds: 60, RetentionDays: 30},
+				{ResolutionSeconds: 3600, RetentionDays: 365},
+			},
+		},
+		Exporter: Exporter{
+			Prometheus: PrometheusExporter{
+				Listen: ":9188",
+				Path:   "/metrics",
+			},
+			OTLP: OTLPExporter{
+				Endpoint:        "http://localhost:4318/v1/metrics",
+				Encoding:        "protobuf",
+				Compression:     "gzip",
+				IntervalSeconds: 10,
+				TimeoutSeconds:  10,
+				BufferMaxMB:     100,
+			},
+		},
 		Widgets: Widgets{
-			ShowCPU:     true,
-			ShowMemory:  true,
-			ShowDisk:    true,
-			ShowNetwork: true,
-			ShowGame:    true,
-			ShowSteam:   true,
+			ShowCPU:      true,
+			ShowMemory:   true,
+			ShowDisk:     true,
+			ShowNetwork:  true,
+			ShowGame:     true,
+			ShowSteam:    true,
+			ShowStorage:  true,
+			ShowPlaytime: true,
 		},
 		Theme: Theme{
 			BackgroundColor: "#1e1e2e",
@@ -82,7 +230,10 @@ func LoadConfig(configPath string) (*Config, error) {
 		return defaultConfig, fmt.Errorf("failed to read config: %w", err)
 	}
 
-	var config Config
+	// Keys missing from the file keep their default, so settings added
+	// since it was written, e.g. history.enabled, start out as intended
+	config := *defaultConfig
+	config.LogRotation.PerMetric = nil // merged with the defaults below
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return defaultConfig, fmt.Errorf("failed to parse config: %w", err)
 	}
@@ -97,10 +248,170 @@ func LoadConfig(configPath string) (*Config, error) {
 	if config.LogFormat == "" {
 		config.LogFormat = defaultConfig.LogFormat
 	}
+	if config.History.RetentionDays == 0 {
+		return defaultConfig, fmt.Errorf("history retention_days must be positive, or -1 to keep everything")
+	}
+	if config.History.Rollups == nil {
+		config.History.Rollups = defaultConfig.History.Rollups
+	}
+	for i, rollup := range config.History.Rollups {
+		if rollup.ResolutionSeconds <= 0 {
+			return defaultConfig, fmt.Errorf("history rollup resolution must be positive, got %d", rollup.ResolutionSeconds)
+		}
+		if rollup.RetentionDays == 0 {
+			return defaultConfig, fmt.Errorf("history rollup retention_days must be positive, or -1 to keep everything")
+		}
+		if i > 0 {
+			previous := config.History.Rollups[i-1].ResolutionSeconds
+			if rollup.ResolutionSeconds <= previous || rollup.ResolutionSeconds%previous != 0 {
+				return defaultConfig, fmt.Errorf("history rollup resolution %ds must be a larger multiple of %ds", rollup.ResolutionSeconds, previous)
+			}
+		}
+	}
+	if config.Exporter.Prometheus.Listen == "" {
+		config.Exporter.Prometheus.Listen = defaultConfig.Exporter.Prometheus.Listen
+	}
+	if config.Exporter.Prometheus.Path == "" {
+		config.Exporter.Prometheus.Path = defaultConfig.Exporter.Prometheus.Path
+	}
+	if !strings.HasPrefix(config.Exporter.Prometheus.Path, "/") {
+		return defaultConfig, fmt.Errorf("prometheus exporter path %q must start with /", config.Exporter.Prometheus.Path)
+	}
+	if err := mergeOTLPExporter(&config.Exporter.OTLP, defaultConfig.Exporter.OTLP, config.LogDir); err != nil {
+		return defaultConfig, err
+	}
+	config.LogRotation.RotationPolicy = mergeRotationPolicy(config.LogRotation.RotationPolicy, defaultConfig.LogRotation.RotationPolicy)
+	for metricType, defaults := range defaultConfig.LogRotation.PerMetric {
+		if config.LogRotation.PerMetric == nil {
+			config.LogRotation.PerMetric = make(map[string]RotationPolicy)
+		}
+		config.LogRotation.PerMetric[metricType] = mergeRotationPolicy(config.LogRotation.PerMetric[metricType], defaults)
+	}
+	for _, metricType := range append([]string{""}, mapKeys(config.LogRotation.PerMetric)...) {
+		switch compression := config.LogRotation.For(metricType).Compression; compression {
+		case "gzip", "zstd", "none":
+		default:
+			return defaultConfig, fmt.Errorf("unknown log compression %q, use gzip, zstd or none", compression)
+		}
+	}
+
+	for i := range config.Sinks {
+		sink := &config.Sinks[i]
+		switch sink.Type {
+		case "file", "stdout":
+			if sink.Format == "" {
+				sink.Format = config.LogFormat
+			}
+			if sink.Format != "json" && sink.Format != "csv" && sink.Format != "influx" {
+				return defaultConfig, fmt.Errorf("unknown %s sink format %q, use json, csv or influx", sink.Type, sink.Format)
+			}
+		case "http", "udp":
+			if sink.Format == "" {
+				sink.Format = "influx"
+			}
+			if sink.Format != "influx" {
+				return defaultConfig, fmt.Errorf("%s sinks only write influx, not %q", sink.Type, sink.Format)
+			}
+			if err := validateSinkEndpoint(sink.Type, sink.Endpoint); err != nil {
+				return defaultConfig, err
+			}
+		default:
+			return defaultConfig, fmt.Errorf("unknown sink type %q, use file, stdout, http or udp", sink.Type)
+		}
+		if sink.Type == "file" && sink.Dir == "" {
+			sink.Dir = config.LogDir
+		}
+		switch sink.Overflow {
+		case "", "drop", "block":
+		default:
+			return defaultConfig, fmt.Errorf("unknown %s sink overflow %q, use drop or block", sink.Type, sink.Overflow)
+		}
+	}
 
 	return &config, nil
 }
 
+// validateSinkEndpoint checks the endpoint of an http or udp sink
+func validateSinkEndpoint(sinkType, endpoint string) error {
+	if sinkType == "udp" {
+		if _, _, err := net.SplitHostPort(endpoint); err != nil {
+			return fmt.Errorf("udp sink endpoint %q must be host:port", endpoint)
+		}
+		return nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("http sink endpoint %q must be an http or https URL", endpoint)
+	}
+	return nil
+}
+
+// mergeOTLPExporter fills the unset fields of the OTLP exporter from
+// defaults and validates it
+func mergeOTLPExporter(otlp *OTLPExporter, defaults OTLPExporter, logDir string) error {
+	if otlp.Endpoint == "" {
+		otlp.Endpoint = defaults.Endpoint
+	}
+	if otlp.Encoding == "" {
+		otlp.Encoding = defaults.Encoding
+	}
+	if otlp.Compression == "" {
+		otlp.Compression = defaults.Compression
+	}
+	if otlp.IntervalSeconds <= 0 {
+		otlp.IntervalSeconds = defaults.IntervalSeconds
+	}
+	if otlp.TimeoutSeconds <= 0 {
+		otlp.TimeoutSeconds = defaults.TimeoutSeconds
+	}
+	if otlp.BufferDir == "" {
+		otlp.BufferDir = filepath.Join(logDir, "otlp")
+	}
+	if otlp.BufferMaxMB <= 0 {
+		otlp.BufferMaxMB = defaults.BufferMaxMB
+	}
+
+	endpoint, err := url.Parse(otlp.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return fmt.Errorf("otlp exporter endpoint %q must be an http or https URL", otlp.Endpoint)
+	}
+	if otlp.Encoding != "protobuf" && otlp.Encoding != "json" {
+		return fmt.Errorf("unknown otlp exporter encoding %q, use protobuf or json", otlp.Encoding)
+	}
+	if otlp.Compression != "gzip" && otlp.Compression != "none" {
+		return fmt.Errorf("unknown otlp exporter compression %q, use gzip or none", otlp.Compression)
+	}
+	return nil
+}
+
+func mapKeys(m map[string]RotationPolicy) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// mergeRotationPolicy fills the unset fields of policy from defaults
+func mergeRotationPolicy(policy, defaults RotationPolicy) RotationPolicy {
+	if policy.MaxSizeMB == 0 {
+		policy.MaxSizeMB = defaults.MaxSizeMB
+	}
+	if policy.MaxAgeHours == 0 {
+		policy.MaxAgeHours = defaults.MaxAgeHours
+	}
+	if policy.Compression == "" {
+		policy.Compression = defaults.Compression
+	}
+	if policy.RetentionDays == 0 {
+		policy.RetentionDays = defaults.RetentionDays
+	}
+	if policy.MaxTotalMB == 0 {
+		policy.MaxTotalMB = defaults.MaxTotalMB
+	}
+	return policy
+}
+
 // SaveConfig saves configuration to file
 func SaveConfig(configPath string, config *Config) error {
 	// Ensure directory exists
@@ -127,4 +438,3 @@ func getDefaultLogDir() string {
 	}
 	return filepath.Join(homeDir, ".steam-os-monitor", "logs")
 }
-
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSessionsLogIsNotRotatedByDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := "log_rotation:\n  max_age_hours: 12\n  per_metric:\n    disk:\n      max_size_mb: 20\n"
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := cfg.LogRotation.For("sessions")
+	if sessions.MaxSizeMB >= 0 || sessions.MaxAgeHours >= 0 || sessions.RetentionDays >= 0 || sessions.MaxTotalMB >= 0 {
+		t.Errorf("sessions rotation = %+v, want every limit disabled", sessions)
+	}
+	if disk := cfg.LogRotation.For("disk"); disk.MaxSizeMB != 20 || disk.MaxAgeHours != 12 {
+		t.Errorf("disk rotation = %+v", disk)
+	}
+}
+
+func TestSessionsRotationCanBeEnabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := "log_rotation:\n  per_metric:\n    sessions:\n      retention_days: 365\n"
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := cfg.LogRotation.For("sessions")
+	if sessions.RetentionDays != 365 || sessions.MaxSizeMB >= 0 {
+		t.Errorf("sessions rotation = %+v, want only the retention set", sessions)
+	}
+}
+
+// baselineConfig is a config file as written before history, storage and
+// playtime were added
+const baselineConfig = `refresh_rate: 500
+log_dir: /home/deck/.steam-os-monitor/logs
+log_format: csv
+widgets:
+    show_cpu: true
+    show_memory: true
+    show_disk: false
+    show_network: true
+    show_game: true
+    show_steam: true
+theme:
+    background_color: '#000000'
+    text_color: '#ffffff'
+    bar_color: '#89b4fa'
+    bar_color_high: '#f38ba8'
+    bar_color_medium: '#fab387'
+    bar_color_low: '#a6e3a1'
+steam:
+    api_key: ""
+`
+
+func TestBaselineConfigGetsNewDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(baselineConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RefreshRate != 500 || cfg.LogFormat != "csv" || cfg.Widgets.ShowDisk || cfg.Theme.BackgroundColor != "#000000" {
+		t.Errorf("settings from the file not kept: %+v", cfg)
+	}
+	if !cfg.History.Enabled || cfg.History.RetentionDays != 1 || len(cfg.History.Rollups) != 2 {
+		t.Errorf("history = %+v, want the defaults", cfg.History)
+	}
+	if !cfg.Widgets.ShowStorage || !cfg.Widgets.ShowPlaytime {
+		t.Errorf("widgets = %+v, want storage and playtime shown", cfg.Widgets)
+	}
+	if sessions := cfg.LogRotation.For("sessions"); sessions.MaxSizeMB >= 0 {
+		t.Errorf("sessions rotation = %+v, want every limit disabled", sessions)
+	}
+}
+
+func TestConfigKeepsExplicitFalse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := "history:\n  enabled: false\n  rollups: []\nwidgets:\n  show_playtime: false\n"
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.History.Enabled || len(cfg.History.Rollups) != 0 || cfg.Widgets.ShowPlaytime {
+		t.Errorf("history %+v, widgets %+v, want history, rollups and playtime off", cfg.History, cfg.Widgets)
+	}
+	if !cfg.Widgets.ShowStorage {
+		t.Error("storage widget hidden, want the default")
+	}
+}
+
+func TestHistoryRetentionZeroIsRejected(t *testing.T) {
+	for _, config := range []string{
+		"history:\n  retention_days: 0\n",
+		"history:\n  rollups:\n    - resolution_seconds: 60\n      retention_days: 0\n",
+	} {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("LoadConfig(%q) succeeded, want retention_days 0 rejected", config)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Package exporter makes the collected metrics available to monitoring
+// systems such as Prometheus
+package exporter
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/steam-os-monitor/monitor/internal/logger"
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// Metric is one value of a collected metric, named after what it measures
+// and converted to base units
+type Metric struct {
+	Name    string // e.g. "cpu_core_usage"
+	Unit    string // e.g. "percent", "bytes" or "seconds"; empty for counts
+	Help    string
+	Counter bool // a running total rather than a current value
+	Labels  []Label
+	Value   float64
+}
+
+// Label tells apart the metrics of the same name, e.g. one per disk
+type Label struct {
+	Name  string
+	Value string
+}
+
+// metricSet collects the metrics of a sample
+type metricSet []Metric
+
+// gauge adds a current value, with labels given as name, value pairs
+func (s *metricSet) gauge(name, unit, help string, value float64, labels ...string) {
+	s.add(Metric{Name: name, Unit: unit, Help: help, Value: value}, labels)
+}
+
+// counter adds a running total, with labels given as name, value pairs
+func (s *metricSet) counter(name, unit, help string, value float64, labels ...string) {
+	s.add(Metric{Name: name, Unit: unit, Help: help, Counter: true, Value: value}, labels)
+}
+
+func (s *metricSet) add(m Metric, labels []string) {
+	for i := 0; i+1 < len(labels); i += 2 {
+		m.Labels = append(m.Labels, Label{Name: labels[i], Value: labels[i+1]})
+	}
+	*s = append(*s, m)
+}
+
+// Metrics converts a logged sample to metrics. Session summaries are
+// events rather than measurements and give none, as do game samples
+// taken while no game runs.
+func Metrics(sample logger.Sample) []Metric {
+	var s metricSet
+	switch data := sample.Data.(type) {
+	case *metrics.CPUStats:
+		cpuMetrics(&s, data)
+	case *metrics.MemoryStats:
+		memoryMetrics(&s, data)
+	case *metrics.DiskStats:
+		diskMetrics(&s, data)
+	case *metrics.NetworkStats:
+		networkMetrics(&s, data)
+	case *metrics.GamePerformanceStats:
+		gameMetrics(&s, data)
+	case *metrics.SensorStats:
+		sensorMetrics(&s, data)
+	case *metrics.SteamStats:
+		steamMetrics(&s, data)
+	case *metrics.SteamInventory:
+		steamInventoryMetrics(&s, data)
+	}
+	return s
+}
+
+func cpuMetrics(s *metricSet, c *metrics.CPUStats) {
+	s.gauge("cpu_usage", "percent", "CPU usage over all cores", c.OverallPercent)
+	for i, percent := range c.PerCorePercent {
+		s.gauge("cpu_core_usage", "percent", "CPU usage per core", percent, "core", strconv.Itoa(i))
+	}
+	s.gauge("load1", "", "System load average over 1 minute", c.LoadAvg1)
+	s.gauge("load5", "", "System load average over 5 minutes", c.LoadAvg5)
+	s.gauge("load15", "", "System load average over 15 minutes", c.LoadAvg15)
+}
+
+func memoryMetrics(s *metricSet, m *metrics.MemoryStats) {
+	s.gauge("memory_total", "bytes", "Total memory", float64(m.Total))
+	s.gauge("memory_used", "bytes", "Memory in use", float64(m.Used))
+	s.gauge("memory_available", "bytes", "Memory available to applications", float64(m.Available))
+	s.gauge("memory_used", "percent", "Memory in use as a percentage of the total", m.UsedPercent)
+	s.gauge("swap_total", "bytes", "Total swap space", float64(m.SwapTotal))
+	s.gauge("swap_used", "bytes", "Swap space in use", float64(m.SwapUsed))
+	s.gauge("swap_used", "percent", "Swap space in use as a percentage of the total", m.SwapPercent)
+}
+
+func diskMetrics(s *metricSet, d *metrics.DiskStats) {
+	labels := []string{"device", d.Device, "mountpoint", d.MountPoint}
+	s.gauge("disk_total", "bytes", "Size of the filesystem", float64(d.Total), labels...)
+	s.gauge("disk_used", "bytes", "Space used on the filesystem", float64(d.Used), labels...)
+	s.gauge("disk_free", "bytes", "Space free on the filesystem", float64(d.Free), labels...)
+	s.gauge("disk_used", "percent", "Space used as a percentage of the filesystem size", d.UsedPercent, labels...)
+	s.counter("disk_read", "bytes", "Bytes read from the device", float64(d.ReadBytes), labels...)
+	s.counter("disk_written", "bytes", "Bytes written to the device", float64(d.WriteBytes), labels...)
+	s.counter("disk_reads_completed", "", "Reads completed on the device", float64(d.ReadIOPS), labels...)
+	s.counter("disk_writes_completed", "", "Writes completed on the device", float64(d.WriteIOPS), labels...)
+}
+
+func networkMetrics(s *metricSet, n *metrics.NetworkStats) {
+	labels := []string{"interface", n.Interface}
+	s.counter("network_transmit", "bytes", "Bytes sent on the interface", float64(n.BytesSent), labels...)
+	s.counter("network_receive", "bytes", "Bytes received on the interface", float64(n.BytesRecv), labels...)
+	s.counter("network_transmit_packets", "", "Packets sent on the interface", float64(n.PacketsSent), labels...)
+	s.counter("network_receive_packets", "", "Packets received on the interface", float64(n.PacketsRecv), labels...)
+	s.gauge("network_transmit_speed", "bytes_per_second", "Current send rate of the interface", n.SpeedSent, labels...)
+	s.gauge("network_receive_speed", "bytes_per_second", "Current receive rate of the interface", n.SpeedRecv, labels...)
+}
+
+func gameMetrics(s *metricSet, g *metrics.GamePerformanceStats) {
+	if g.AppID == "" {
+		return
+	}
+	app := []string{"app_id", g.AppID}
+	s.gauge("game_info", "", "The running game, always 1", 1,
+		"app_id", g.AppID, "name", g.GameName, "proton", strconv.FormatBool(g.Proton),
+		"compat_tool", g.CompatTool, "compat_tool_version", g.CompatToolVersion,
+		"frame_time_source", g.FrameTimeSource)
+	s.gauge("game_fps", "", "Frames per second of the running game", g.FPS, app...)
+	// Without MangoHud the statistics are those of the sampled FPS, see
+	// the frame_time_source label of game_info
+	s.gauge("game_fps_1pct_low", "", "Average FPS of the slowest 1% of recent frames or FPS readings", g.FPS1PercentLow, app...)
+	s.gauge("game_fps_0_1pct_low", "", "Average FPS of the slowest 0.1% of recent frames or FPS readings", g.FPS01PercentLow, app...)
+
+	// Frame times are collected in milliseconds
+	frameTimes := []struct {
+		name, help string
+		ms         float64
+	}{
+		{"game_frame_time", "Current frame time", g.FrameTime},
+		{"game_frame_time_min", "Shortest recent frame time", g.FrameTimeMin},
+		{"game_frame_time_max", "Longest recent frame time", g.FrameTimeMax},
+		{"game_frame_time_mean", "Mean of the recent frame times", g.FrameTimeMean},
+		{"game_frame_time_median", "Median of the recent frame times", g.FrameTimeMedian},
+		{"game_frame_time_p95", "95th percentile of the recent frame times", g.FrameTimeP95},
+		{"game_frame_time_p99", "99th percentile of the recent frame times", g.FrameTimeP99},
+		{"game_frame_time_stddev", "Standard deviation of the recent frame times", g.FrameTimeStdDev},
+	}
+	for _, ft := range frameTimes {
+		s.gauge(ft.name, "seconds", ft.help, ft.ms/1000, app...)
+	}
+	s.gauge("game_frame_samples", "", "Frames or FPS readings the recent frame time statistics cover", float64(g.FrameSamples), app...)
+}
+
+// sensorMetrics leaves out the sensors that couldn't be read, which are
+// collected as 0
+func sensorMetrics(s *metricSet, t *metrics.SensorStats) {
+	if t.CPUTemp > 0 {
+		s.gauge("cpu_temperature", "celsius", "CPU temperature", t.CPUTemp)
+	}
+	if t.GPUTemp > 0 {
+		s.gauge("gpu_temperature", "celsius", "GPU temperature", t.GPUTemp)
+	}
+	if t.CPUClock > 0 {
+		s.gauge("cpu_frequency", "hertz", "CPU clock averaged over all cores", t.CPUClock*1e6)
+	}
+	if t.GPUClock > 0 {
+		s.gauge("gpu_frequency", "hertz", "GPU shader clock", t.GPUClock*1e6)
+	}
+	if t.APUPower > 0 {
+		s.gauge("apu_power", "watts", "APU power draw", t.APUPower)
+	}
+	if t.HasBattery {
+		s.gauge("battery_charge", "percent", "Battery charge", t.BatteryPercent)
+		s.gauge("battery_power", "watts", "Battery charge or discharge rate", t.BatteryPower)
+		s.gauge("battery_info", "", "Battery status, always 1", 1, "status", t.BatteryStatus)
+	}
+}
+
+func steamMetrics(s *metricSet, st *metrics.SteamStats) {
+	s.gauge("steam_download_speed", "bytes_per_second", "Steam download rate", st.DownloadSpeed)
+	s.gauge("steam_upload_speed", "bytes_per_second", "Steam upload rate", st.UploadSpeed)
+	s.gauge("steam_disk_write_speed", "bytes_per_second", "Rate Steam writes downloaded content to disk", st.DiskWriteSpeed)
+	s.gauge("steam_active_downloads", "", "Apps Steam is downloading", float64(st.ActiveDownloads))
+	s.gauge("steam_update_queue", "", "Apps waiting for an update", float64(len(st.UpdateQueue)))
+	s.gauge("steam_installed_games", "", "Installed apps in all libraries", float64(st.InstalledGames))
+	s.gauge("steam_installed_size", "bytes", "Install size of the apps in all libraries", float64(st.LibrarySize))
+	s.gauge("steam_orphaned", "bytes", "Proton prefixes and shader caches of uninstalled apps", float64(st.OrphanedBytes))
+
+	appIDs := make([]string, 0, len(st.DownloadProgress))
+	for appID := range st.DownloadProgress {
+		appIDs = append(appIDs, appID)
+	}
+	sort.Strings(appIDs)
+	for _, appID := range appIDs {
+		s.gauge("steam_download_progress", "percent", "Download progress per app", st.DownloadProgress[appID], "app_id", appID)
+	}
+	for _, lib := range st.Libraries {
+		labels := []string{"path", lib.Path, "device", lib.Device, "mountpoint", lib.MountPoint}
+		s.gauge("steam_library_games", "", "Apps installed in the library", float64(lib.GameCount), labels...)
+		s.gauge("steam_library_installed", "bytes", "Install size of the apps in the library", float64(lib.Size), labels...)
+		s.gauge("steam_library_free", "bytes", "Space free on the library's filesystem", float64(lib.Free), labels...)
+		s.gauge("steam_library_total", "bytes", "Size of the library's filesystem", float64(lib.Total), labels...)
+	}
+}
+
+// steamInventoryMetrics are exported until the inventory is next logged,
+// which is only when it changes
+func steamInventoryMetrics(s *metricSet, st *metrics.SteamInventory) {
+	for _, app := range st.Storage {
+		kinds := []struct {
+			kind  string
+			bytes uint64
+		}{
+			{"install", app.InstallBytes},
+			{"shader_cache", app.ShaderCacheBytes},
+			{"compat_data", app.CompatDataBytes},
+			{"workshop", app.WorkshopBytes},
+		}
+		for _, k := range kinds {
+			s.gauge("steam_app_storage", "bytes", "Disk usage per app, library and kind of data", float64(k.bytes), "app_id", app.AppID, "library", app.LibraryPath, "kind", k.kind)
+		}
+	}
+	for _, p := range st.Playtime {
+		s.counter("steam_app_playtime", "seconds", "Playtime Steam recorded per app and user", p.Playtime.Seconds(), "app_id", p.AppID, "user_id", p.UserID)
+	}
+}
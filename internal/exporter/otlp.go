@@ -0,0 +1,526 @@
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/steam-os-monitor/monitor/internal/logger"
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// OTLPOptions configures an OTLP exporter. Zero values get the defaults.
+type OTLPOptions struct {
+	Endpoint    string            // full URL, defaults to http://localhost:4318/v1/metrics
+	Encoding    string            // "protobuf" (default) or "json"
+	Compression string            // "gzip" (default) or "none"
+	Headers     map[string]string // sent with every request, e.g. Authorization
+	Resource    map[string]string // resource attributes, e.g. from SystemResource
+	Interval    time.Duration     // how often collected metrics are pushed, defaults to 10s
+	Timeout     time.Duration     // per request, defaults to 10s
+	BufferDir   string            // where requests wait while the endpoint is unreachable
+	BufferSize  int64             // bytes kept in BufferDir, defaults to 100 MB
+}
+
+// Defaults of OTLPOptions
+const (
+	DefaultOTLPEndpoint   = "http://localhost:4318/v1/metrics"
+	DefaultOTLPInterval   = 10 * time.Second
+	DefaultOTLPTimeout    = 10 * time.Second
+	DefaultOTLPBufferSize = 100 * 1024 * 1024
+)
+
+// Retry backoff while the endpoint is unreachable
+const (
+	minRetryBackoff = time.Second
+	maxRetryBackoff = 5 * time.Minute
+	// closeTimeout bounds the last push when the exporter is closed; what
+	// isn't sent by then is buffered for the next start
+	closeTimeout = 2 * time.Second
+)
+
+// scopeName is the instrumentation scope of the exported metrics
+const scopeName = "github.com/steam-os-monitor/monitor"
+
+// OTLP is a sink pushing metrics to an OTLP/HTTP endpoint every interval.
+// Requests that can't be delivered are kept in a buffer directory and
+// retried with backoff, oldest first, also after a restart.
+type OTLP struct {
+	options  OTLPOptions
+	client   *http.Client
+	resource []otlpKeyValue
+	started  time.Time // start time of the cumulative sums
+
+	mu      sync.Mutex
+	pending []timedMetric
+
+	// Owned by run
+	buffered bool // requests are waiting in the buffer directory
+	backoff  time.Duration
+	retry    *time.Timer
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// timedMetric is a metric with the time it was collected at
+type timedMetric struct {
+	Metric
+	time time.Time
+}
+
+// retryableError is a failed push that may succeed later
+type retryableError struct {
+	err        error
+	retryAfter time.Duration // requested by the endpoint, or 0
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+// NewOTLP creates a sink pushing to the configured endpoint in the
+// background. Requests buffered by a previous run are retried right away.
+func NewOTLP(options OTLPOptions) (*OTLP, error) {
+	if options.Endpoint == "" {
+		options.Endpoint = DefaultOTLPEndpoint
+	}
+	if options.Encoding == "" {
+		options.Encoding = "protobuf"
+	}
+	if options.Compression == "" {
+		options.Compression = "gzip"
+	}
+	if options.Interval <= 0 {
+		options.Interval = DefaultOTLPInterval
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultOTLPTimeout
+	}
+	if options.BufferSize <= 0 {
+		options.BufferSize = DefaultOTLPBufferSize
+	}
+	if options.Encoding != "protobuf" && options.Encoding != "json" {
+		return nil, fmt.Errorf("unknown OTLP encoding %q, use protobuf or json", options.Encoding)
+	}
+	if options.Compression != "gzip" && options.Compression != "none" {
+		return nil, fmt.Errorf("unknown OTLP compression %q, use gzip or none", options.Compression)
+	}
+	if err := os.MkdirAll(options.BufferDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create OTLP buffer directory: %w", err)
+	}
+
+	o := &OTLP{
+		options: options,
+		client:  &http.Client{Timeout: options.Timeout},
+		started: time.Now(),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	keys := make([]string, 0, len(options.Resource))
+	for key := range options.Resource {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		o.resource = append(o.resource, otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: options.Resource[key]}})
+	}
+
+	files, err := o.bufferedFiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 0 {
+		o.buffered = true
+		o.retry = time.NewTimer(0)
+	} else {
+		o.retry = time.NewTimer(maxRetryBackoff)
+		o.retry.Stop()
+	}
+	go o.run()
+	return o, nil
+}
+
+// SystemResource returns the OTLP resource attributes describing the device
+// and OS, following the OpenTelemetry semantic conventions
+func SystemResource(info *metrics.SystemInfo) map[string]string {
+	attributes := map[string]string{
+		"service.name": "steam-os-monitor",
+		"os.type":      "linux",
+	}
+	set := func(key, value string) {
+		if value != "" {
+			attributes[key] = value
+		}
+	}
+	set("host.name", info.Host)
+	set("device.manufacturer", info.Vendor)
+	set("device.model.identifier", info.Model)
+	set("device.model.name", info.ModelName)
+	set("os.name", info.OSName)
+	set("os.version", info.OSVersion)
+	set("os.build_id", info.OSBuildID)
+	return attributes
+}
+
+// Write queues the sample's metrics for the next push
+func (o *OTLP) Write(sample logger.Sample) error {
+	metrics := Metrics(sample)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, m := range metrics {
+		o.pending = append(o.pending, timedMetric{Metric: m, time: sample.Time})
+	}
+	return nil
+}
+
+// Flush does nothing; metrics are pushed every interval
+func (o *OTLP) Flush() error {
+	return nil
+}
+
+// Close pushes what is left, buffering it if that fails. Closing again
+// does nothing.
+func (o *OTLP) Close() error {
+	o.closeOnce.Do(func() { close(o.stop) })
+	<-o.done
+	return nil
+}
+
+// run pushes the pending metrics every interval and retries buffered
+// requests until the exporter is closed
+func (o *OTLP) run() {
+	defer close(o.done)
+	ticker := time.NewTicker(o.options.Interval)
+	defer ticker.Stop()
+	defer o.retry.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			o.push(context.Background())
+		case <-o.retry.C:
+			o.sendBuffered()
+		case <-o.stop:
+			ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+			o.push(ctx)
+			cancel()
+			return
+		}
+	}
+}
+
+// push sends the pending metrics. While earlier requests are buffered, or
+// when the endpoint can't be reached, the request joins the buffer.
+func (o *OTLP) push(ctx context.Context) {
+	o.mu.Lock()
+	pending := o.pending
+	o.pending = nil
+	o.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	body, err := o.encode(pending)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to encode OTLP metrics")
+		return
+	}
+	if o.buffered {
+		o.store(body)
+		return
+	}
+
+	err = o.send(ctx, o.options.Encoding, o.options.Compression == "gzip", body)
+	var retryable *retryableError
+	switch {
+	case err == nil:
+	case errors.As(err, &retryable):
+		logrus.WithError(err).Warn("OTLP endpoint unreachable, buffering metrics")
+		o.store(body)
+		o.buffered = true
+		o.scheduleRetry(retryable.retryAfter)
+	default:
+		logrus.WithError(err).Error("OTLP endpoint rejected metrics")
+	}
+}
+
+// sendBuffered sends the buffered requests, oldest first, until one fails
+func (o *OTLP) sendBuffered() {
+	files, err := o.bufferedFiles()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to read OTLP buffer")
+		o.scheduleRetry(0)
+		return
+	}
+	for _, name := range files {
+		encoding, compressed := bufferedFormat(name)
+		path := filepath.Join(o.options.BufferDir, name)
+		body, err := os.ReadFile(path)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to read buffered OTLP request")
+			os.Remove(path)
+			continue
+		}
+
+		err = o.send(context.Background(), encoding, compressed, body)
+		var retryable *retryableError
+		if errors.As(err, &retryable) {
+			o.scheduleRetry(retryable.retryAfter)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("OTLP endpoint rejected buffered metrics")
+		}
+		os.Remove(path)
+	}
+
+	if o.buffered {
+		logrus.Info("OTLP endpoint reachable again, buffered metrics sent")
+	}
+	o.buffered = false
+	o.backoff = 0
+}
+
+// scheduleRetry retries the buffered requests after the delay asked for by
+// the endpoint, or an exponential backoff with jitter
+func (o *OTLP) scheduleRetry(retryAfter time.Duration) {
+	if o.backoff == 0 {
+		o.backoff = minRetryBackoff
+	} else if o.backoff *= 2; o.backoff > maxRetryBackoff {
+		o.backoff = maxRetryBackoff
+	}
+	delay := retryAfter
+	if delay <= 0 {
+		delay = o.backoff/2 + time.Duration(rand.Int63n(int64(o.backoff/2)+1))
+	}
+	o.retry.Reset(delay)
+}
+
+// send posts a request body. Network errors, throttling and unavailability
+// are retryable; other rejections are not.
+func (o *OTLP) send(ctx context.Context, encoding string, compressed bool, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.options.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if encoding == "json" {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Header.Set("Content-Type", "application/x-protobuf")
+	}
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for key, value := range o.options.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return &retryableError{err: err}
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("OTLP endpoint returned %s", resp.Status)
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		retryAfter := time.Duration(0)
+		if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return &retryableError{err: err, retryAfter: retryAfter}
+	}
+	return err
+}
+
+// encode builds a request of the metrics in the configured encoding and
+// compression, with the data points of a metric grouped together
+func (o *OTLP) encode(pending []timedMetric) ([]byte, error) {
+	var ordered []*otlpMetric
+	byName := make(map[string]*otlpMetric)
+	for _, m := range pending {
+		// Neither encoding can carry every non-finite value
+		if math.IsNaN(m.Value) || math.IsInf(m.Value, 0) {
+			continue
+		}
+		name := OTLPName(m.Metric)
+		metric, exists := byName[name]
+		if !exists {
+			metric = &otlpMetric{Name: name, Description: m.Help, Unit: otlpUnit(m.Unit)}
+			if m.Counter {
+				metric.Sum = &otlpSum{AggregationTemporality: aggregationTemporalityCumulative, IsMonotonic: true}
+			} else {
+				metric.Gauge = &otlpGauge{}
+			}
+			byName[name] = metric
+			ordered = append(ordered, metric)
+		}
+
+		point := otlpDataPoint{TimeUnixNano: uint64(m.time.UnixNano()), AsDouble: m.Value}
+		for _, label := range m.Labels {
+			point.Attributes = append(point.Attributes, otlpKeyValue{Key: label.Name, Value: otlpAnyValue{StringValue: label.Value}})
+		}
+		if metric.Sum != nil {
+			point.StartTimeUnixNano = uint64(o.started.UnixNano())
+			metric.Sum.DataPoints = append(metric.Sum.DataPoints, point)
+		} else {
+			metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, point)
+		}
+	}
+
+	request := &otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     otlpResource{Attributes: o.resource},
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpScope{Name: scopeName}, Metrics: ordered}},
+	}}}
+	var body []byte
+	if o.options.Encoding == "json" {
+		var err error
+		if body, err = request.marshalJSON(); err != nil {
+			return nil, err
+		}
+	} else {
+		body = request.marshalProto()
+	}
+
+	if o.options.Compression != "gzip" {
+		return body, nil
+	}
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(body)
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+// OTLPName returns a metric's name in OTLP, e.g. steamos.disk_read; the
+// unit is sent separately. A metric has a single unit, so percentages are
+// named after theirs, e.g. steamos.memory_used.percent next to
+// steamos.memory_used in bytes.
+func OTLPName(m Metric) string {
+	if m.Unit == "percent" {
+		return "steamos." + m.Name + ".percent"
+	}
+	return "steamos." + m.Name
+}
+
+// otlpUnit returns the UCUM unit OpenTelemetry uses for a metric unit
+func otlpUnit(unit string) string {
+	switch unit {
+	case "":
+		return "1"
+	case "percent":
+		return "%"
+	case "bytes":
+		return "By"
+	case "bytes_per_second":
+		return "By/s"
+	case "seconds":
+		return "s"
+	case "celsius":
+		return "Cel"
+	case "hertz":
+		return "Hz"
+	case "watts":
+		return "W"
+	}
+	return unit
+}
+
+// Buffered requests are named after the time they were stored, with their
+// encoding and compression as extension, e.g. 1700000000000000000.pb.gz
+func (o *OTLP) store(body []byte) {
+	ext := ".pb"
+	if o.options.Encoding == "json" {
+		ext = ".json"
+	}
+	if o.options.Compression == "gzip" {
+		ext += ".gz"
+	}
+	name := fmt.Sprintf("%020d%s", time.Now().UnixNano(), ext)
+	path := filepath.Join(o.options.BufferDir, name)
+	if err := os.WriteFile(path+".tmp", body, 0644); err != nil {
+		logrus.WithError(err).Error("Failed to buffer OTLP metrics")
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		logrus.WithError(err).Error("Failed to buffer OTLP metrics")
+		return
+	}
+	o.trimBuffer()
+}
+
+// trimBuffer deletes the oldest buffered requests beyond the buffer size
+func (o *OTLP) trimBuffer() {
+	files, err := o.bufferedFiles()
+	if err != nil {
+		return
+	}
+	sizes := make([]int64, len(files))
+	var total int64
+	for i, name := range files {
+		if info, err := os.Stat(filepath.Join(o.options.BufferDir, name)); err == nil {
+			sizes[i] = info.Size()
+			total += sizes[i]
+		}
+	}
+	dropped := 0
+	for i := 0; i < len(files)-1 && total > o.options.BufferSize; i++ {
+		if os.Remove(filepath.Join(o.options.BufferDir, files[i])) == nil {
+			total -= sizes[i]
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		logrus.WithField("requests", dropped).Warn("OTLP buffer full, dropped the oldest metrics")
+	}
+}
+
+// bufferedFiles returns the names of the buffered requests, oldest first
+func (o *OTLP) bufferedFiles() ([]string, error) {
+	entries, err := os.ReadDir(o.options.BufferDir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && !strings.HasSuffix(name, ".tmp") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// bufferedFormat reads the encoding and compression from a buffered
+// request's name
+func bufferedFormat(name string) (encoding string, compressed bool) {
+	compressed = strings.HasSuffix(name, ".gz")
+	encoding = "protobuf"
+	if strings.HasSuffix(strings.TrimSuffix(name, ".gz"), ".json") {
+		encoding = "json"
+	}
+	return encoding, compressed
+}
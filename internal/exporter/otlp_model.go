@@ -0,0 +1,192 @@
+package exporter
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+)
+
+// The subset of the OTLP metrics data model the exporter sends, with the
+// field names of the OTLP/JSON encoding. marshalProto encodes the same
+// messages in the protobuf wire format, with the field numbers of
+// opentelemetry-proto's metrics/v1 and collector/metrics/v1.
+
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope     `json:"scope"`
+	Metrics []*otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Unit        string     `json:"unit,omitempty"`
+	Gauge       *otlpGauge `json:"gauge,omitempty"`
+	Sum         *otlpSum   `json:"sum,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+// Sums are always cumulative and monotonic; only counters are sent as sums
+const aggregationTemporalityCumulative = 2
+
+type otlpSum struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano uint64         `json:"startTimeUnixNano,string,omitempty"`
+	TimeUnixNano      uint64         `json:"timeUnixNano,string"`
+	AsDouble          float64        `json:"asDouble"`
+}
+
+func (r *otlpRequest) marshalJSON() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+func (r *otlpRequest) marshalProto() []byte {
+	var w protoWriter
+	for _, rm := range r.ResourceMetrics {
+		w.message(1, func(w *protoWriter) {
+			w.message(1, func(w *protoWriter) {
+				for _, kv := range rm.Resource.Attributes {
+					w.message(1, kv.marshalProto)
+				}
+			})
+			for _, sm := range rm.ScopeMetrics {
+				w.message(2, func(w *protoWriter) {
+					w.message(1, func(w *protoWriter) {
+						w.string(1, sm.Scope.Name)
+					})
+					for _, m := range sm.Metrics {
+						w.message(2, m.marshalProto)
+					}
+				})
+			}
+		})
+	}
+	return w.buf
+}
+
+func (kv otlpKeyValue) marshalProto(w *protoWriter) {
+	w.string(1, kv.Key)
+	w.message(2, func(w *protoWriter) {
+		// A oneof member is written even when empty
+		w.tag(1, wireBytes)
+		w.bytes([]byte(kv.Value.StringValue))
+	})
+}
+
+func (m *otlpMetric) marshalProto(w *protoWriter) {
+	w.string(1, m.Name)
+	w.string(2, m.Description)
+	w.string(3, m.Unit)
+	if m.Gauge != nil {
+		w.message(5, func(w *protoWriter) {
+			for _, p := range m.Gauge.DataPoints {
+				w.message(1, p.marshalProto)
+			}
+		})
+	}
+	if m.Sum != nil {
+		w.message(7, func(w *protoWriter) {
+			for _, p := range m.Sum.DataPoints {
+				w.message(1, p.marshalProto)
+			}
+			w.varintField(2, uint64(m.Sum.AggregationTemporality))
+			if m.Sum.IsMonotonic {
+				w.varintField(3, 1)
+			}
+		})
+	}
+}
+
+func (p otlpDataPoint) marshalProto(w *protoWriter) {
+	if p.StartTimeUnixNano != 0 {
+		w.fixed64(2, p.StartTimeUnixNano)
+	}
+	w.fixed64(3, p.TimeUnixNano)
+	// as_double is a oneof member, so it's written even when 0
+	w.fixed64(4, math.Float64bits(p.AsDouble))
+	for _, kv := range p.Attributes {
+		w.message(7, kv.marshalProto)
+	}
+}
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// protoWriter appends protobuf fields to a buffer
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) tag(field, wireType int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(field<<3|wireType))
+}
+
+func (w *protoWriter) bytes(b []byte) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+// string writes a string field, leaving it out when empty as proto3 does
+func (w *protoWriter) string(field int, s string) {
+	if s == "" {
+		return
+	}
+	w.tag(field, wireBytes)
+	w.bytes([]byte(s))
+}
+
+func (w *protoWriter) varintField(field int, v uint64) {
+	w.tag(field, wireVarint)
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *protoWriter) fixed64(field int, v uint64) {
+	w.tag(field, wireFixed64)
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, v)
+}
+
+// message writes an embedded message encoded by encode
+func (w *protoWriter) message(field int, encode func(w *protoWriter)) {
+	var sub protoWriter
+	encode(&sub)
+	w.tag(field, wireBytes)
+	w.bytes(sub.buf)
+}
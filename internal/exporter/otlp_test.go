@@ -0,0 +1,314 @@
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/internal/logger"
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// receivedRequest is a request received by the stand-in collector
+type receivedRequest struct {
+	at              time.Time
+	contentType     string
+	contentEncoding string
+	body            []byte // decompressed
+}
+
+// otlpCollector is a stand-in OTLP/HTTP endpoint answering with status,
+// and Retry-After if set, until it is changed
+type otlpCollector struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu         sync.Mutex
+	status     int
+	retryAfter string
+	requests   []receivedRequest
+}
+
+func newOTLPCollector(t *testing.T) *otlpCollector {
+	c := &otlpCollector{t: t, status: http.StatusOK}
+	c.server = httptest.NewServer(http.HandlerFunc(c.handle))
+	t.Cleanup(c.server.Close)
+	return c
+}
+
+func (c *otlpCollector) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		c.t.Error(err)
+	}
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			c.t.Errorf("body isn't gzip: %v", err)
+			return
+		}
+		if body, err = io.ReadAll(gz); err != nil {
+			c.t.Errorf("body isn't gzip: %v", err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, receivedRequest{time.Now(), r.Header.Get("Content-Type"), r.Header.Get("Content-Encoding"), body})
+	if c.retryAfter != "" {
+		w.Header().Set("Retry-After", c.retryAfter)
+	}
+	w.WriteHeader(c.status)
+}
+
+func (c *otlpCollector) respond(status int, retryAfter string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.status, c.retryAfter = status, retryAfter
+}
+
+func (c *otlpCollector) received() []receivedRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]receivedRequest(nil), c.requests...)
+}
+
+// waitFor polls until done reports true or the test times out
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func writeCPU(t *testing.T, o *OTLP, percent float64) {
+	t.Helper()
+	sample := logger.Sample{Type: "cpu", Time: time.Now(), Data: &metrics.CPUStats{OverallPercent: percent}}
+	if err := o.Write(sample); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// cpuUsage returns the steamos.cpu_usage.percent values of a JSON request
+func cpuUsage(t *testing.T, body []byte) []float64 {
+	t.Helper()
+	var request otlpRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		t.Fatalf("request isn't OTLP JSON: %v", err)
+	}
+	var values []float64
+	for _, rm := range request.ResourceMetrics {
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name == "steamos.cpu_usage.percent" && m.Gauge != nil {
+					for _, p := range m.Gauge.DataPoints {
+						values = append(values, p.AsDouble)
+					}
+				}
+			}
+		}
+	}
+	return values
+}
+
+func bufferedCount(t *testing.T, dir string) int {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func TestOTLPEncodings(t *testing.T) {
+	tests := []struct {
+		encoding, compression, contentType, contentEncoding string
+	}{
+		{"json", "gzip", "application/json", "gzip"},
+		{"json", "none", "application/json", ""},
+		{"protobuf", "gzip", "application/x-protobuf", "gzip"},
+		{"protobuf", "none", "application/x-protobuf", ""},
+	}
+	for _, tt := range tests {
+		collector := newOTLPCollector(t)
+		o, err := NewOTLP(OTLPOptions{
+			Endpoint:    collector.server.URL,
+			Encoding:    tt.encoding,
+			Compression: tt.compression,
+			Interval:    time.Hour,
+			BufferDir:   t.TempDir(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeCPU(t, o, 42)
+		o.Close() // pushes the pending metrics
+
+		requests := collector.received()
+		if len(requests) != 1 {
+			t.Fatalf("%s/%s: %d requests, want 1", tt.encoding, tt.compression, len(requests))
+		}
+		r := requests[0]
+		if r.contentType != tt.contentType || r.contentEncoding != tt.contentEncoding {
+			t.Errorf("%s/%s: Content-Type %q, Content-Encoding %q", tt.encoding, tt.compression, r.contentType, r.contentEncoding)
+		}
+		if tt.encoding == "json" {
+			if values := cpuUsage(t, r.body); len(values) != 1 || values[0] != 42 {
+				t.Errorf("%s/%s: cpu_usage = %v, want [42]", tt.encoding, tt.compression, values)
+			}
+		} else if !bytes.Contains(r.body, []byte("steamos.cpu_usage.percent")) {
+			t.Errorf("%s/%s: protobuf body lacks the metric name", tt.encoding, tt.compression)
+		}
+	}
+}
+
+func TestOTLPMemoryUnits(t *testing.T) {
+	collector := newOTLPCollector(t)
+	o, err := NewOTLP(OTLPOptions{
+		Endpoint:  collector.server.URL,
+		Encoding:  "json",
+		Interval:  time.Hour,
+		BufferDir: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sample := logger.Sample{Type: "memory", Time: time.Now(), Data: &metrics.MemoryStats{
+		Total: 16 << 30, Used: 4 << 30, UsedPercent: 25, SwapTotal: 1 << 30, SwapUsed: 1 << 28, SwapPercent: 25,
+	}}
+	if err := o.Write(sample); err != nil {
+		t.Fatal(err)
+	}
+	o.Close()
+
+	requests := collector.received()
+	if len(requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(requests))
+	}
+	var request otlpRequest
+	if err := json.Unmarshal(requests[0].body, &request); err != nil {
+		t.Fatalf("request isn't OTLP JSON: %v", err)
+	}
+	// Bytes and percentages of the same quantity are separate metrics
+	type sent struct {
+		unit   string
+		values []float64
+	}
+	byName := make(map[string]sent)
+	for _, m := range request.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		if _, exists := byName[m.Name]; exists {
+			t.Errorf("metric %s sent twice", m.Name)
+		}
+		s := sent{unit: m.Unit}
+		for _, p := range m.Gauge.DataPoints {
+			s.values = append(s.values, p.AsDouble)
+		}
+		byName[m.Name] = s
+	}
+	tests := []struct {
+		name, unit string
+		value      float64
+	}{
+		{"steamos.memory_used", "By", 4 << 30},
+		{"steamos.memory_used.percent", "%", 25},
+		{"steamos.swap_used", "By", 1 << 28},
+		{"steamos.swap_used.percent", "%", 25},
+	}
+	for _, tt := range tests {
+		got := byName[tt.name]
+		if got.unit != tt.unit || len(got.values) != 1 || got.values[0] != tt.value {
+			t.Errorf("%s = %v %q, want [%v] %q", tt.name, got.values, got.unit, tt.value, tt.unit)
+		}
+	}
+}
+
+func TestOTLPRetryAfter(t *testing.T) {
+	collector := newOTLPCollector(t)
+	collector.respond(http.StatusServiceUnavailable, "2")
+	bufferDir := t.TempDir()
+	o, err := NewOTLP(OTLPOptions{
+		Endpoint:  collector.server.URL,
+		Encoding:  "json",
+		Interval:  20 * time.Millisecond,
+		BufferDir: bufferDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	writeCPU(t, o, 1)
+	waitFor(t, "the first request", func() bool { return len(collector.received()) == 1 })
+	waitFor(t, "the request to be buffered", func() bool { return bufferedCount(t, bufferDir) == 1 })
+	collector.respond(http.StatusOK, "")
+
+	waitFor(t, "the retry", func() bool { return len(collector.received()) == 2 })
+	requests := collector.received()
+	// Backoff alone would retry within a second
+	if gap := requests[1].at.Sub(requests[0].at); gap < 1900*time.Millisecond {
+		t.Errorf("retried after %s, want the 2s asked for by Retry-After", gap)
+	}
+	if values := cpuUsage(t, requests[1].body); len(values) != 1 || values[0] != 1 {
+		t.Errorf("retried cpu_usage = %v, want [1]", values)
+	}
+	waitFor(t, "the buffer to empty", func() bool { return bufferedCount(t, bufferDir) == 0 })
+}
+
+func TestOTLPBuffersAndReplaysInOrder(t *testing.T) {
+	collector := newOTLPCollector(t)
+	// Retry much later than the test runs
+	collector.respond(http.StatusServiceUnavailable, "3600")
+	bufferDir := t.TempDir()
+	options := OTLPOptions{
+		Endpoint:  collector.server.URL,
+		Encoding:  "json",
+		Interval:  20 * time.Millisecond,
+		BufferDir: bufferDir,
+	}
+	o, err := NewOTLP(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, percent := range []float64{1, 2, 3} {
+		writeCPU(t, o, percent)
+		want := i + 1
+		waitFor(t, "the request to be buffered", func() bool { return bufferedCount(t, bufferDir) == want })
+	}
+	o.Close()
+	if err := o.Close(); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+	// Once a request is buffered, later ones join the buffer unsent
+	if n := len(collector.received()); n != 1 {
+		t.Errorf("%d requests while unreachable, want 1", n)
+	}
+
+	// The next start sends the buffer, oldest first
+	collector.respond(http.StatusOK, "")
+	o, err = NewOTLP(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	waitFor(t, "the buffer to be sent", func() bool { return bufferedCount(t, bufferDir) == 0 })
+
+	var sent []float64
+	for _, r := range collector.received()[1:] {
+		sent = append(sent, cpuUsage(t, r.body)...)
+	}
+	if len(sent) != 3 || sent[0] != 1 || sent[1] != 2 || sent[2] != 3 {
+		t.Errorf("replayed cpu_usage = %v, want [1 2 3]", sent)
+	}
+}
@@ -0,0 +1,178 @@
+package exporter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/steam-os-monitor/monitor/internal/logger"
+)
+
+// MetricPrefix starts the name of every exported metric
+const MetricPrefix = "steamos_"
+
+// Prometheus is a sink keeping the latest metrics of every metric type and
+// serving them in the Prometheus text exposition format
+type Prometheus struct {
+	mu     sync.Mutex
+	latest map[string]tickMetrics // by metric type
+	server *http.Server
+}
+
+// tickMetrics are the metrics of a metric type from one collection cycle,
+// e.g. of every disk
+type tickMetrics struct {
+	tick    uint64
+	metrics []Metric
+}
+
+// NewPrometheus creates a sink serving the metrics at path on addr, e.g.
+// ":9188". It fails if addr can't be listened on.
+func NewPrometheus(addr, path string) (*Prometheus, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for Prometheus on %s: %w", addr, err)
+	}
+
+	p := &Prometheus{latest: make(map[string]tickMetrics)}
+	mux := http.NewServeMux()
+	mux.Handle(path, p)
+	p.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := p.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.WithError(err).Error("Prometheus exporter stopped")
+		}
+	}()
+	return p, nil
+}
+
+// Write replaces the metrics of the sample's type once a new collection
+// cycle starts, so disks or games that went away are no longer exported
+func (p *Prometheus) Write(sample logger.Sample) error {
+	metrics := Metrics(sample)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	current, exists := p.latest[sample.Type]
+	if !exists || current.tick != sample.Tick {
+		current = tickMetrics{tick: sample.Tick}
+	}
+	current.metrics = append(current.metrics, metrics...)
+	p.latest[sample.Type] = current
+	return nil
+}
+
+// Flush does nothing; metrics are served as they are written
+func (p *Prometheus) Flush() error {
+	return nil
+}
+
+// Close stops serving the metrics
+func (p *Prometheus) Close() error {
+	return p.server.Close()
+}
+
+// ServeHTTP writes the latest metrics
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	out := bufio.NewWriter(w)
+	writeExposition(out, p.snapshot())
+	out.Flush()
+}
+
+// snapshot returns the latest metrics, in the order of MetricTypes
+func (p *Prometheus) snapshot() []Metric {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var result []Metric
+	for _, metricType := range logger.MetricTypes {
+		result = append(result, p.latest[metricType].metrics...)
+	}
+	return result
+}
+
+// family is the metrics sharing a name, written under one HELP and TYPE
+type family struct {
+	name    string
+	help    string
+	counter bool
+	metrics []Metric
+}
+
+// PrometheusName returns a metric's name in Prometheus, with the unit and
+// a _total suffix for counters, e.g. steamos_disk_read_bytes_total
+func PrometheusName(m Metric) string {
+	name := MetricPrefix + m.Name
+	if m.Unit != "" {
+		name += "_" + m.Unit
+	}
+	if m.Counter {
+		name += "_total"
+	}
+	return name
+}
+
+// writeExposition writes metrics grouped into families, ordered by name
+func writeExposition(w *bufio.Writer, metrics []Metric) {
+	families := make(map[string]*family)
+	var names []string
+	for _, m := range metrics {
+		name := PrometheusName(m)
+		f, exists := families[name]
+		if !exists {
+			f = &family{name: name, help: m.Help, counter: m.Counter}
+			families[name] = f
+			names = append(names, name)
+		}
+		f.metrics = append(f.metrics, m)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := families[name]
+		kind := "gauge"
+		if f.counter {
+			kind = "counter"
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(w, "# TYPE %s %s\n", f.name, kind)
+		for _, m := range f.metrics {
+			w.WriteString(f.name)
+			if len(m.Labels) > 0 {
+				w.WriteByte('{')
+				for i, label := range m.Labels {
+					if i > 0 {
+						w.WriteByte(',')
+					}
+					fmt.Fprintf(w, "%s=\"%s\"", label.Name, escapeLabelValue(label.Value))
+				}
+				w.WriteByte('}')
+			}
+			w.WriteByte(' ')
+			w.WriteString(strconv.FormatFloat(m.Value, 'g', -1, 64))
+			w.WriteByte('\n')
+		}
+	}
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
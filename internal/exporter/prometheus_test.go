@@ -0,0 +1,66 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/steam-os-monitor/monitor/internal/logger"
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+func TestPrometheusExposition(t *testing.T) {
+	p := &Prometheus{latest: make(map[string]tickMetrics)}
+	p.Write(logger.Sample{Type: "memory", Tick: 1, Data: &metrics.MemoryStats{Total: 16 << 30, Used: 4 << 30, UsedPercent: 25}})
+	// A game installed on the SD card keeps its Proton prefix on the
+	// internal drive
+	p.Write(logger.Sample{Type: "steam_inventory", Tick: 1, Data: &metrics.SteamInventory{Storage: []metrics.AppStorage{
+		{AppID: "620", LibraryPath: "/run/media/mmcblk0p1", InstallBytes: 13 << 30},
+		{AppID: "620", LibraryPath: "/home/deck/.local/share/Steam", CompatDataBytes: 200 << 20},
+	}}})
+
+	recorder := httptest.NewRecorder()
+	p.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+
+	helps := make(map[string]int)
+	types := make(map[string]string)
+	series := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "# HELP "):
+			helps[fields[2]]++
+		case strings.HasPrefix(line, "# TYPE "):
+			if _, exists := types[fields[2]]; exists {
+				t.Errorf("TYPE of %s written twice", fields[2])
+			}
+			types[fields[2]] = fields[3]
+		default:
+			name := line[:strings.LastIndexByte(line, ' ')]
+			if series[name] {
+				t.Errorf("series %s written twice", name)
+			}
+			series[name] = true
+			family := strings.SplitN(name, "{", 2)[0]
+			if helps[family] != 1 || types[family] == "" {
+				t.Errorf("series %s not preceded by one HELP and TYPE of its family", name)
+			}
+		}
+	}
+
+	want := []string{
+		`steamos_memory_used_bytes 4.294967296e+09`,
+		`steamos_memory_used_percent 25`,
+		`steamos_steam_app_storage_bytes{app_id="620",library="/run/media/mmcblk0p1",kind="install"} 1.3958643712e+10`,
+		`steamos_steam_app_storage_bytes{app_id="620",library="/home/deck/.local/share/Steam",kind="compat_data"} 2.097152e+08`,
+	}
+	for _, line := range want {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("exposition lacks %s", line)
+		}
+	}
+	if types["steamos_steam_app_storage_bytes"] != "gauge" {
+		t.Errorf("TYPE of steamos_steam_app_storage_bytes = %q, want gauge", types["steamos_steam_app_storage_bytes"])
+	}
+}
@@ -0,0 +1,208 @@
+package logger
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// What an AsyncSink does when its queue is full
+const (
+	OverflowDrop  = "drop"  // discard the new sample and count it
+	OverflowBlock = "block" // wait for room, delaying the caller
+)
+
+// Defaults for unset AsyncOptions
+const (
+	DefaultQueueSize     = 1024
+	DefaultFlushInterval = time.Second
+)
+
+// dropReportInterval limits how often dropped samples are reported
+const dropReportInterval = time.Minute
+
+// syncMetricTypes are written and flushed before Write returns, whatever
+// the overflow policy: session summaries are rare and can't be collected
+// again
+var syncMetricTypes = map[string]bool{"sessions": true}
+
+// AsyncOptions configures an AsyncSink. Zero values select the defaults.
+type AsyncOptions struct {
+	QueueSize     int
+	FlushInterval time.Duration
+	Overflow      string // OverflowDrop or OverflowBlock
+}
+
+// AsyncStats counts what happened to the samples passed to an AsyncSink
+type AsyncStats struct {
+	Written uint64
+	Dropped uint64 // discarded because the queue was full
+	Failed  uint64 // rejected by the sink
+}
+
+// AsyncSink queues samples and writes them to a sink from its own
+// goroutine, flushing the sink in batches, so slow storage doesn't delay
+// the caller.
+type AsyncSink struct {
+	sink    Sink
+	options AsyncOptions
+	queue   chan queuedSample
+	done    chan struct{}
+
+	// closed guards against writes to the closed queue
+	closed bool
+	mu     sync.RWMutex
+
+	written atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+// queuedSample is a sample waiting to be written. A synchronous write
+// waits on written for the result.
+type queuedSample struct {
+	sample  Sample
+	written chan error
+}
+
+// NewAsyncSink starts writing to sink in the background
+func NewAsyncSink(sink Sink, options AsyncOptions) *AsyncSink {
+	if options.QueueSize <= 0 {
+		options.QueueSize = DefaultQueueSize
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = DefaultFlushInterval
+	}
+	if options.Overflow == "" {
+		options.Overflow = OverflowDrop
+	}
+
+	a := &AsyncSink{
+		sink:    sink,
+		options: options,
+		queue:   make(chan queuedSample, options.QueueSize),
+		done:    make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Write queues a sample. With a full queue it is dropped or waits for room,
+// depending on the overflow policy. Session summaries wait until they are
+// written and flushed, and return the sink's error.
+func (a *AsyncSink) Write(sample Sample) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		return errors.New("sink is closed")
+	}
+
+	if syncMetricTypes[sample.Type] {
+		written := make(chan error, 1)
+		a.queue <- queuedSample{sample, written}
+		return <-written
+	}
+	if a.options.Overflow == OverflowBlock {
+		a.queue <- queuedSample{sample: sample}
+		return nil
+	}
+	select {
+	case a.queue <- queuedSample{sample: sample}:
+	default:
+		a.dropped.Add(1)
+	}
+	return nil
+}
+
+// Flush is a no-op; queued samples are flushed on the flush interval
+func (a *AsyncSink) Flush() error {
+	return nil
+}
+
+// Stats returns the sample counters
+func (a *AsyncSink) Stats() AsyncStats {
+	return AsyncStats{
+		Written: a.written.Load(),
+		Dropped: a.dropped.Load(),
+		Failed:  a.failed.Load(),
+	}
+}
+
+// Close writes the samples still queued, then closes the sink. Closing
+// again only waits for the queue to drain.
+func (a *AsyncSink) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		<-a.done
+		return nil
+	}
+	a.closed = true
+	close(a.queue)
+	a.mu.Unlock()
+
+	<-a.done
+	return a.sink.Close()
+}
+
+func (a *AsyncSink) run() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.options.FlushInterval)
+	defer ticker.Stop()
+
+	var reported uint64
+	var reportedAt time.Time
+	for {
+		select {
+		case queued, ok := <-a.queue:
+			if !ok {
+				a.flush()
+				a.reportDropped(&reported)
+				return
+			}
+			err := a.sink.Write(queued.sample)
+			if err != nil {
+				a.failed.Add(1)
+				logrus.WithError(err).WithField("metric_type", queued.sample.Type).Error("Failed to write sample")
+			} else {
+				a.written.Add(1)
+			}
+			if queued.written != nil {
+				if err == nil {
+					err = a.sink.Flush()
+				}
+				queued.written <- err
+			}
+		case now := <-ticker.C:
+			a.flush()
+			if now.Sub(reportedAt) >= dropReportInterval {
+				a.reportDropped(&reported)
+				reportedAt = now
+			}
+		}
+	}
+}
+
+func (a *AsyncSink) flush() {
+	if err := a.sink.Flush(); err != nil {
+		logrus.WithError(err).Error("Failed to flush sink")
+	}
+}
+
+// reportDropped warns about samples dropped since the last report
+func (a *AsyncSink) reportDropped(reported *uint64) {
+	dropped := a.dropped.Load()
+	if dropped == *reported {
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"dropped": dropped - *reported,
+		"total":   dropped,
+	}).Warn("Log queue full, samples dropped")
+	*reported = dropped
+}
@@ -0,0 +1,186 @@
+package logger
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowSink records the samples written to it after a delay, and whether
+// they were flushed
+type slowSink struct {
+	delay time.Duration
+
+	mu      sync.Mutex
+	written []Sample
+	flushed int // how many of written were flushed
+	closes  int
+}
+
+func (s *slowSink) Write(sample Sample) error {
+	time.Sleep(s.delay)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.written = append(s.written, sample)
+	return nil
+}
+
+func (s *slowSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushed = len(s.written)
+	return nil
+}
+
+func (s *slowSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closes++
+	return nil
+}
+
+func (s *slowSink) count(metricType string) (written, flushed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, sample := range s.written {
+		if sample.Type == metricType {
+			written++
+			if i < s.flushed {
+				flushed++
+			}
+		}
+	}
+	return written, flushed
+}
+
+func TestAsyncSinkNeverDropsSessions(t *testing.T) {
+	sink := &slowSink{delay: time.Millisecond}
+	a := NewAsyncSink(sink, AsyncOptions{QueueSize: 1, FlushInterval: time.Hour, Overflow: OverflowDrop})
+	defer a.Close()
+
+	for i := 0; i < 20; i++ {
+		a.Write(Sample{Type: "cpu"})
+		if i%5 == 0 {
+			if err := a.Write(Sample{Type: "sessions"}); err != nil {
+				t.Fatal(err)
+			}
+			want := i/5 + 1
+			// Written and flushed by the time Write returns
+			if written, flushed := sink.count("sessions"); written != want || flushed != want {
+				t.Fatalf("after %d summaries: %d written, %d flushed", want, written, flushed)
+			}
+		}
+	}
+	if a.Stats().Dropped == 0 {
+		t.Error("no cpu samples dropped, the queue never filled up")
+	}
+}
+
+// gateSink holds every Write until released, and fails samples of the
+// "fail" type
+type gateSink struct {
+	entered chan struct{}
+	release chan struct{}
+	closes  atomic.Int32
+}
+
+func newGateSink() *gateSink {
+	return &gateSink{entered: make(chan struct{}, 100), release: make(chan struct{})}
+}
+
+func (s *gateSink) Write(sample Sample) error {
+	s.entered <- struct{}{}
+	<-s.release
+	if sample.Type == "fail" {
+		return errors.New("rejected")
+	}
+	return nil
+}
+
+func (s *gateSink) Flush() error { return nil }
+
+func (s *gateSink) Close() error {
+	s.closes.Add(1)
+	return nil
+}
+
+func TestAsyncSinkDropPolicy(t *testing.T) {
+	sink := newGateSink()
+	a := NewAsyncSink(sink, AsyncOptions{QueueSize: 2, FlushInterval: time.Hour, Overflow: OverflowDrop})
+
+	// The first sample is taken off the queue and held in the sink, the
+	// next two fill the queue and the rest are dropped
+	a.Write(Sample{Type: "cpu"})
+	<-sink.entered
+	a.Write(Sample{Type: "fail"})
+	for i := 0; i < 6; i++ {
+		if err := a.Write(Sample{Type: "cpu"}); err != nil {
+			t.Fatalf("Write() with a full queue = %v, want the sample dropped silently", err)
+		}
+	}
+	if stats := a.Stats(); stats.Dropped != 5 {
+		t.Errorf("Dropped = %d, want 5", stats.Dropped)
+	}
+
+	close(sink.release)
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := a.Stats(); stats != (AsyncStats{Written: 2, Dropped: 5, Failed: 1}) {
+		t.Errorf("Stats() = %+v, want 2 written, 5 dropped, 1 failed", stats)
+	}
+}
+
+func TestAsyncSinkBlockPolicy(t *testing.T) {
+	sink := newGateSink()
+	a := NewAsyncSink(sink, AsyncOptions{QueueSize: 2, FlushInterval: time.Hour, Overflow: OverflowBlock})
+
+	a.Write(Sample{Type: "cpu"})
+	<-sink.entered
+	a.Write(Sample{Type: "cpu"})
+	a.Write(Sample{Type: "cpu"})
+
+	returned := make(chan struct{})
+	go func() {
+		a.Write(Sample{Type: "cpu"})
+		close(returned)
+	}()
+	select {
+	case <-returned:
+		t.Fatal("Write() with a full queue returned before there was room")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(sink.release)
+	<-returned
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := a.Stats(); stats != (AsyncStats{Written: 4}) {
+		t.Errorf("Stats() = %+v, want 4 written and none dropped", stats)
+	}
+}
+
+func TestAsyncSinkCloseTwice(t *testing.T) {
+	sink := newGateSink()
+	close(sink.release)
+	a := NewAsyncSink(sink, AsyncOptions{})
+
+	a.Write(Sample{Type: "cpu"})
+	for i := 0; i < 2; i++ {
+		if err := a.Close(); err != nil {
+			t.Fatalf("Close() #%d = %v", i+1, err)
+		}
+	}
+	if closes := sink.closes.Load(); closes != 1 {
+		t.Errorf("wrapped sink closed %d times, want once", closes)
+	}
+	if err := a.Write(Sample{Type: "cpu"}); err == nil {
+		t.Error("Write() after Close succeeded")
+	}
+	if stats := a.Stats(); stats.Written != 1 {
+		t.Errorf("Written = %d, want the sample queued before Close", stats.Written)
+	}
+}
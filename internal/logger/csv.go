@@ -0,0 +1,271 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvHeaderScan is how much of the end of an existing CSV log is searched
+// for the header in effect, so appending to it doesn't repeat the header
+const csvHeaderScan = 1024 * 1024
+
+// Leading columns of every CSV row
+const (
+	csvTimestampColumn = "timestamp"
+	csvSessionColumn   = "session_id"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// csvColumn is one flattened value of a metric
+type csvColumn struct {
+	name  string
+	value string
+}
+
+// flattenCSV turns a metric into named columns following its struct layout
+// and JSON names, so the column order is stable:
+//   - nested structs become "parent.field"
+//   - maps become one column per key, "field.key", in sorted key order
+//   - slices of numbers become "field.0", "field.1", ... e.g. per-core usage
+//   - slices of structs become "field.<app_id>.x" when every element has a
+//     distinct app ID, "field.<index>.x" otherwise
+//   - other slices of scalars are joined with ";" in a single column
+func flattenCSV(data interface{}) []csvColumn {
+	var columns []csvColumn
+	flattenValue(&columns, "", reflect.ValueOf(data))
+	return columns
+}
+
+func flattenValue(columns *[]csvColumn, name string, v reflect.Value) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			*columns = append(*columns, csvColumn{name: name})
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Type() == timeType:
+		*columns = append(*columns, csvColumn{name, v.Interface().(time.Time).Format(time.RFC3339Nano)})
+	case v.Type() == durationType:
+		*columns = append(*columns, csvColumn{name, strconv.FormatInt(v.Int(), 10)})
+	case v.Kind() == reflect.Struct:
+		flattenStruct(columns, name, v)
+	case v.Kind() == reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			flattenValue(columns, joinColumn(name, fmt.Sprint(key.Interface())), v.MapIndex(key))
+		}
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		flattenSlice(columns, name, v)
+	default:
+		*columns = append(*columns, csvColumn{name, formatScalar(v)})
+	}
+}
+
+func flattenStruct(columns *[]csvColumn, prefix string, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := fieldName(field)
+		if name == "-" {
+			continue
+		}
+		flattenValue(columns, joinColumn(prefix, name), v.Field(i))
+	}
+}
+
+func flattenSlice(columns *[]csvColumn, name string, v reflect.Value) {
+	elem := v.Type().Elem()
+	for elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+
+	switch elem.Kind() {
+	case reflect.Struct:
+		if elem == timeType {
+			break
+		}
+		keys := sliceKeys(v)
+		for i := 0; i < v.Len(); i++ {
+			flattenValue(columns, joinColumn(name, keys[i]), v.Index(i))
+		}
+		return
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		for i := 0; i < v.Len(); i++ {
+			flattenValue(columns, joinColumn(name, strconv.Itoa(i)), v.Index(i))
+		}
+		return
+	}
+
+	values := make([]string, v.Len())
+	for i := range values {
+		var element []csvColumn
+		flattenValue(&element, "", v.Index(i))
+		if len(element) == 1 {
+			values[i] = element[0].value
+		}
+	}
+	*columns = append(*columns, csvColumn{name, strings.Join(values, ";")})
+}
+
+// sliceKeys names the elements of a slice of structs by their app ID when
+// each has a distinct one, or by index
+func sliceKeys(v reflect.Value) []string {
+	keys := make([]string, v.Len())
+	seen := make(map[string]bool)
+	for i := range keys {
+		key := appIDOf(v.Index(i))
+		if key == "" || seen[key] {
+			for j := range keys {
+				keys[j] = strconv.Itoa(j)
+			}
+			return keys
+		}
+		seen[key] = true
+		keys[i] = key
+	}
+	return keys
+}
+
+// appIDOf returns the value of a struct's app_id field, or ""
+func appIDOf(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if fieldName(t.Field(i)) == "app_id" && v.Field(i).Kind() == reflect.String {
+			return v.Field(i).String()
+		}
+	}
+	return ""
+}
+
+// fieldName returns the JSON name of a struct field
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func joinColumn(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+func formatScalar(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// csvRow builds the header and row for a metric. The timestamp comes from
+// the metric's own timestamp field when it has one, as does the session ID
+// of a metric that isn't tagged with one, e.g. a session summary.
+func csvRow(data interface{}, sessionID string, now time.Time) ([]string, []string) {
+	header := []string{csvTimestampColumn, csvSessionColumn}
+	row := []string{now.Format(time.RFC3339Nano), sessionID}
+	for _, column := range flattenCSV(data) {
+		if column.name == csvTimestampColumn {
+			row[0] = column.value
+			continue
+		}
+		if column.name == csvSessionColumn {
+			if row[1] == "" {
+				row[1] = column.value
+			}
+			continue
+		}
+		header = append(header, column.name)
+		row = append(row, column.value)
+	}
+	return header, row
+}
+
+// lastCSVHeader returns the last header line of an existing CSV log, or nil
+// if none is found near its end
+func lastCSVHeader(path string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return nil
+	}
+	offset := info.Size() - csvHeaderScan
+	if offset < 0 {
+		offset = 0
+	}
+
+	var header []string
+	scanner := bufio.NewScanner(io.NewSectionReader(file, offset, info.Size()-offset))
+	scanner.Buffer(make([]byte, 64*1024), csvHeaderScan)
+	prefix := csvTimestampColumn + "," + csvSessionColumn
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		if record, err := csv.NewReader(strings.NewReader(line)).Read(); err == nil {
+			header = record
+		}
+	}
+	return header
+}
+
+func equalColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
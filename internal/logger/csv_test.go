@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+func TestSessionsCSVHeader(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewFileSink(dir, "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := NewLoggerWithSinks(sink)
+
+	end := time.Date(2024, 1, 2, 16, 0, 0, 0, time.UTC)
+	summary := metrics.SessionSummary{
+		SessionID: "20240102-150000-abcd",
+		AppID:     "620",
+		StartTime: end.Add(-time.Hour),
+		EndTime:   end,
+		Duration:  time.Hour,
+	}
+	if err := log.LogSession(summary); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(filepath.Join(dir, "sessions.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("sessions.log has %d lines, want a header and a row", len(records))
+	}
+
+	header, row := records[0], records[1]
+	seen := make(map[string]bool)
+	for _, name := range header {
+		if seen[name] {
+			t.Errorf("header %v repeats column %q", header, name)
+		}
+		seen[name] = true
+	}
+	if want := []string{csvTimestampColumn, csvSessionColumn}; !reflect.DeepEqual(header[:2], want) {
+		t.Errorf("header starts with %v, want %v", header[:2], want)
+	}
+	// Summaries are logged once the session is no longer tagged, so the
+	// session ID comes from the summary itself
+	if row[1] != summary.SessionID {
+		t.Errorf("session_id = %q, want %q", row[1], summary.SessionID)
+	}
+}
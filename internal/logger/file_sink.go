@@ -0,0 +1,219 @@
+package logger
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FileSink writes each metric type to its own file in a directory, e.g.
+// cpu.log, as NDJSON records, CSV or InfluxDB line protocol. Files can be
+// rotated with SetRotation. Writes are buffered until Flush.
+type FileSink struct {
+	dir        string
+	format     string
+	files      map[string]*logFile
+	csvWriters map[string]*csv.Writer
+	csvHeaders map[string][]string // header in effect per metric type
+	mu         sync.Mutex
+	background sync.WaitGroup         // compression and cleanup of rotated logs
+	cleanups   map[string]*sync.Mutex // serializes the cleanups of each metric type
+}
+
+// NewFileSink opens the log files of all metric types in dir. Format is
+// "json", "csv" or "influx".
+func NewFileSink(dir, format string) (*FileSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	s := &FileSink{
+		dir:        dir,
+		format:     format,
+		files:      make(map[string]*logFile),
+		csvWriters: make(map[string]*csv.Writer),
+		csvHeaders: make(map[string][]string),
+		cleanups:   make(map[string]*sync.Mutex),
+	}
+
+	for _, metricType := range MetricTypes {
+		s.cleanups[metricType] = &sync.Mutex{}
+		if err := s.open(metricType); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("failed to initialize logger for %s: %w", metricType, err)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *FileSink) open(metricType string) error {
+	logPath := filepath.Join(s.dir, fmt.Sprintf("%s.log", metricType))
+
+	file, err := openLogFile(logPath)
+	if err != nil {
+		return err
+	}
+	s.files[metricType] = file
+
+	if s.format == "csv" {
+		s.csvWriters[metricType] = csv.NewWriter(file)
+		s.csvHeaders[metricType] = lastCSVHeader(logPath)
+	}
+	return nil
+}
+
+// SetRotation sets the rotation and retention policy of a metric type's
+// log. Rotated files already on disk are cleaned up right away.
+func (s *FileSink) SetRotation(metricType string, policy RotationPolicy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, exists := s.files[metricType]
+	if !exists {
+		return fmt.Errorf("logger for %s not initialized", metricType)
+	}
+	file.policy = policy
+	s.cleanUp(metricType, "", policy)
+	return nil
+}
+
+// Write appends a sample to its metric type's file
+func (s *FileSink) Write(sample Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.files[sample.Type]; !exists {
+		return fmt.Errorf("logger for %s not initialized", sample.Type)
+	}
+
+	if err := s.rotateIfNeeded(sample.Type); err != nil {
+		return err
+	}
+
+	var line []byte
+	switch s.format {
+	case "csv":
+		return s.writeCSV(sample)
+	case "influx":
+		line = marshalInflux(sample)
+	default:
+		var err error
+		if line, err = marshalRecord(sample); err != nil {
+			return err
+		}
+	}
+	if _, err := s.files[sample.Type].Write(line); err != nil {
+		return fmt.Errorf("failed to write %s record: %w", sample.Type, err)
+	}
+	return nil
+}
+
+func (s *FileSink) writeCSV(sample Sample) error {
+	writer := s.csvWriters[sample.Type]
+
+	// Flatten the metric into columns and start a new header whenever
+	// they differ from the previous row's, e.g. when a download starts
+	header, row := csvRow(sample.Data, sample.SessionID, sample.Time)
+	if !equalColumns(header, s.csvHeaders[sample.Type]) {
+		if err := writer.Write(header); err != nil {
+			return fmt.Errorf("failed to write CSV header: %w", err)
+		}
+		s.csvHeaders[sample.Type] = header
+	}
+
+	if err := writer.Write(row); err != nil {
+		return fmt.Errorf("failed to write CSV row: %w", err)
+	}
+
+	// Hand the row to the file's buffer, which keeps track of the size
+	writer.Flush()
+	return writer.Error()
+}
+
+// Flush writes buffered samples to their files
+func (s *FileSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for metricType, file := range s.files {
+		if err := file.Flush(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to flush log file for %s: %w", metricType, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rotateIfNeeded starts a new file for a metric type once the current one
+// reached its size or age limit. The rotated file is compressed and old
+// ones are deleted in the background.
+func (s *FileSink) rotateIfNeeded(metricType string) error {
+	file := s.files[metricType]
+	now := time.Now()
+	if !file.needsRotation(now) {
+		return nil
+	}
+
+	if writer, exists := s.csvWriters[metricType]; exists {
+		writer.Flush()
+	}
+	rotated, err := file.rotate(now)
+	if err != nil {
+		return err
+	}
+	// The new file needs its own CSV header
+	s.csvHeaders[metricType] = nil
+
+	s.cleanUp(metricType, rotated, file.policy)
+	return nil
+}
+
+// cleanUp compresses a rotated log, if given, and applies the retention
+// limits in the background. Failures are logged as diagnostics since there
+// is no caller left to return them to.
+func (s *FileSink) cleanUp(metricType, rotated string, policy RotationPolicy) {
+	// A cleanup still compressing an earlier file of the same metric type
+	// would have its input deleted or counted twice by this one's retention
+	lock := s.cleanups[metricType]
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		lock.Lock()
+		defer lock.Unlock()
+
+		if rotated != "" {
+			if err := compressLog(rotated, policy.Compression); err != nil {
+				logrus.WithError(err).WithField("path", rotated).Error("Failed to compress rotated log")
+			}
+		}
+		if err := enforceRetention(s.dir, metricType, policy, time.Now()); err != nil {
+			logrus.WithError(err).WithField("metric_type", metricType).Error("Failed to clean up rotated logs")
+		}
+	}()
+}
+
+// Close closes all log files once pending compression has finished
+func (s *FileSink) Close() error {
+	s.background.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for metricType, file := range s.files {
+		if writer, exists := s.csvWriters[metricType]; exists {
+			writer.Flush()
+		}
+		if err := file.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close log file for %s: %w", metricType, err))
+		}
+	}
+	return errors.Join(errs...)
+}
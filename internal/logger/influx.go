@@ -0,0 +1,262 @@
+package logger
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// influxTags names the fields that become tags of a measurement rather
+// than fields. Lists of structs are measurements of their own, named
+// "<metric type>_<field>", e.g. steam_inventory_apps.
+var influxTags = map[string][]string{
+	"disk":                      {"device", "mount_point"},
+	"network":                   {"interface"},
+	"game_performance":          {"app_id"},
+	"sessions":                  {"app_id"},
+	"steam_inventory_apps":      {"app_id"},
+	"steam_libraries":           {"path", "device", "mount_point"},
+	"steam_inventory_storage":   {"app_id", "library_path"},
+	"steam_inventory_shortcuts": {"app_id", "user_id"},
+	"steam_inventory_playtime":  {"app_id", "user_id"},
+	"steam_inventory_orphaned":  {"kind", "app_id", "library_path"},
+}
+
+// influxKeyTags names the tag that tells apart the values of a list of
+// numbers or a map, e.g. per-core usage, which become one point each
+var influxKeyTags = map[string]string{
+	"cpu.per_core_percent":    "core",
+	"steam.download_progress": "app_id",
+}
+
+// influxPoint is one line of line protocol
+type influxPoint struct {
+	measurement string
+	tags        map[string]string
+	fields      []string // encoded key=value pairs
+}
+
+// marshalInflux encodes a sample as InfluxDB line protocol, one point per
+// line with nanosecond timestamps:
+//   - the measurement is the metric type, tagged with host and session_id
+//   - scalar fields become fields, except those listed in influxTags
+//   - lists of numbers and maps become a point per element, tagged by the
+//     influxKeyTags name, e.g. cpu,core=3 per_core_percent=12.5
+//   - lists of structs become a point per element in a measurement of
+//     their own, e.g. steam_inventory_apps,app_id=620
+//   - other lists are joined with "," in a string field
+func marshalInflux(sample Sample) []byte {
+	v := reflect.ValueOf(sample.Data)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	tags := map[string]string{"host": sample.Host}
+	if sample.SessionID != "" {
+		tags["session_id"] = sample.SessionID
+	}
+	var points []influxPoint
+	influxStruct(&points, sample.Type, tags, v)
+
+	timestamp := strconv.FormatInt(sample.Time.UnixNano(), 10)
+	var line []byte
+	for _, p := range points {
+		if len(p.fields) == 0 {
+			continue
+		}
+		line = append(line, influxMeasurementEscaper.Replace(p.measurement)...)
+		keys := make([]string, 0, len(p.tags))
+		for key, value := range p.tags {
+			if value != "" {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			line = append(line, ',')
+			line = append(line, influxKeyEscaper.Replace(key)...)
+			line = append(line, '=')
+			line = append(line, influxKeyEscaper.Replace(p.tags[key])...)
+		}
+		line = append(line, ' ')
+		line = append(line, strings.Join(p.fields, ",")...)
+		line = append(line, ' ')
+		line = append(line, timestamp...)
+		line = append(line, '\n')
+	}
+	return line
+}
+
+// influxStruct adds the point of a struct and those of its lists
+func influxStruct(points *[]influxPoint, measurement string, parentTags map[string]string, v reflect.Value) {
+	tags := copyTags(parentTags)
+	isTag := make(map[string]bool)
+	for _, name := range influxTags[measurement] {
+		isTag[name] = true
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() && isTag[fieldName(field)] {
+			tags[fieldName(field)] = formatScalar(v.Field(i))
+		}
+	}
+
+	point := influxPoint{measurement: measurement, tags: tags}
+	var children []func()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := fieldName(field)
+		fv := v.Field(i)
+		if !field.IsExported() || name == "-" || tags[name] != "" {
+			continue
+		}
+		// The point's own timestamp is the metric's
+		if fv.Type() == timeType && name == csvTimestampColumn {
+			continue
+		}
+		if value, ok := influxValue(fv); ok {
+			point.fields = append(point.fields, influxKeyEscaper.Replace(name)+"="+value)
+			continue
+		}
+
+		// Points of the elements follow the struct's own
+		switch {
+		case fv.Kind() == reflect.Map && isNumber(fv.Type().Elem()):
+			children = append(children, func() { influxKeyed(points, measurement, name, tags, fv) })
+		case fv.Kind() == reflect.Slice && isNumber(fv.Type().Elem()):
+			children = append(children, func() { influxKeyed(points, measurement, name, tags, fv) })
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Struct && fv.Type().Elem() != timeType:
+			children = append(children, func() {
+				for j := 0; j < fv.Len(); j++ {
+					influxStruct(points, measurement+"_"+name, parentTags, fv.Index(j))
+				}
+			})
+		case fv.Kind() == reflect.Slice:
+			values := make([]string, fv.Len())
+			for j := range values {
+				values[j] = formatScalar(fv.Index(j))
+			}
+			point.fields = append(point.fields, influxKeyEscaper.Replace(name)+"="+influxString(strings.Join(values, ",")))
+		}
+	}
+
+	*points = append(*points, point)
+	for _, child := range children {
+		child()
+	}
+}
+
+// influxKeyed adds a point per element of a list of numbers or a map,
+// tagged with its index or key
+func influxKeyed(points *[]influxPoint, measurement, name string, tags map[string]string, v reflect.Value) {
+	tag := influxKeyTags[measurement+"."+name]
+	if tag == "" {
+		tag = "key"
+	}
+	add := func(key string, value reflect.Value) {
+		encoded, ok := influxValue(value)
+		if !ok {
+			return
+		}
+		elementTags := copyTags(tags)
+		elementTags[tag] = key
+		*points = append(*points, influxPoint{
+			measurement: measurement,
+			tags:        elementTags,
+			fields:      []string{influxKeyEscaper.Replace(name) + "=" + encoded},
+		})
+	}
+
+	if v.Kind() == reflect.Map {
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			add(fmt.Sprint(key.Interface()), v.MapIndex(key))
+		}
+		return
+	}
+	for i := 0; i < v.Len(); i++ {
+		add(strconv.Itoa(i), v.Index(i))
+	}
+}
+
+// influxValue encodes a scalar as a field value: floats as is, integers
+// with an "i" suffix, durations in nanoseconds and times as RFC 3339
+// strings. Non-finite floats and zero times have no value.
+func influxValue(v reflect.Value) (string, bool) {
+	switch {
+	case v.Type() == timeType:
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return "", false
+		}
+		return influxString(t.Format(time.RFC3339Nano)), true
+	case v.Type() == durationType:
+		return strconv.FormatInt(v.Int(), 10) + "i", true
+	}
+
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", false
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10) + "i", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// Unsigned integers aren't supported everywhere
+		if u := v.Uint(); u <= math.MaxInt64 {
+			return strconv.FormatUint(u, 10) + "i", true
+		}
+		return strconv.FormatFloat(float64(v.Uint()), 'g', -1, 64), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.String:
+		return influxString(v.String()), true
+	}
+	return "", false
+}
+
+func isNumber(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	c := make(map[string]string, len(tags))
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
+}
+
+// Line protocol escaping. Newlines can't be escaped, so they are written
+// as \n.
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	influxKeyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+	influxStringEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+func influxString(s string) string {
+	return `"` + influxStringEscaper.Replace(s) + `"`
+}
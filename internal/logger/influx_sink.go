@@ -0,0 +1,206 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Limits of the line protocol sinks
+const (
+	// influxMaxPending bounds the lines an InfluxHTTPSink keeps while the
+	// endpoint is unreachable; the oldest are dropped beyond it
+	influxMaxPending  = 16 * 1024 * 1024
+	influxHTTPTimeout = 10 * time.Second
+	// influxMaxDatagram keeps UDP packets within a typical MTU
+	influxMaxDatagram = 1400
+)
+
+// InfluxHTTPSink posts samples as InfluxDB line protocol to a write
+// endpoint, e.g. InfluxDB's /api/v2/write or Telegraf's influxdb_listener,
+// with one request per Flush. While the endpoint is unreachable, lines are
+// kept and sent with a later Flush.
+type InfluxHTTPSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	pending []byte
+	failing bool // the last Flush failed and was reported
+	mu      sync.Mutex
+}
+
+// NewInfluxHTTPSink creates a sink posting to url, e.g.
+// http://influxdb:8086/api/v2/write?org=o&bucket=b, with the given
+// headers, e.g. Authorization
+func NewInfluxHTTPSink(url string, headers map[string]string) *InfluxHTTPSink {
+	return &InfluxHTTPSink{url: url, headers: headers, client: &http.Client{Timeout: influxHTTPTimeout}}
+}
+
+// Write buffers a sample's lines until Flush
+func (s *InfluxHTTPSink) Write(sample Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = append(s.pending, marshalInflux(sample)...)
+	if len(s.pending) > influxMaxPending {
+		s.pending = dropOldestLines(s.pending, len(s.pending)-influxMaxPending)
+	}
+	return nil
+}
+
+// Flush posts the buffered lines. Only the first of consecutive failures
+// is returned, so an unreachable endpoint is reported once.
+func (s *InfluxHTTPSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) == 0 {
+		return nil
+	}
+	retry, err := s.post(s.pending)
+	if err == nil {
+		if s.failing {
+			logrus.WithField("url", s.url).Info("InfluxDB endpoint reachable again")
+		}
+		s.pending, s.failing = s.pending[:0], false
+		return nil
+	}
+	if !retry {
+		// The endpoint won't take these lines later either
+		s.pending = s.pending[:0]
+		return err
+	}
+	if s.failing {
+		return nil
+	}
+	s.failing = true
+	return fmt.Errorf("%w; keeping samples until it is reachable", err)
+}
+
+// post sends lines, reporting whether a failure is worth retrying
+func (s *InfluxHTTPSink) post(lines []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(lines))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to post to InfluxDB: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("InfluxDB endpoint returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, err
+}
+
+// Close makes a last attempt to post the buffered lines
+func (s *InfluxHTTPSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) == 0 {
+		return nil
+	}
+	_, err := s.post(s.pending)
+	s.pending = nil
+	return err
+}
+
+// dropOldestLines removes at least n bytes of whole lines from the front
+func dropOldestLines(lines []byte, n int) []byte {
+	if n >= len(lines) {
+		return lines[:0]
+	}
+	if i := bytes.IndexByte(lines[n:], '\n'); i >= 0 {
+		return append(lines[:0], lines[n+i+1:]...)
+	}
+	return lines[:0]
+}
+
+// InfluxUDPSink sends samples as InfluxDB line protocol in UDP datagrams,
+// e.g. to Telegraf's socket_listener or InfluxDB 1.x's UDP input. Lines
+// are batched into datagrams on Flush; what isn't received is lost.
+type InfluxUDPSink struct {
+	conn    net.Conn
+	pending []byte
+	mu      sync.Mutex
+}
+
+// NewInfluxUDPSink creates a sink sending to addr, e.g. "telegraf:8094"
+func NewInfluxUDPSink(addr string) (*InfluxUDPSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open UDP socket to %s: %w", addr, err)
+	}
+	return &InfluxUDPSink{conn: conn}, nil
+}
+
+// Write buffers a sample's lines until Flush
+func (s *InfluxUDPSink) Write(sample Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = append(s.pending, marshalInflux(sample)...)
+	return nil
+}
+
+// Flush sends the buffered lines, as many whole lines per datagram as fit
+func (s *InfluxUDPSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lines := s.pending
+	s.pending = s.pending[:0]
+	var firstErr error
+	for len(lines) > 0 {
+		size := datagramSize(lines)
+		if _, err := s.conn.Write(lines[:size]); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to send to %s: %w", s.conn.RemoteAddr(), err)
+		}
+		lines = lines[size:]
+	}
+	return firstErr
+}
+
+// datagramSize returns the length of the whole lines at the start of lines
+// that fit a datagram, or of the first line if it alone is longer
+func datagramSize(lines []byte) int {
+	size := 0
+	for size < len(lines) {
+		end := bytes.IndexByte(lines[size:], '\n')
+		if end < 0 {
+			end = len(lines) - size - 1
+		}
+		next := size + end + 1
+		if next > influxMaxDatagram && size > 0 {
+			break
+		}
+		size = next
+	}
+	return size
+}
+
+// Close sends the buffered lines and closes the socket
+func (s *InfluxUDPSink) Close() error {
+	err := s.Flush()
+	if closeErr := s.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
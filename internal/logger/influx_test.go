@@ -0,0 +1,161 @@
+package logger
+
+import (
+	"bytes"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalInflux(t *testing.T) {
+	at := time.Unix(1704207845, 0)
+	tests := []struct {
+		name   string
+		sample Sample
+		want   string
+	}{
+		{
+			name: "escaping",
+			sample: Sample{Type: "disk", Host: "steam deck", SessionID: "s,1", Time: at, Data: &struct {
+				Device     string  `json:"device"`
+				MountPoint string  `json:"mount_point"`
+				Label      string  `json:"label"`
+				Odd        float64 `json:"odd key=x"`
+			}{`/dev/a,b=c d`, "/run/media/\"SD\"\ncard", "say \"hi\"\n\\path", 1.5}},
+			// Quotes are literal in tags, newlines can't be escaped anywhere
+			want: `disk,device=/dev/a\,b\=c\ d,host=steam\ deck,mount_point=/run/media/"SD"\ncard,session_id=s\,1 label="say \"hi\"\n\\path",odd\ key\=x=1.5 1704207845000000000` + "\n",
+		},
+		{
+			name:   "measurement",
+			sample: Sample{Type: "odd type,x", Host: "deck", Time: at, Data: struct{ V int }{1}},
+			want:   `odd\ type\,x,host=deck V=1i 1704207845000000000` + "\n",
+		},
+		{
+			name: "numbers",
+			sample: Sample{Type: "cpu", Host: "deck", Time: at, Data: &struct {
+				Small    uint64
+				Big      uint64
+				Negative int32
+				NaN      float64
+				Inf      float64
+				Ratio    float32
+				Up       bool
+				Took     time.Duration
+				At       time.Time
+			}{5, math.MaxUint64, -3, math.NaN(), math.Inf(-1), 0.5, true, 1500 * time.Millisecond, time.Time{}}},
+			// Unsigned values beyond int64 become floats; non-finite values
+			// and zero times are left out
+			want: "cpu,host=deck Small=5i,Big=1.8446744073709552e+19,Negative=-3i,Ratio=0.5,Up=true,Took=1500000000i 1704207845000000000\n",
+		},
+		{
+			name:   "only non-finite",
+			sample: Sample{Type: "cpu", Host: "deck", Time: at, Data: &struct{ NaN float64 }{math.NaN()}},
+			want:   "",
+		},
+		{
+			name: "keyed",
+			sample: Sample{Type: "cpu", Host: "deck", Time: at, Data: &struct {
+				Overall float64            `json:"overall_percent"`
+				PerCore []float64          `json:"per_core_percent"`
+				Temps   map[string]float64 `json:"temps"`
+				Names   []string           `json:"names"`
+			}{50, []float64{10, math.NaN(), 30}, map[string]float64{"soc 1": 55, "gpu": 60}, []string{"a", "b"}}},
+			want: "cpu,host=deck overall_percent=50,names=\"a,b\" 1704207845000000000\n" +
+				"cpu,core=0,host=deck per_core_percent=10 1704207845000000000\n" +
+				"cpu,core=2,host=deck per_core_percent=30 1704207845000000000\n" +
+				"cpu,host=deck,key=gpu temps=60 1704207845000000000\n" +
+				`cpu,host=deck,key=soc\ 1 temps=55 1704207845000000000` + "\n",
+		},
+		{
+			name: "structs",
+			sample: Sample{Type: "steam_inventory", Host: "deck", Time: at, Data: &struct {
+				Count int `json:"count"`
+				Apps  []struct {
+					AppID string `json:"app_id"`
+					Size  uint64 `json:"size"`
+				} `json:"apps"`
+			}{Count: 2, Apps: []struct {
+				AppID string `json:"app_id"`
+				Size  uint64 `json:"size"`
+			}{{"620", 10}, {"570", 20}}}},
+			want: "steam_inventory,host=deck count=2i 1704207845000000000\n" +
+				"steam_inventory_apps,app_id=620,host=deck size=10i 1704207845000000000\n" +
+				"steam_inventory_apps,app_id=570,host=deck size=20i 1704207845000000000\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := string(marshalInflux(tt.sample)); got != tt.want {
+			t.Errorf("%s: marshalInflux() =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDatagramSize(t *testing.T) {
+	line := func(n int) string {
+		return strings.Repeat("x", n-1) + "\n"
+	}
+	tests := []struct {
+		name  string
+		lines string
+		want  int
+	}{
+		{"two of three fit", line(500) + line(500) + line(500), 1000},
+		{"exactly full", line(700) + line(700) + line(10), influxMaxDatagram},
+		{"first line too long", line(2000) + line(10), 2000},
+		{"unterminated", "abc", 3},
+	}
+	for _, tt := range tests {
+		if got := datagramSize([]byte(tt.lines)); got != tt.want {
+			t.Errorf("%s: datagramSize() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInfluxUDPSinkSplitsDatagrams(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	sink, err := NewInfluxUDPSink(listener.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	var sent []byte
+	for i := 0; i < 5; i++ {
+		sample := Sample{Type: "cpu", Host: "deck", Time: time.Unix(int64(i), 0), Data: &struct {
+			Text string `json:"text"`
+		}{strings.Repeat("x", 600)}}
+		sent = append(sent, marshalInflux(sample)...)
+		sink.Write(sample)
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two lines of about 640 bytes fit a datagram
+	var received []byte
+	buf := make([]byte, 64*1024)
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i < 3; i++ {
+		n, _, err := listener.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("datagram %d: %v", i+1, err)
+		}
+		datagram := buf[:n]
+		if n > influxMaxDatagram || !bytes.HasSuffix(datagram, []byte("\n")) {
+			t.Errorf("datagram %d of %d bytes isn't whole lines within %d bytes", i+1, n, influxMaxDatagram)
+		}
+		if lines, want := bytes.Count(datagram, []byte("\n")), 2-i/2; lines != want {
+			t.Errorf("datagram %d holds %d lines, want %d", i+1, lines, want)
+		}
+		received = append(received, datagram...)
+	}
+	if !bytes.Equal(received, sent) {
+		t.Error("datagrams don't add up to the lines written")
+	}
+}
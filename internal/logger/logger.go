@@ -1,75 +1,40 @@
 package logger
 
 import (
-	"encoding/csv"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"os"
-	"path/filepath"
 	"sync"
 	"time"
-
-	"github.com/sirupsen/logrus"
 )
 
-// Logger handles logging to separate files for different metric types
+// Logger passes metrics of the different metric types on to its sinks
 type Logger struct {
-	logDir   string
-	format   string
-	loggers  map[string]*logrus.Logger
-	fileHandles map[string]*os.File
-	csvWriters map[string]*csv.Writer
-	mu       sync.Mutex
-}
-
-// NewLogger creates a new logger instance
-func NewLogger(logDir, format string) (*Logger, error) {
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create log directory: %w", err)
-	}
-
-	l := &Logger{
-		logDir:      logDir,
-		format:      format,
-		loggers:     make(map[string]*logrus.Logger),
-		fileHandles: make(map[string]*os.File),
-		csvWriters:  make(map[string]*csv.Writer),
-	}
-
-	// Initialize loggers for each metric type
-	metricTypes := []string{"cpu", "memory", "disk", "network", "game_performance", "steam"}
-	for _, metricType := range metricTypes {
-		if err := l.initLogger(metricType); err != nil {
-			return nil, fmt.Errorf("failed to initialize logger for %s: %w", metricType, err)
-		}
-	}
-
-	return l, nil
+	sinks     []Sink
+	host      string
+	tick      uint64
+	sessionID string
+	mu        sync.Mutex
 }
 
-func (l *Logger) initLogger(metricType string) error {
-	logPath := filepath.Join(l.logDir, fmt.Sprintf("%s.log", metricType))
+// MetricTypes lists the metric types that are logged, each to its own file
+var MetricTypes = []string{"cpu", "memory", "disk", "network", "game_performance", "steam", "steam_inventory", "sensors", "sessions"}
 
-	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+// NewLogger creates a logger writing one file per metric type to logDir in
+// the background
+func NewLogger(logDir, format string) (*Logger, error) {
+	sink, err := NewFileSink(logDir, format)
 	if err != nil {
-		return fmt.Errorf("failed to open log file: %w", err)
-	}
-
-	logger := logrus.New()
-	logger.SetOutput(file)
-	logger.SetFormatter(&logrus.JSONFormatter{
-		TimestampFormat: time.RFC3339Nano,
-	})
-
-	l.fileHandles[metricType] = file
-	l.loggers[metricType] = logger
-
-	if l.format == "csv" {
-		writer := csv.NewWriter(file)
-		l.csvWriters[metricType] = writer
+		return nil, err
 	}
+	return NewLoggerWithSinks(NewAsyncSink(sink, AsyncOptions{})), nil
+}
 
-	return nil
+// NewLoggerWithSinks creates a logger passing every metric to all sinks.
+// Sinks are written to from the caller's goroutine, so slow ones should be
+// wrapped in an AsyncSink.
+func NewLoggerWithSinks(sinks ...Sink) *Logger {
+	host, _ := os.Hostname()
+	return &Logger{sinks: sinks, host: host}
 }
 
 // LogCPU logs CPU metrics
@@ -102,82 +67,83 @@ func (l *Logger) LogSteam(data interface{}) error {
 	return l.log("steam", data)
 }
 
-func (l *Logger) log(metricType string, data interface{}) error {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+// LogSteamInventory logs the per-app state of the Steam installation
+func (l *Logger) LogSteamInventory(data interface{}) error {
+	return l.log("steam_inventory", data)
+}
 
-	logger, exists := l.loggers[metricType]
-	if !exists {
-		return fmt.Errorf("logger for %s not initialized", metricType)
-	}
+// LogSensors logs thermal, clock, power and battery metrics
+func (l *Logger) LogSensors(data interface{}) error {
+	return l.log("sensors", data)
+}
 
-	if l.format == "csv" {
-		return l.logCSV(metricType, data)
-	}
+// LogSession logs a completed play session summary
+func (l *Logger) LogSession(data interface{}) error {
+	return l.log("sessions", data)
+}
 
-	// JSON format
-	logger.WithFields(logrus.Fields{
-		"metric": data,
-	}).Info("metric")
+// SetSessionID tags every subsequently logged metric with a play session ID.
+// An empty ID stops tagging.
+func (l *Logger) SetSessionID(sessionID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	return nil
+	l.sessionID = sessionID
 }
 
-func (l *Logger) logCSV(metricType string, data interface{}) error {
-	writer, exists := l.csvWriters[metricType]
-	if !exists {
-		return fmt.Errorf("CSV writer for %s not initialized", metricType)
-	}
+// NextTick starts a new collection cycle. Metrics logged until the next
+// call share its tick ID.
+func (l *Logger) NextTick() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	// Convert data to JSON first, then parse for CSV
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("failed to marshal data: %w", err)
-	}
+	l.tick++
+}
 
-	var dataMap map[string]interface{}
-	if err := json.Unmarshal(jsonData, &dataMap); err != nil {
-		return fmt.Errorf("failed to unmarshal data: %w", err)
+// log writes a metric to every sink. A failing sink doesn't keep the
+// metric from the others; all errors are returned together.
+func (l *Logger) log(metricType string, data interface{}) error {
+	l.mu.Lock()
+	sample := Sample{
+		Type:      metricType,
+		Host:      l.host,
+		Tick:      l.tick,
+		SessionID: l.sessionID,
+		Data:      data,
 	}
+	l.mu.Unlock()
 
-	// Extract timestamp
-	timestamp := time.Now().Format(time.RFC3339Nano)
-	if ts, ok := dataMap["timestamp"]; ok {
-		if tsStr, ok := ts.(string); ok {
-			timestamp = tsStr
-		}
+	sample.Time = metricTime(data)
+	if sample.Time.IsZero() {
+		sample.Time = time.Now()
 	}
 
-	// Create CSV row
-	row := []string{timestamp}
-	for key, value := range dataMap {
-		if key != "timestamp" {
-			row = append(row, fmt.Sprintf("%v", value))
+	var errs []error
+	for _, sink := range l.sinks {
+		if err := sink.Write(sample); err != nil {
+			errs = append(errs, err)
 		}
 	}
-
-	if err := writer.Write(row); err != nil {
-		return fmt.Errorf("failed to write CSV row: %w", err)
-	}
-
-	writer.Flush()
-	return writer.Error()
+	return errors.Join(errs...)
 }
 
-// Close closes all log file handles
+// Close closes all sinks
 func (l *Logger) Close() error {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	for metricType, file := range l.fileHandles {
-		if writer, exists := l.csvWriters[metricType]; exists {
-			writer.Flush()
-		}
-		if err := file.Close(); err != nil {
-			return fmt.Errorf("failed to close log file for %s: %w", metricType, err)
+	var errs []error
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
 		}
 	}
-
-	return nil
+	return errors.Join(errs...)
 }
 
+// ValidMetricType reports whether metricType is one of MetricTypes
+func ValidMetricType(metricType string) bool {
+	for _, t := range MetricTypes {
+		if t == metricType {
+			return true
+		}
+	}
+	return false
+}
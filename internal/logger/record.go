@@ -0,0 +1,101 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// RecordSchemaVersion is the version of the NDJSON record format. It is
+// raised whenever a record field is renamed or changes meaning.
+const RecordSchemaVersion = 1
+
+// Record is one line of a JSON log: a metric with the context it was
+// logged in. Fields holds the metric itself, without its own timestamp.
+type Record struct {
+	Schema    int             `json:"schema"`
+	Type      string          `json:"type"`
+	Host      string          `json:"host"`
+	Tick      uint64          `json:"tick"`
+	Time      time.Time       `json:"time"`
+	SessionID string          `json:"session_id,omitempty"`
+	Fields    json.RawMessage `json:"fields"`
+}
+
+// newRecord encodes a sample as a record
+func newRecord(sample Sample) (Record, error) {
+	fields, err := json.Marshal(sample.Data)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to encode %s sample: %w", sample.Type, err)
+	}
+	fields, err = dropTimestamp(fields)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to encode %s sample: %w", sample.Type, err)
+	}
+
+	return Record{
+		Schema:    RecordSchemaVersion,
+		Type:      sample.Type,
+		Host:      sample.Host,
+		Tick:      sample.Tick,
+		Time:      sample.Time,
+		SessionID: sample.SessionID,
+		Fields:    fields,
+	}, nil
+}
+
+// marshalRecord encodes a sample as a single NDJSON line
+func marshalRecord(sample Sample) ([]byte, error) {
+	record, err := newRecord(sample)
+	if err != nil {
+		return nil, err
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s record: %w", sample.Type, err)
+	}
+	return append(line, '\n'), nil
+}
+
+// dropTimestamp removes the top-level timestamp of an encoded metric, since
+// the record carries it. Anything but a JSON object is returned as is.
+func dropTimestamp(fields []byte) ([]byte, error) {
+	if !bytes.HasPrefix(fields, []byte("{")) {
+		return fields, nil
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(fields, &object); err != nil {
+		return nil, err
+	}
+	if _, exists := object[csvTimestampColumn]; !exists {
+		return fields, nil
+	}
+	delete(object, csvTimestampColumn)
+	return json.Marshal(object)
+}
+
+// metricTime returns a metric's own timestamp field, or the zero time if
+// it has none or it isn't set
+func metricTime(data interface{}) time.Time {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return time.Time{}
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return time.Time{}
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() && field.Type == timeType && fieldName(field) == csvTimestampColumn {
+			return v.Field(i).Interface().(time.Time)
+		}
+	}
+	return time.Time{}
+}
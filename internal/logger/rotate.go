@@ -0,0 +1,297 @@
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression formats for rotated logs
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// rotatedTimeFormat is the timestamp in a rotated log's name, e.g.
+// cpu-20240102-150405.log.gz
+const rotatedTimeFormat = "20060102-150405"
+
+// startTimeScan is how much of the start of an existing log is searched for
+// the time of its first record
+const startTimeScan = 64 * 1024
+
+// RotationPolicy controls when a metric log is rotated and how long rotated
+// files are kept. Zero values disable the respective limit.
+type RotationPolicy struct {
+	MaxSize      int64         // rotate once the file reaches this many bytes
+	MaxAge       time.Duration // rotate once the file's first record is this old
+	Compression  string        // CompressionNone, CompressionGzip or CompressionZstd
+	Retention    time.Duration // delete rotated files older than this
+	MaxTotalSize int64         // delete the oldest rotated files beyond this many bytes
+}
+
+// logFile is the open log file of one metric type. Records and the CSV
+// writer write through it, so it can be swapped for a fresh file on
+// rotation without them noticing. Writes are buffered until Flush.
+type logFile struct {
+	path      string
+	file      *os.File
+	buf       *bufio.Writer
+	size      int64
+	startedAt time.Time // time of the first record, for the age limit
+	policy    RotationPolicy
+}
+
+// openLogFile opens path for appending. The age of an existing file counts
+// from its first record, so restarting the monitor doesn't postpone its
+// rotation.
+func openLogFile(path string) (*logFile, error) {
+	f := &logFile{path: path}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *logFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	f.file = file
+	f.buf = bufio.NewWriter(file)
+	f.size = info.Size()
+	f.startedAt = time.Now()
+	if f.size > 0 {
+		f.startedAt = logStartTime(f.path, info.ModTime())
+	}
+	return nil
+}
+
+// logStartTime returns the time of the first record in an existing log of
+// any format, or modTime if none can be found
+func logStartTime(path string, modTime time.Time) time.Time {
+	file, err := os.Open(path)
+	if err != nil {
+		return modTime
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(io.LimitReader(file, startTimeScan))
+	scanner.Buffer(make([]byte, 4096), startTimeScan)
+	for scanner.Scan() {
+		if t, ok := recordTime(scanner.Text()); ok {
+			return t
+		}
+	}
+	return modTime
+}
+
+// recordTime parses the time of a JSON record, a CSV row or an InfluxDB
+// line. CSV header lines have none.
+func recordTime(line string) (time.Time, bool) {
+	if strings.HasPrefix(line, "{") {
+		var record struct {
+			Time time.Time `json:"time"`
+		}
+		if json.Unmarshal([]byte(line), &record) != nil || record.Time.IsZero() {
+			return time.Time{}, false
+		}
+		return record.Time, true
+	}
+	first, _, _ := strings.Cut(line, ",")
+	if t, err := time.Parse(time.RFC3339Nano, first); err == nil {
+		return t, true
+	}
+	if i := strings.LastIndexByte(line, ' '); i >= 0 {
+		if ns, err := strconv.ParseInt(line[i+1:], 10, 64); err == nil {
+			return time.Unix(0, ns), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Write appends to the current file
+func (f *logFile) Write(p []byte) (int, error) {
+	n, err := f.buf.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Flush writes buffered data to the current file
+func (f *logFile) Flush() error {
+	return f.buf.Flush()
+}
+
+// Close flushes and closes the current file
+func (f *logFile) Close() error {
+	err := f.buf.Flush()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// needsRotation reports whether the file reached its size or age limit
+func (f *logFile) needsRotation(now time.Time) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.policy.MaxSize > 0 && f.size >= f.policy.MaxSize {
+		return true
+	}
+	return f.policy.MaxAge > 0 && now.Sub(f.startedAt) >= f.policy.MaxAge
+}
+
+// rotate moves the current file aside under a timestamped name, opens a
+// fresh one and returns the rotated file's path
+func (f *logFile) rotate(now time.Time) (string, error) {
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to close log file: %w", err)
+	}
+
+	base := f.path[:len(f.path)-len(filepath.Ext(f.path))]
+	rotated := fmt.Sprintf("%s-%s.log", base, now.Format(rotatedTimeFormat))
+	for i := 1; fileExists(rotated) || fileExists(rotated+".gz") || fileExists(rotated+".zst"); i++ {
+		rotated = fmt.Sprintf("%s-%s-%d.log", base, now.Format(rotatedTimeFormat), i)
+	}
+
+	renameErr := os.Rename(f.path, rotated)
+	// Keep logging even if the rename failed
+	if err := f.open(); err != nil {
+		return "", err
+	}
+	if renameErr != nil {
+		return "", fmt.Errorf("failed to rotate log file: %w", renameErr)
+	}
+	return rotated, nil
+}
+
+// compressLog compresses a rotated log in place, replacing it with a .gz
+// or .zst file
+func compressLog(path, compression string) error {
+	var ext string
+	switch compression {
+	case CompressionGzip:
+		ext = ".gz"
+	case CompressionZstd:
+		ext = ".zst"
+	default:
+		return nil
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := path + ext + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	var encoder io.WriteCloser
+	if compression == CompressionGzip {
+		encoder = gzip.NewWriter(out)
+	} else if encoder, err = zstd.NewWriter(out); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	_, err = io.Copy(encoder, in)
+	if closeErr := encoder.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to compress %s: %w", path, err)
+	}
+
+	if err := os.Rename(tmp, path+ext); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path)
+}
+
+// enforceRetention deletes rotated logs of a metric type that are older
+// than the retention period, then the oldest ones until the rest fit into
+// the total size limit. The active log doesn't count towards the limit.
+func enforceRetention(logDir, metricType string, policy RotationPolicy, now time.Time) error {
+	if policy.Retention <= 0 && policy.MaxTotalSize <= 0 {
+		return nil
+	}
+
+	pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(metricType) + `-(\d{8}-\d{6})(-\d+)?\.log(\.gz|\.zst)?$`)
+	entries, err := os.ReadDir(logDir)
+	if err != nil {
+		return err
+	}
+
+	type rotatedLog struct {
+		path      string
+		rotatedAt time.Time
+		size      int64
+	}
+	var logs []rotatedLog
+	for _, entry := range entries {
+		m := pattern.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		rotatedAt, err := time.ParseInLocation(rotatedTimeFormat, m[1], time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		logs = append(logs, rotatedLog{filepath.Join(logDir, entry.Name()), rotatedAt, info.Size()})
+	}
+	// Newest first, so the total is accumulated from the files worth keeping
+	sort.Slice(logs, func(i, j int) bool {
+		return logs[i].rotatedAt.After(logs[j].rotatedAt)
+	})
+
+	var total int64
+	for _, log := range logs {
+		total += log.size
+		expired := policy.Retention > 0 && now.Sub(log.rotatedAt) > policy.Retention
+		oversize := policy.MaxTotalSize > 0 && total > policy.MaxTotalSize
+		if expired || oversize {
+			if err := os.Remove(log.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReopenedLogKeepsItsAge(t *testing.T) {
+	start := time.Now().Add(-25 * time.Hour).UTC().Truncate(time.Second)
+	lines := map[string]string{
+		"json":   `{"schema":1,"type":"cpu","host":"deck","tick":1,"time":"` + start.Format(time.RFC3339Nano) + `","fields":{}}` + "\n",
+		"csv":    "timestamp,session_id,overall_percent\n" + start.Format(time.RFC3339Nano) + ",,12.5\n",
+		"influx": "cpu,host=deck overall_percent=12.5 " + strconv.FormatInt(start.UnixNano(), 10) + "\n",
+	}
+	for format, content := range lines {
+		path := filepath.Join(t.TempDir(), "cpu.log")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := openLogFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.policy = RotationPolicy{MaxAge: 24 * time.Hour}
+		if !f.startedAt.Equal(start) {
+			t.Errorf("%s: startedAt = %s, want %s", format, f.startedAt, start)
+		}
+		if !f.needsRotation(time.Now()) {
+			t.Errorf("%s: log started 25h ago isn't due for rotation after reopening", format)
+		}
+		f.Close()
+	}
+}
+
+func TestReopenedLogWithoutRecordsUsesModTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cpu.log")
+	if err := os.WriteFile(path, []byte("timestamp,session_id\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := openLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if !f.startedAt.Equal(modTime) {
+		t.Errorf("startedAt = %s, want the mtime %s", f.startedAt, modTime)
+	}
+}
+
+func TestConcurrentCleanUps(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileSink(dir, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Random content doesn't shrink when compressed, so three files fit
+	rng := rand.New(rand.NewSource(1))
+	content := make([]byte, 64*1024)
+	policy := RotationPolicy{Compression: CompressionGzip, MaxTotalSize: int64(len(content)) * 7 / 2}
+	start := time.Now().Add(-time.Hour)
+	var rotated []string
+	for i := 0; i < 10; i++ {
+		rng.Read(content)
+		name := "cpu-" + start.Add(time.Duration(i)*time.Minute).Format(rotatedTimeFormat) + ".log"
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		rotated = append(rotated, name)
+	}
+
+	// While one cleanup runs, the others wait instead of deleting or
+	// counting files it is still compressing
+	lock := s.cleanups["cpu"]
+	lock.Lock()
+	s.mu.Lock()
+	for _, name := range rotated {
+		s.cleanUp("cpu", filepath.Join(dir, name), policy)
+	}
+	s.mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	for _, name := range rotated {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s touched while another cleanup of cpu ran: %v", name, err)
+		}
+	}
+	lock.Unlock()
+	s.background.Wait()
+
+	matches, err := filepath.Glob(filepath.Join(dir, "cpu-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, match := range matches {
+		got = append(got, filepath.Base(match))
+	}
+	var want []string
+	for _, name := range rotated[len(rotated)-3:] {
+		want = append(want, name+".gz")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rotated logs left = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package logger
+
+import "time"
+
+// Sample is one logged metric, e.g. a metrics.CPUStats of type "cpu"
+type Sample struct {
+	Type      string // one of MetricTypes
+	Host      string
+	Tick      uint64 // collection cycle; samples of the same cycle share it
+	SessionID string // play session the sample was taken in, or ""
+	Time      time.Time
+	Data      interface{}
+}
+
+// Sink receives the samples passed to a Logger. Write is called for one
+// sample at a time and may buffer it until Flush; Close flushes and is
+// called once when the Logger is closed.
+type Sink interface {
+	Write(sample Sample) error
+	Flush() error
+	Close() error
+}
+
+// FilterSink passes only samples of the given metric types to a sink
+type FilterSink struct {
+	sink  Sink
+	types map[string]bool
+}
+
+// NewFilterSink wraps sink so it only receives the given metric types
+func NewFilterSink(sink Sink, metricTypes ...string) *FilterSink {
+	types := make(map[string]bool, len(metricTypes))
+	for _, metricType := range metricTypes {
+		types[metricType] = true
+	}
+	return &FilterSink{sink: sink, types: types}
+}
+
+// Write passes the sample on if its type is accepted
+func (f *FilterSink) Write(sample Sample) error {
+	if !f.types[sample.Type] {
+		return nil
+	}
+	return f.sink.Write(sample)
+}
+
+// Flush flushes the wrapped sink
+func (f *FilterSink) Flush() error {
+	return f.sink.Flush()
+}
+
+// Close closes the wrapped sink
+func (f *FilterSink) Close() error {
+	return f.sink.Close()
+}
@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// streamTypeColumn leads every CSV row of a stream, since all metric types
+// share it
+const streamTypeColumn = "metric_type"
+
+// StreamSink writes samples of all metric types to a single writer, e.g.
+// stdout, as NDJSON records, CSV or InfluxDB line protocol. A CSV header is
+// written whenever the columns change, so CSV streams are best filtered to
+// one metric type. Writes are buffered until Flush.
+type StreamSink struct {
+	w         *bufio.Writer
+	format    string
+	csvWriter *csv.Writer
+	csvHeader []string
+	mu        sync.Mutex
+}
+
+// NewStreamSink creates a sink writing to w. Format is "json", "csv" or
+// "influx".
+func NewStreamSink(w io.Writer, format string) *StreamSink {
+	s := &StreamSink{w: bufio.NewWriter(w), format: format}
+	if format == "csv" {
+		s.csvWriter = csv.NewWriter(s.w)
+	}
+	return s
+}
+
+// Write writes a sample as one line, preceded by a header if needed
+func (s *StreamSink) Write(sample Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.format == "csv" {
+		header, row := csvRow(sample.Data, sample.SessionID, sample.Time)
+		header = append([]string{streamTypeColumn}, header...)
+		row = append([]string{sample.Type}, row...)
+		if !equalColumns(header, s.csvHeader) {
+			if err := s.csvWriter.Write(header); err != nil {
+				return fmt.Errorf("failed to write CSV header: %w", err)
+			}
+			s.csvHeader = header
+		}
+		if err := s.csvWriter.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+		s.csvWriter.Flush()
+		return s.csvWriter.Error()
+	}
+
+	if s.format == "influx" {
+		_, err := s.w.Write(marshalInflux(sample))
+		return err
+	}
+
+	line, err := marshalRecord(sample)
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(line)
+	return err
+}
+
+// Flush writes buffered samples to the writer
+func (s *StreamSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.w.Flush()
+}
+
+// Close flushes pending output. The writer itself is left open.
+func (s *StreamSink) Close() error {
+	return s.Flush()
+}
@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"reflect"
+	"strconv"
+
+	"github.com/steam-os-monitor/monitor/internal/tsdb"
+)
+
+// seriesLabels names the fields that tell apart the samples of a metric
+// type logged in the same tick, e.g. one per disk
+var seriesLabels = map[string][]string{
+	"disk":             {"device", "mount_point"},
+	"network":          {"interface"},
+	"game_performance": {"app_id"},
+	"sessions":         {"app_id"},
+}
+
+// TSDBSink stores the numeric fields of samples in a time-series store as
+// series named "<metric type>.<field>", e.g. "cpu.overall_percent".
+// Numeric slices such as per-core usage become one series per element;
+// nested lists and maps, e.g. per-app Steam data, aren't stored.
+type TSDBSink struct {
+	db *tsdb.DB
+}
+
+// NewTSDBSink creates a sink appending to db. The store stays open when the
+// sink is closed, so it can still be read from.
+func NewTSDBSink(db *tsdb.DB) *TSDBSink {
+	return &TSDBSink{db: db}
+}
+
+// Write appends the sample's numeric fields
+func (s *TSDBSink) Write(sample Sample) error {
+	return s.db.Append(sample.Time, seriesSamples(sample))
+}
+
+// Flush makes the appended samples durable
+func (s *TSDBSink) Flush() error {
+	return s.db.Flush()
+}
+
+// Close flushes the store
+func (s *TSDBSink) Close() error {
+	return s.db.Flush()
+}
+
+// seriesSamples turns a sample's top-level numeric and boolean fields into
+// series values
+func seriesSamples(sample Sample) []tsdb.Sample {
+	v := reflect.ValueOf(sample.Data)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	t := v.Type()
+	labels := tsdb.Labels{}
+	isLabel := make(map[string]bool)
+	for _, name := range seriesLabels[sample.Type] {
+		isLabel[name] = true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || !isLabel[fieldName(field)] {
+			continue
+		}
+		if value := formatScalar(v.Field(i)); value != "" {
+			labels[fieldName(field)] = value
+		}
+	}
+
+	var samples []tsdb.Sample
+	add := func(name string, value float64) {
+		samples = append(samples, tsdb.Sample{Name: sample.Type + "." + name, Labels: labels, Value: value})
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := fieldName(field)
+		if !field.IsExported() || name == "-" || isLabel[name] {
+			continue
+		}
+
+		fv := v.Field(i)
+		if value, ok := seriesValue(fv); ok {
+			add(name, value)
+			continue
+		}
+		if fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array {
+			for j := 0; j < fv.Len(); j++ {
+				if value, ok := seriesValue(fv.Index(j)); ok {
+					add(name+"."+strconv.Itoa(j), value)
+				}
+			}
+		}
+	}
+	return samples
+}
+
+// seriesValue returns a number, duration or bool as a float
+func seriesValue(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,202 @@
+package session
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// maxHistoryLine bounds the length of a single sessions.log line
+const maxHistoryLine = 1024 * 1024
+
+// HistoryFile is the file in log_dir the recorder keeps the summaries of
+// all sessions in. Unlike sessions.log it doesn't depend on the configured
+// sinks or log format and is never rotated.
+const HistoryFile = "session_history.jsonl"
+
+// OpenHistory loads the session summaries kept in the history file at path.
+// If there is none yet, the summaries in legacyPath, the sessions.log of
+// earlier versions, are imported into it.
+func OpenHistory(path, legacyPath string) ([]metrics.SessionSummary, error) {
+	sessions, err := LoadHistory(path)
+	if !errors.Is(err, os.ErrNotExist) {
+		return sessions, err
+	}
+
+	sessions, err = LoadHistory(legacyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := appendHistory(path, sessions...); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// appendHistory adds summaries to the history file at path as JSON lines
+func appendHistory(path string, summaries ...metrics.SessionSummary) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open session history: %w", err)
+	}
+
+	var data []byte
+	for _, summary := range summaries {
+		line, err := json.Marshal(summary)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write session history: %w", err)
+	}
+	return file.Close()
+}
+
+// LoadHistory reads the session summaries in a history file or written to
+// sessions.log in either log format. JSON lines are plain summaries, NDJSON
+// records or, from older versions, logrus entries with the summary under
+// "metric". CSV rows are matched to fields through the header line
+// preceding them; lines that can't be parsed are skipped.
+func LoadHistory(path string) ([]metrics.SessionSummary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sessions []metrics.SessionSummary
+	var header []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxHistoryLine)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		var summary metrics.SessionSummary
+		if strings.HasPrefix(line, "{") {
+			if !parseJSONSession([]byte(line), &summary) {
+				continue
+			}
+		} else {
+			row, err := csv.NewReader(strings.NewReader(line)).Read()
+			if err != nil {
+				continue
+			}
+			if len(row) > 0 && row[0] == "timestamp" {
+				header = row
+				continue
+			}
+			if !parseCSVSession(header, row, &summary) {
+				continue
+			}
+		}
+
+		if summary.SessionID == "" || summary.EndTime.IsZero() {
+			continue
+		}
+		sessions = append(sessions, summary)
+	}
+	return sessions, scanner.Err()
+}
+
+// parseJSONSession fills summary from a JSON log line
+func parseJSONSession(line []byte, summary *metrics.SessionSummary) bool {
+	var record struct {
+		Fields json.RawMessage `json:"fields"`
+		Metric json.RawMessage `json:"metric"`
+	}
+	if err := json.Unmarshal(line, &record); err != nil {
+		return false
+	}
+	fields := record.Fields
+	if fields == nil {
+		fields = record.Metric
+	}
+	if fields == nil {
+		fields = line // A history file line
+	}
+	return json.Unmarshal(fields, summary) == nil
+}
+
+// parseCSVSession fills summary from a CSV row whose columns are named by
+// header after the JSON names of the summary's fields
+func parseCSVSession(header, row []string, summary *metrics.SessionSummary) bool {
+	if len(header) != len(row) {
+		return false
+	}
+	columns := make(map[string]string, len(header))
+	for i, name := range header {
+		columns[name] = row[i]
+	}
+
+	v := reflect.ValueOf(summary).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		value, exists := columns[name]
+		if !exists || value == "" {
+			continue
+		}
+		if !setField(v.Field(i), value) {
+			return false
+		}
+	}
+	return true
+}
+
+// setField parses value into a scalar or time field
+func setField(field reflect.Value, value string) bool {
+	if _, isTime := field.Interface().(time.Time); isTime {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return false
+		}
+		field.Set(reflect.ValueOf(t))
+		return true
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return false
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false
+		}
+		field.SetFloat(f)
+	default:
+		return false
+	}
+	return true
+}
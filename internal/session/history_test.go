@@ -0,0 +1,108 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/internal/logger"
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+func TestRecorderKeepsHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", HistoryFile)
+	start := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	for run := 0; run < 2; run++ {
+		r := NewRecorder(logger.NewLoggerWithSinks(&memorySink{}), path)
+		game := &metrics.GamePerformanceStats{AppID: "620", FPS: 60, Timestamp: start.Add(time.Duration(run) * time.Hour)}
+		if err := r.Observe(game, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions, err := OpenHistory(path, filepath.Join(t.TempDir(), "sessions.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].AppID != "620" || !sessions[1].StartTime.Equal(start.Add(time.Hour)) {
+		t.Errorf("OpenHistory() = %+v, want both sessions", sessions)
+	}
+}
+
+func TestOpenHistoryImportsSessionsLog(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "sessions.log")
+	line := `{"timestamp":"2024-01-02T16:00:00Z","type":"sessions","fields":{"session_id":"20240102-150000-abcd","app_id":"620","start_time":"2024-01-02T15:00:00Z","end_time":"2024-01-02T16:00:00Z"}}`
+	if err := os.WriteFile(legacy, []byte(line+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, HistoryFile)
+
+	sessions, err := OpenHistory(path, legacy)
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("OpenHistory() = %+v, %v, want the logged session", sessions, err)
+	}
+
+	// The import is kept once sessions.log is gone
+	if err := os.Remove(legacy); err != nil {
+		t.Fatal(err)
+	}
+	sessions, err = OpenHistory(path, legacy)
+	if err != nil || len(sessions) != 1 || sessions[0].SessionID != "20240102-150000-abcd" {
+		t.Errorf("OpenHistory() after import = %+v, %v", sessions, err)
+	}
+}
+
+func TestOpenHistoryWithoutAny(t *testing.T) {
+	dir := t.TempDir()
+	sessions, err := OpenHistory(filepath.Join(dir, HistoryFile), filepath.Join(dir, "sessions.log"))
+	if err != nil || len(sessions) != 0 {
+		t.Errorf("OpenHistory() = %+v, %v, want no sessions", sessions, err)
+	}
+}
+
+func TestHistorySurvivesSessionsLogRotation(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := logger.NewFileSink(dir, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rotate sessions.log after every summary and keep no rotated logs
+	policy := logger.RotationPolicy{MaxSize: 1, Retention: time.Nanosecond, Compression: logger.CompressionGzip}
+	if err := sink.SetRotation("sessions", policy); err != nil {
+		t.Fatal(err)
+	}
+	log := logger.NewLoggerWithSinks(sink)
+	path := filepath.Join(dir, HistoryFile)
+	r := NewRecorder(log, path)
+
+	start := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		game := &metrics.GamePerformanceStats{AppID: "620", Timestamp: start.Add(time.Duration(i) * time.Hour)}
+		if err := r.Observe(game, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	logged, _ := LoadHistory(filepath.Join(dir, "sessions.log"))
+	if len(logged) == 3 {
+		t.Fatal("sessions.log wasn't rotated")
+	}
+	sessions, err := OpenHistory(path, filepath.Join(dir, "sessions.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 3 {
+		t.Errorf("OpenHistory() after rotation = %d sessions, want 3", len(sessions))
+	}
+}
@@ -0,0 +1,135 @@
+package session
+
+import (
+	"sort"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// Playtime history reported per game
+const (
+	playtimeDays  = 7
+	playtimeWeeks = 4
+)
+
+// GamePlaytime is the playtime of one game, observed by the monitor's own
+// sessions and as recorded by Steam
+type GamePlaytime struct {
+	AppID       string
+	Name        string
+	Daily       []time.Duration // observed per day, oldest first, ending today
+	Weekly      []time.Duration // observed per week from Monday, oldest first, ending this week
+	Observed    time.Duration   // all observed sessions
+	Sessions    int
+	SteamTotal  time.Duration // Steam's playtime across local users
+	Steam2Weeks time.Duration
+	LastPlayed  time.Time
+}
+
+// Today returns the observed playtime of the current day
+func (g *GamePlaytime) Today() time.Duration {
+	return g.Daily[len(g.Daily)-1]
+}
+
+// ThisWeek returns the observed playtime of the current week
+func (g *GamePlaytime) ThisWeek() time.Duration {
+	return g.Weekly[len(g.Weekly)-1]
+}
+
+// SummarizePlaytime combines recorded sessions with Steam's local playtime
+// into per-game daily and weekly playtime, most recently played first.
+// Sessions are split at midnight, so one spanning two days counts towards
+// both. Steam-only games are included if played within the weekly history.
+func SummarizePlaytime(sessions []metrics.SessionSummary, steam []metrics.AppPlaytime, names map[string]string, now time.Time) []GamePlaytime {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	dayStarts := make([]time.Time, playtimeDays+1)
+	for i := range dayStarts {
+		dayStarts[i] = today.AddDate(0, 0, i-playtimeDays+1)
+	}
+	monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	weekStarts := make([]time.Time, playtimeWeeks+1)
+	for i := range weekStarts {
+		weekStarts[i] = monday.AddDate(0, 0, 7*(i-playtimeWeeks+1))
+	}
+
+	games := make(map[string]*GamePlaytime)
+	game := func(appID string) *GamePlaytime {
+		g, exists := games[appID]
+		if !exists {
+			g = &GamePlaytime{
+				AppID:  appID,
+				Name:   names[appID],
+				Daily:  make([]time.Duration, playtimeDays),
+				Weekly: make([]time.Duration, playtimeWeeks),
+			}
+			games[appID] = g
+		}
+		return g
+	}
+
+	seen := make(map[string]bool)
+	for _, s := range sessions {
+		if s.AppID == "" || seen[s.SessionID] {
+			continue
+		}
+		seen[s.SessionID] = true
+
+		g := game(s.AppID)
+		if g.Name == "" {
+			g.Name = s.GameName
+		}
+		g.Sessions++
+		g.Observed += s.Duration
+		if s.EndTime.After(g.LastPlayed) {
+			g.LastPlayed = s.EndTime
+		}
+		for i := 0; i < playtimeDays; i++ {
+			g.Daily[i] += overlap(s.StartTime, s.EndTime, dayStarts[i], dayStarts[i+1])
+		}
+		for i := 0; i < playtimeWeeks; i++ {
+			g.Weekly[i] += overlap(s.StartTime, s.EndTime, weekStarts[i], weekStarts[i+1])
+		}
+	}
+
+	for _, p := range steam {
+		if _, observed := games[p.AppID]; !observed && p.LastPlayed.Before(weekStarts[0]) {
+			continue
+		}
+		g := game(p.AppID)
+		g.SteamTotal += p.Playtime
+		g.Steam2Weeks += p.Playtime2Weeks
+		if p.LastPlayed.After(g.LastPlayed) {
+			g.LastPlayed = p.LastPlayed
+		}
+	}
+
+	result := make([]GamePlaytime, 0, len(games))
+	for _, g := range games {
+		if g.Name == "" {
+			g.Name = "App " + g.AppID
+		}
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].LastPlayed.Equal(result[j].LastPlayed) {
+			return result[i].LastPlayed.After(result[j].LastPlayed)
+		}
+		return result[i].AppID < result[j].AppID
+	})
+	return result
+}
+
+// overlap returns how much of [start, end) falls into [from, to)
+func overlap(start, end, from, to time.Time) time.Duration {
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
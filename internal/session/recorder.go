@@ -0,0 +1,267 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/internal/collector"
+	"github.com/steam-os-monitor/monitor/internal/logger"
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// endGrace is the number of consecutive samples without a running game
+// before the current session is closed. It keeps a single failed process
+// scan from splitting one play session in two. A session that isn't
+// resumed ends at the first of these samples, which aren't tagged with it.
+const endGrace = 3
+
+// Recorder detects game starts and stops and records play sessions
+type Recorder struct {
+	logger      *logger.Logger
+	historyPath string // "" keeps no history file
+	current     *session
+	finished    []metrics.SessionSummary // sessions ended since the recorder started
+	missed      int
+	missedAt    time.Time // when the game was first found missing
+	mu          sync.Mutex
+}
+
+// session accumulates the samples of the session in progress
+type session struct {
+	summary      metrics.SessionSummary
+	frameTimes   []float64 // sampled once per round
+	frames       []float32 // per-frame times, e.g. from MangoHud; float32 keeps hours of them small
+	fpsSum       float64
+	fpsCount     int
+	powerSum     float64
+	powerCount   int
+	lastBattery  float64
+	batteryKnown bool
+}
+
+// NewRecorder creates a new session recorder writing summaries to log and
+// adding them to the history file at historyPath
+func NewRecorder(log *logger.Logger, historyPath string) *Recorder {
+	return &Recorder{
+		logger:      log,
+		historyPath: historyPath,
+	}
+}
+
+// Observe feeds one round of samples into the recorder. It opens a session
+// when a game starts, tags the logger with its ID and writes the summary
+// once the game stops. frameTimes are the per-frame times read since the
+// last round, nil if only sampled ones are known. game and sensors may be
+// nil.
+func (r *Recorder) Observe(game *metrics.GamePerformanceStats, frameTimes []float64, sensors *metrics.SensorStats) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	appID := ""
+	now := time.Now()
+	if game != nil {
+		appID = game.AppID
+		if !game.Timestamp.IsZero() {
+			now = game.Timestamp
+		}
+	}
+
+	if r.current != nil && appID != r.current.summary.AppID {
+		if appID == "" && r.missed < endGrace {
+			if r.missed == 0 {
+				r.missedAt = now
+				r.logger.SetSessionID("")
+			}
+			r.missed++
+			return nil
+		}
+		if err := r.finish(r.endTime(now)); err != nil {
+			return err
+		}
+	}
+	if r.current != nil && r.missed > 0 {
+		// The game is back within the grace period
+		r.logger.SetSessionID(r.current.summary.SessionID)
+	}
+	r.missed = 0
+
+	if appID == "" {
+		return nil
+	}
+
+	if r.current == nil {
+		id, err := newSessionID(game.Timestamp)
+		if err != nil {
+			return fmt.Errorf("failed to create session ID: %w", err)
+		}
+		r.current = &session{
+			summary: metrics.SessionSummary{
+				SessionID:         id,
+				AppID:             game.AppID,
+				GameName:          game.GameName,
+				Proton:            game.Proton,
+				CompatTool:        game.CompatTool,
+				CompatToolVersion: game.CompatToolVersion,
+				StartTime:         game.Timestamp,
+			},
+		}
+		r.logger.SetSessionID(id)
+	}
+
+	r.current.add(game, frameTimes, sensors)
+	return nil
+}
+
+// Current returns the ID of the session in progress, or "" if none
+func (r *Recorder) Current() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.current == nil {
+		return ""
+	}
+	return r.current.summary.SessionID
+}
+
+// Sessions returns the sessions recorded since the recorder started,
+// including the one in progress summarized up to now. Its 1% low FPS is
+// left out, as it is only computed over all its frames once it ends.
+func (r *Recorder) Sessions(now time.Time) []metrics.SessionSummary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sessions := append([]metrics.SessionSummary(nil), r.finished...)
+	if r.current != nil {
+		sessions = append(sessions, r.current.summarize(r.endTime(now)))
+	}
+	return sessions
+}
+
+// Close ends the session in progress, if any, and writes its summary
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.current == nil {
+		return nil
+	}
+	return r.finish(r.endTime(time.Now()))
+}
+
+// endTime returns when the current session ends if it ended at now: when
+// the game was first found missing, if it is
+func (r *Recorder) endTime(now time.Time) time.Time {
+	if r.missed > 0 {
+		return r.missedAt
+	}
+	return now
+}
+
+// finish closes the current session, logs its summary and adds it to the
+// history file
+func (r *Recorder) finish(end time.Time) error {
+	s := r.current
+	r.current = nil
+	r.missed = 0
+	r.logger.SetSessionID("")
+
+	summary := s.summarize(end)
+	summary.FPS1PercentLow = s.fps1PercentLow()
+	r.finished = append(r.finished, summary)
+	if err := r.logger.LogSession(summary); err != nil {
+		return fmt.Errorf("failed to log session %s: %w", summary.SessionID, err)
+	}
+	if r.historyPath != "" {
+		if err := appendHistory(r.historyPath, summary); err != nil {
+			return fmt.Errorf("failed to record session %s: %w", summary.SessionID, err)
+		}
+	}
+	return nil
+}
+
+// add accumulates a round of samples into the session
+func (s *session) add(game *metrics.GamePerformanceStats, frameTimes []float64, sensors *metrics.SensorStats) {
+	s.summary.Samples++
+	if game.GameName != "" {
+		s.summary.GameName = game.GameName
+	}
+	if game.FPS > 0 {
+		s.fpsSum += game.FPS
+		s.fpsCount++
+		s.frameTimes = append(s.frameTimes, game.FrameTime)
+	}
+	for _, ft := range frameTimes {
+		s.frames = append(s.frames, float32(ft))
+	}
+
+	if sensors == nil {
+		return
+	}
+
+	if sensors.CPUTemp > s.summary.PeakCPUTemp {
+		s.summary.PeakCPUTemp = sensors.CPUTemp
+	}
+	if sensors.GPUTemp > s.summary.PeakGPUTemp {
+		s.summary.PeakGPUTemp = sensors.GPUTemp
+	}
+	if sensors.APUPower > 0 {
+		s.powerSum += sensors.APUPower
+		s.powerCount++
+	}
+
+	if sensors.HasBattery {
+		if !s.batteryKnown {
+			s.summary.BatteryStart = sensors.BatteryPercent
+			s.batteryKnown = true
+		} else if sensors.BatteryPercent < s.lastBattery {
+			s.summary.BatteryDrain += s.lastBattery - sensors.BatteryPercent
+		}
+		s.lastBattery = sensors.BatteryPercent
+		s.summary.BatteryEnd = sensors.BatteryPercent
+	}
+}
+
+// summarize completes the session summary at the given end time
+func (s *session) summarize(end time.Time) metrics.SessionSummary {
+	summary := s.summary
+	summary.EndTime = end
+	summary.Duration = end.Sub(summary.StartTime)
+
+	if s.fpsCount > 0 {
+		summary.AvgFPS = s.fpsSum / float64(s.fpsCount)
+	}
+	if s.powerCount > 0 {
+		summary.AvgAPUPower = s.powerSum / float64(s.powerCount)
+	}
+	if hours := summary.Duration.Hours(); hours > 0 {
+		summary.DrainPerHour = summary.BatteryDrain / hours
+	}
+
+	return summary
+}
+
+// fps1PercentLow returns the 1% low FPS of the session's frames, as the
+// benchmark computes it, or of the sampled frame times if no per-frame
+// times were read
+func (s *session) fps1PercentLow() float64 {
+	if len(s.frames) == 0 {
+		return collector.ComputeFrameTimeStats(s.frameTimes).Low1Percent
+	}
+	frames := make([]float64, len(s.frames))
+	for i, ft := range s.frames {
+		frames[i] = float64(ft)
+	}
+	return collector.ComputeFrameTimeStats(frames).Low1Percent
+}
+
+// newSessionID returns a unique, time-sortable session identifier
+func newSessionID(start time.Time) (string, error) {
+	random := make([]byte, 4)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s", start.Format("20060102-150405"), hex.EncodeToString(random)), nil
+}
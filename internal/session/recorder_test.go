@@ -0,0 +1,147 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/internal/logger"
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// memorySink keeps the samples written to it
+type memorySink struct {
+	samples []logger.Sample
+}
+
+func (s *memorySink) Write(sample logger.Sample) error {
+	s.samples = append(s.samples, sample)
+	return nil
+}
+
+func (s *memorySink) Flush() error { return nil }
+func (s *memorySink) Close() error { return nil }
+
+func (s *memorySink) ofType(metricType string) []logger.Sample {
+	var samples []logger.Sample
+	for _, sample := range s.samples {
+		if sample.Type == metricType {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+func TestRecorderEndsAtFirstMissedSample(t *testing.T) {
+	sink := &memorySink{}
+	log := logger.NewLoggerWithSinks(sink)
+	r := NewRecorder(log, "")
+
+	start := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	tick := func(i int, running bool) {
+		game := &metrics.GamePerformanceStats{Timestamp: start.Add(time.Duration(i) * time.Second)}
+		if running {
+			game.AppID, game.FPS, game.FrameTime = "620", 60, 1000.0/60
+		}
+		if err := r.Observe(game, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		log.LogGamePerformance(game)
+	}
+
+	for i := 0; i < 10; i++ {
+		tick(i, true)
+	}
+	for i := 10; i < 10+endGrace+1; i++ {
+		tick(i, false)
+	}
+
+	sessions := sink.ofType("sessions")
+	if len(sessions) != 1 {
+		t.Fatalf("logged %d sessions, want 1", len(sessions))
+	}
+	summary := sessions[0].Data.(metrics.SessionSummary)
+	if want := 10 * time.Second; summary.Duration != want {
+		t.Errorf("Duration = %s, want %s", summary.Duration, want)
+	}
+	if want := start.Add(10 * time.Second); !summary.EndTime.Equal(want) {
+		t.Errorf("EndTime = %s, want %s", summary.EndTime, want)
+	}
+
+	for i, sample := range sink.ofType("game_performance") {
+		tagged := sample.SessionID != ""
+		if tagged != (i < 10) {
+			t.Errorf("game sample %d tagged with %q", i, sample.SessionID)
+		}
+	}
+}
+
+func TestRecorderResumesWithinGrace(t *testing.T) {
+	sink := &memorySink{}
+	log := logger.NewLoggerWithSinks(sink)
+	r := NewRecorder(log, "")
+
+	start := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	running := []bool{true, true, false, true, true}
+	for i, run := range running {
+		game := &metrics.GamePerformanceStats{Timestamp: start.Add(time.Duration(i) * time.Second)}
+		if run {
+			game.AppID = "620"
+		}
+		if err := r.Observe(game, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		log.LogGamePerformance(game)
+	}
+
+	id := r.Current()
+	if id == "" {
+		t.Fatal("session ended within the grace period")
+	}
+	for i, sample := range sink.ofType("game_performance") {
+		want := id
+		if !running[i] {
+			want = ""
+		}
+		if sample.SessionID != want {
+			t.Errorf("game sample %d tagged with %q, want %q", i, sample.SessionID, want)
+		}
+	}
+
+	sessions := r.Sessions(start.Add(time.Minute))
+	if len(sessions) != 1 || sessions[0].Duration != time.Minute {
+		t.Errorf("Sessions() = %+v, want one session of a minute", sessions)
+	}
+}
+
+func TestRecorderLowsFromFrameTimes(t *testing.T) {
+	sink := &memorySink{}
+	r := NewRecorder(logger.NewLoggerWithSinks(sink), "")
+
+	start := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	// Every round samples a steady 60 FPS, but one of the frames read in
+	// between took 100ms
+	for i := 0; i < 10; i++ {
+		frames := make([]float64, 10)
+		for j := range frames {
+			frames[j] = 10
+		}
+		if i == 5 {
+			frames[0] = 100
+		}
+		game := &metrics.GamePerformanceStats{AppID: "620", FPS: 60, FrameTime: 1000.0 / 60, Timestamp: start.Add(time.Duration(i) * time.Second)}
+		if err := r.Observe(game, frames, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sessions := r.Sessions(start.Add(10 * time.Second)); len(sessions) != 1 || sessions[0].FPS1PercentLow != 0 {
+		t.Errorf("Sessions() = %+v, want the running session without its 1%% low", sessions)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	summary := sink.ofType("sessions")[0].Data.(metrics.SessionSummary)
+	if summary.FPS1PercentLow != 10 {
+		t.Errorf("FPS1PercentLow = %v, want 10 from the 100ms frame", summary.FPS1PercentLow)
+	}
+}
@@ -0,0 +1,206 @@
+// Package steamapi is a small client for the Steam Web API. Responses are
+// cached on disk so data stays available offline and requests are spaced
+// out to stay well within Valve's rate limits.
+package steamapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBaseURL is the public Steam Web API endpoint
+const DefaultBaseURL = "https://api.steampowered.com"
+
+// Defaults for new clients
+const (
+	defaultMinInterval = time.Second
+	defaultTimeout     = 15 * time.Second
+)
+
+// ErrNoAPIKey is returned when the client has no API key configured
+var ErrNoAPIKey = errors.New("no Steam Web API key configured")
+
+// Client calls the Steam Web API. BaseURL can point at a local stand-in for
+// testing. A response is served from the disk cache while it is younger
+// than the TTL of its call; when a request fails, an older cached response
+// is returned instead, marked as stale.
+type Client struct {
+	BaseURL     string
+	APIKey      string
+	CacheDir    string        // "" disables the disk cache
+	MinInterval time.Duration // minimum time between requests
+	HTTPClient  *http.Client
+
+	mu          sync.Mutex
+	lastRequest time.Time
+}
+
+// NewClient creates a client for the public API that caches in cacheDir
+func NewClient(apiKey, cacheDir string) *Client {
+	return &Client{
+		BaseURL:     DefaultBaseURL,
+		APIKey:      apiKey,
+		CacheDir:    cacheDir,
+		MinInterval: defaultMinInterval,
+		HTTPClient:  &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// DefaultCacheDir returns the per-user cache directory for API responses
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "steam-os-monitor", "steamapi")
+}
+
+// cachedResponse is a response body as stored in the disk cache
+type cachedResponse struct {
+	FetchedAt time.Time       `json:"fetched_at"`
+	Body      json.RawMessage `json:"body"`
+}
+
+// call fetches interface/method/version with params and decodes the JSON
+// body into out. It returns when the data was fetched and whether it came
+// from a stale cache entry because the request failed.
+func (c *Client) call(ctx context.Context, method string, params url.Values, ttl time.Duration, out interface{}) (time.Time, bool, error) {
+	if c.APIKey == "" {
+		return time.Time{}, false, ErrNoAPIKey
+	}
+
+	cachePath := c.cachePath(method, params)
+	cached, cacheErr := c.readCache(cachePath)
+	if cacheErr == nil && time.Since(cached.FetchedAt) < ttl {
+		return cached.FetchedAt, false, json.Unmarshal(cached.Body, out)
+	}
+
+	body, err := c.fetch(ctx, method, params)
+	if err != nil {
+		// Offline fallback: better old data than none
+		if cacheErr == nil {
+			return cached.FetchedAt, true, json.Unmarshal(cached.Body, out)
+		}
+		return time.Time{}, false, err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to decode %s response: %w", method, err)
+	}
+
+	fetchedAt := time.Now()
+	c.writeCache(cachePath, cachedResponse{FetchedAt: fetchedAt, Body: body})
+	return fetchedAt, false, nil
+}
+
+// fetch performs one rate-limited request
+func (c *Client) fetch(ctx context.Context, method string, params url.Values) ([]byte, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	for key, values := range params {
+		query[key] = values
+	}
+	query.Set("key", c.APIKey)
+	query.Set("format", "json")
+	endpoint := strings.TrimSuffix(c.BaseURL, "/") + "/" + method + "/?" + query.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		// The URL carries the API key, so don't wrap the error verbatim
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("%s request failed: %w", method, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s request failed: %s", method, response.Status)
+	}
+	return io.ReadAll(response.Body)
+}
+
+// wait blocks until MinInterval has passed since the previous request
+func (c *Client) wait(ctx context.Context) error {
+	c.mu.Lock()
+	next := c.lastRequest.Add(c.MinInterval)
+	now := time.Now()
+	if next.Before(now) {
+		next = now
+	}
+	c.lastRequest = next
+	c.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cachePath names the cache file of a call. The API key isn't part of the
+// params, so it never ends up on disk.
+func (c *Client) cachePath(method string, params url.Values) string {
+	if c.CacheDir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(c.BaseURL + "/" + method + "?" + params.Encode()))
+	return filepath.Join(c.CacheDir, hex.EncodeToString(sum[:16])+".json")
+}
+
+func (c *Client) readCache(path string) (*cachedResponse, error) {
+	if path == "" {
+		return nil, os.ErrNotExist
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cached cachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, err
+	}
+	return &cached, nil
+}
+
+// writeCache stores a response, replacing the old entry atomically. A cache
+// that can't be written only costs extra requests, so errors are ignored.
+func (c *Client) writeCache(path string, cached cachedResponse) {
+	if path == "" {
+		return
+	}
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	os.Rename(tmp, path)
+}
@@ -0,0 +1,165 @@
+package steamapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const ownedGamesBody = `{"response":{"game_count":1,"games":[{"appid":620,"name":"Portal 2","playtime_forever":90,"img_icon_url":"abc"}]}}`
+
+// newTestClient returns a client for a stand-in API that answers with
+// handler and counts its requests
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, *int32) {
+	t.Helper()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	c := NewClient("secret-key", t.TempDir())
+	c.BaseURL = server.URL
+	c.MinInterval = 0
+	return c, &requests
+}
+
+func TestGetOwnedGames(t *testing.T) {
+	c, requests := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/IPlayerService/GetOwnedGames/v1/" {
+			t.Errorf("request path = %q", r.URL.Path)
+		}
+		query := r.URL.Query()
+		if query.Get("key") != "secret-key" || query.Get("steamid") != "76561197960265729" || query.Get("include_appinfo") != "1" {
+			t.Errorf("request query = %q", r.URL.RawQuery)
+		}
+		w.Write([]byte(ownedGamesBody))
+	})
+
+	owned, err := c.GetOwnedGames(context.Background(), "76561197960265729")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owned.GameCount != 1 || len(owned.Games) != 1 || owned.Games[0].Name != "Portal 2" || owned.Stale {
+		t.Errorf("GetOwnedGames() = %+v", owned)
+	}
+	if want := "https://media.steampowered.com/steamcommunity/public/images/apps/620/abc.jpg"; owned.Games[0].IconURL() != want {
+		t.Errorf("IconURL() = %q, want %q", owned.Games[0].IconURL(), want)
+	}
+
+	// The second call is served from the cache
+	again, err := c.GetOwnedGames(context.Background(), "76561197960265729")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *requests != 1 || again.Games[0].Name != "Portal 2" || !again.FetchedAt.Equal(owned.FetchedAt) {
+		t.Errorf("cached call made %d requests in total and returned %+v", *requests, again)
+	}
+}
+
+func TestGetPlayerSummaries(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("steamids"); got != "1,2" {
+			t.Errorf("steamids = %q", got)
+		}
+		w.Write([]byte(`{"response":{"players":[{"steamid":"1","personaname":"deck","gameid":"620","gameextrainfo":"Portal 2"}]}}`))
+	})
+
+	summaries, err := c.GetPlayerSummaries(context.Background(), "1", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries.Players) != 1 || summaries.Players[0].PersonaName != "deck" || summaries.Players[0].GameName != "Portal 2" {
+		t.Errorf("GetPlayerSummaries() = %+v", summaries)
+	}
+}
+
+func TestRequestFailures(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		})
+		_, err := c.GetRecentlyPlayedGames(context.Background(), "1")
+		if err == nil {
+			t.Fatalf("status %d: GetRecentlyPlayedGames() succeeded", status)
+		}
+		if !strings.Contains(err.Error(), http.StatusText(status)) || strings.Contains(err.Error(), "secret-key") {
+			t.Errorf("status %d: error %q", status, err)
+		}
+	}
+}
+
+func TestStaleCacheOnFailure(t *testing.T) {
+	c, requests := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	params := url.Values{
+		"steamid":                   {"1"},
+		"include_appinfo":           {"1"},
+		"include_played_free_games": {"1"},
+	}
+	fetchedAt := time.Now().Add(-2 * ownedGamesTTL).Truncate(time.Second)
+	c.writeCache(c.cachePath("IPlayerService/GetOwnedGames/v1", params),
+		cachedResponse{FetchedAt: fetchedAt, Body: []byte(ownedGamesBody)})
+
+	owned, err := c.GetOwnedGames(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *requests != 1 {
+		t.Errorf("expired cache entry made %d requests, want 1", *requests)
+	}
+	if !owned.Stale || !owned.FetchedAt.Equal(fetchedAt) || len(owned.Games) != 1 {
+		t.Errorf("GetOwnedGames() = %+v, want the stale cached games", owned)
+	}
+}
+
+func TestMalformedResponse(t *testing.T) {
+	body := `{"response":{"games":`
+	c, requests := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+
+	if _, err := c.GetOwnedGames(context.Background(), "1"); err == nil {
+		t.Fatal("GetOwnedGames() of a malformed response succeeded")
+	}
+
+	// The malformed response isn't cached
+	body = ownedGamesBody
+	if _, err := c.GetOwnedGames(context.Background(), "1"); err != nil {
+		t.Fatal(err)
+	}
+	if *requests != 2 {
+		t.Errorf("made %d requests, want 2", *requests)
+	}
+}
+
+func TestNoAPIKey(t *testing.T) {
+	c := NewClient("", t.TempDir())
+	if _, err := c.GetOwnedGames(context.Background(), "1"); err != ErrNoAPIKey {
+		t.Errorf("GetOwnedGames() without a key = %v, want %v", err, ErrNoAPIKey)
+	}
+}
+
+func TestSteamID64(t *testing.T) {
+	tests := []struct {
+		id, want string
+	}{
+		{"1", "76561197960265729"},
+		{"76561197960265729", "76561197960265729"},
+	}
+	for _, tt := range tests {
+		if got, err := SteamID64(tt.id); err != nil || got != tt.want {
+			t.Errorf("SteamID64(%q) = %q, %v, want %q", tt.id, got, err, tt.want)
+		}
+	}
+	if _, err := SteamID64("deck"); err == nil {
+		t.Error("SteamID64() of a non-numeric ID succeeded")
+	}
+}
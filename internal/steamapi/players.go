@@ -0,0 +1,138 @@
+package steamapi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache lifetimes per call. Owned games rarely change, recent playtime and
+// profile state more often.
+const (
+	ownedGamesTTL      = 6 * time.Hour
+	recentlyPlayedTTL  = 15 * time.Minute
+	playerSummariesTTL = 15 * time.Minute
+)
+
+// steamID64Base converts 32-bit account IDs, as used for userdata folder
+// names, to 64-bit Steam IDs
+const steamID64Base = 76561197960265728
+
+// Game is an app in a user's library as reported by IPlayerService
+type Game struct {
+	AppID           int    `json:"appid"`
+	Name            string `json:"name"`
+	PlaytimeForever int    `json:"playtime_forever"` // minutes
+	Playtime2Weeks  int    `json:"playtime_2weeks"`  // minutes
+	IconHash        string `json:"img_icon_url"`
+	LastPlayed      int64  `json:"rtime_last_played"` // Unix time, 0 if never
+}
+
+// IconURL returns the URL of the game's library icon, or "" if it has none
+func (g Game) IconURL() string {
+	if g.IconHash == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://media.steampowered.com/steamcommunity/public/images/apps/%d/%s.jpg", g.AppID, g.IconHash)
+}
+
+// OwnedGames is the result of GetOwnedGames
+type OwnedGames struct {
+	GameCount int       `json:"game_count"`
+	Games     []Game    `json:"games"`
+	FetchedAt time.Time `json:"-"`
+	Stale     bool      `json:"-"` // served from cache after a failed request
+}
+
+// RecentlyPlayedGames is the result of GetRecentlyPlayedGames
+type RecentlyPlayedGames struct {
+	TotalCount int       `json:"total_count"`
+	Games      []Game    `json:"games"`
+	FetchedAt  time.Time `json:"-"`
+	Stale      bool      `json:"-"`
+}
+
+// PlayerSummary is a user's public profile as reported by ISteamUser
+type PlayerSummary struct {
+	SteamID      string `json:"steamid"`
+	PersonaName  string `json:"personaname"`
+	ProfileURL   string `json:"profileurl"`
+	Avatar       string `json:"avatarfull"`
+	PersonaState int    `json:"personastate"`
+	GameID       string `json:"gameid"`        // app being played, if any
+	GameName     string `json:"gameextrainfo"` // name of the app being played
+}
+
+// PlayerSummaries is the result of GetPlayerSummaries
+type PlayerSummaries struct {
+	Players   []PlayerSummary `json:"players"`
+	FetchedAt time.Time       `json:"-"`
+	Stale     bool            `json:"-"`
+}
+
+// GetOwnedGames returns all games in a user's library with names and
+// playtime, including free games that were played
+func (c *Client) GetOwnedGames(ctx context.Context, steamID string) (*OwnedGames, error) {
+	params := url.Values{
+		"steamid":                   {steamID},
+		"include_appinfo":           {"1"},
+		"include_played_free_games": {"1"},
+	}
+	var envelope struct {
+		Response OwnedGames `json:"response"`
+	}
+	fetchedAt, stale, err := c.call(ctx, "IPlayerService/GetOwnedGames/v1", params, ownedGamesTTL, &envelope)
+	if err != nil {
+		return nil, err
+	}
+	result := &envelope.Response
+	result.FetchedAt, result.Stale = fetchedAt, stale
+	return result, nil
+}
+
+// GetRecentlyPlayedGames returns the games a user played in the last two
+// weeks
+func (c *Client) GetRecentlyPlayedGames(ctx context.Context, steamID string) (*RecentlyPlayedGames, error) {
+	params := url.Values{"steamid": {steamID}}
+	var envelope struct {
+		Response RecentlyPlayedGames `json:"response"`
+	}
+	fetchedAt, stale, err := c.call(ctx, "IPlayerService/GetRecentlyPlayedGames/v1", params, recentlyPlayedTTL, &envelope)
+	if err != nil {
+		return nil, err
+	}
+	result := &envelope.Response
+	result.FetchedAt, result.Stale = fetchedAt, stale
+	return result, nil
+}
+
+// GetPlayerSummaries returns the public profiles of up to 100 users
+func (c *Client) GetPlayerSummaries(ctx context.Context, steamIDs ...string) (*PlayerSummaries, error) {
+	params := url.Values{"steamids": {strings.Join(steamIDs, ",")}}
+	var envelope struct {
+		Response PlayerSummaries `json:"response"`
+	}
+	fetchedAt, stale, err := c.call(ctx, "ISteamUser/GetPlayerSummaries/v2", params, playerSummariesTTL, &envelope)
+	if err != nil {
+		return nil, err
+	}
+	result := &envelope.Response
+	result.FetchedAt, result.Stale = fetchedAt, stale
+	return result, nil
+}
+
+// SteamID64 converts a 32-bit account ID, such as a userdata folder name,
+// to the 64-bit Steam ID the API expects. 64-bit IDs are returned as is.
+func SteamID64(id string) (string, error) {
+	value, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid Steam ID %q", id)
+	}
+	if value < steamID64Base {
+		value += steamID64Base
+	}
+	return strconv.FormatUint(value, 10), nil
+}
@@ -0,0 +1,161 @@
+package tsdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// Every file of the store is a sequence of frames: a little-endian payload
+// length and CRC-32C, then the payload. A frame that is cut short or fails
+// its checksum marks the end of the intact data, e.g. after a crash.
+const frameHeaderSize = 8
+
+// maxFrameSize guards against allocating for a garbage length
+const maxFrameSize = 64 * 1024 * 1024
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var errCorrupt = errors.New("corrupt record")
+
+// appendFrame writes payload as one frame and returns the bytes written
+func appendFrame(w io.Writer, payload []byte) (int64, error) {
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:], crc32.Checksum(payload, crcTable))
+	n, err := w.Write(append(frame, payload...))
+	return int64(n), err
+}
+
+// readFrames calls fn with the offset and payload of each intact frame in
+// order and returns the offset just past the last one
+func readFrames(r io.Reader, fn func(offset int64, payload []byte) error) (int64, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, frameHeaderSize)
+	var offset int64
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			return offset, nil
+		}
+		size := binary.LittleEndian.Uint32(header[0:])
+		if size > maxFrameSize {
+			return offset, nil
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return offset, nil
+		}
+		if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:]) {
+			return offset, nil
+		}
+		if err := fn(offset, payload); err != nil {
+			return offset, err
+		}
+		offset += frameHeaderSize + int64(size)
+	}
+}
+
+// readFrameAt reads the payload of the frame at offset
+func readFrameAt(r io.ReaderAt, offset int64) ([]byte, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint32(header[0:])
+	if size > maxFrameSize {
+		return nil, errCorrupt
+	}
+	payload := make([]byte, size)
+	if _, err := r.ReadAt(payload, offset+frameHeaderSize); err != nil {
+		return nil, err
+	}
+	if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:]) {
+		return nil, errCorrupt
+	}
+	return payload, nil
+}
+
+// encoder builds a frame payload
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uvarint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *encoder) varint(v int64) {
+	e.buf = binary.AppendVarint(e.buf, v)
+}
+
+func (e *encoder) float(v float64) {
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v))
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// decoder reads a frame payload. The first error sticks, so values can be
+// read in a row and the error checked once.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = errCorrupt
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = errCorrupt
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) float() float64 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.buf) < 8 {
+		d.err = errCorrupt
+		return 0
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(d.buf))
+	d.buf = d.buf[8:]
+	return v
+}
+
+func (d *decoder) string() string {
+	size := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if uint64(len(d.buf)) < size {
+		d.err = errCorrupt
+		return ""
+	}
+	s := string(d.buf[:size])
+	d.buf = d.buf[size:]
+	return s
+}
@@ -0,0 +1,403 @@
+package tsdb
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+)
+
+// MaxQueryPoints is how many points per series Query aims to stay under
+// when choosing a resolution
+const MaxQueryPoints = 4000
+
+// rawResolution is the nominal interval of raw points, used to estimate
+// how many a query returns
+const rawResolution = time.Second
+
+// Matcher selects series by name and labels
+type Matcher struct {
+	Name   string // glob as in path.Match, e.g. "cpu.*"; empty matches all
+	Labels Labels // labels a series must have with these values
+}
+
+// matches reports whether a series is selected
+func (m Matcher) matches(s *series) bool {
+	if m.Name != "" {
+		if ok, _ := path.Match(m.Name, s.name); !ok {
+			return false
+		}
+	}
+	for k, v := range m.Labels {
+		if s.labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// aggPoint summarizes the points in a bucket; a raw point is a bucket of
+// one
+type aggPoint struct {
+	t     int64
+	min   float64
+	max   float64
+	sum   float64
+	count float64
+}
+
+func (p *aggPoint) merge(o aggPoint) {
+	if o.min < p.min {
+		p.min = o.min
+	}
+	if o.max > p.max {
+		p.max = o.max
+	}
+	p.sum += o.sum
+	p.count += o.count
+}
+
+// aggSeries is a series with summarized points, keyed by seriesKey
+type aggSeries struct {
+	name   string
+	labels Labels
+	points []aggPoint
+}
+
+// ListSeries returns the series selected by m, without points, ordered by
+// name and labels
+func (db *DB) ListSeries(m Matcher) []Series {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var result []Series
+	for _, s := range db.match(m) {
+		result = append(result, Series{Name: s.name, Labels: copyLabels(s.labels)})
+	}
+	return result
+}
+
+// Resolutions returns the resolutions of the rollups, finest first
+func (db *DB) Resolutions() []time.Duration {
+	tiers := db.rollups()
+	resolutions := make([]time.Duration, len(tiers))
+	for i, t := range tiers {
+		resolutions[i] = t.resolution
+	}
+	return resolutions
+}
+
+// Query returns the points of the series selected by m in [from, to], in
+// time order, at the finest resolution that covers the range and stays
+// within MaxQueryPoints. Series without points in the range are left out.
+func (db *DB) Query(m Matcher, from, to time.Time) ([]Series, error) {
+	tiers := db.rollups()
+	return db.queryLevel(tiers, db.chooseLevel(tiers, from, to), m, from, to)
+}
+
+// QueryResolution is Query at a given resolution, 0 for the raw points
+func (db *DB) QueryResolution(m Matcher, from, to time.Time, resolution time.Duration) ([]Series, error) {
+	tiers := db.rollups()
+	if resolution == 0 {
+		return db.queryLevel(tiers, 0, m, from, to)
+	}
+	for i, t := range tiers {
+		if t.resolution == resolution {
+			return db.queryLevel(tiers, i+1, m, from, to)
+		}
+	}
+	return nil, fmt.Errorf("no rollup with resolution %s", resolution)
+}
+
+// chooseLevel picks the raw points (level 0) or a rollup (level i+1 for
+// tier i). A level covers the range if it holds data from its start, or
+// from as early as any level does.
+func (db *DB) chooseLevel(tiers []*tier, from, to time.Time) int {
+	levels := len(tiers) + 1
+	earliest := make([]int64, levels)
+	first := int64(-1)
+	for level := 0; level < levels; level++ {
+		t, ok := db.levelEarliest(tiers, level)
+		if !ok {
+			earliest[level] = -1
+			continue
+		}
+		earliest[level] = t
+		if first < 0 || t < first {
+			first = t
+		}
+	}
+
+	start := from.UnixMilli()
+	if start < first {
+		start = first
+	}
+	for level := 0; level < levels; level++ {
+		resolution := rawResolution
+		if level > 0 {
+			resolution = tiers[level-1].resolution
+		}
+		covers := earliest[level] >= 0 && earliest[level] <= start
+		if covers && to.Sub(from)/resolution <= MaxQueryPoints {
+			return level
+		}
+	}
+	return levels - 1
+}
+
+// rollups returns the open rollups, which Close clears
+func (db *DB) rollups() []*tier {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.tiers
+}
+
+// levelEarliest returns the time of the oldest point a level returns. A
+// rollup that has nothing yet is answered from the level below.
+func (db *DB) levelEarliest(tiers []*tier, level int) (int64, bool) {
+	if level == 0 {
+		return db.earliest()
+	}
+	if t, ok := tiers[level-1].db.earliest(); ok {
+		return t, true
+	}
+	return db.levelEarliest(tiers, level-1)
+}
+
+// earliest returns the time of the oldest stored point
+func (db *DB) earliest() (int64, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var t int64
+	found := false
+	for _, seg := range db.segments {
+		if len(seg.blocks) > 0 && (!found || seg.minT < t) {
+			t, found = seg.minT, true
+		}
+	}
+	if len(db.head) > 0 && (!found || db.headMin < t) {
+		t, found = db.headMin, true
+	}
+	return t, found
+}
+
+// queryLevel queries a level and converts the result to Series
+func (db *DB) queryLevel(tiers []*tier, level int, m Matcher, from, to time.Time) ([]Series, error) {
+	aggregated, err := db.aggregate(tiers, level, m, from.UnixMilli(), to.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+
+	var resolution time.Duration
+	if level > 0 {
+		resolution = tiers[level-1].resolution
+	}
+	result := make([]Series, 0, len(aggregated))
+	for _, s := range aggregated {
+		series := Series{Name: s.name, Labels: s.labels, Resolution: resolution, Points: make([]Point, len(s.points))}
+		for i, p := range s.points {
+			series.Points[i] = Point{Time: time.UnixMilli(p.t), Value: p.sum / p.count, Min: p.min, Max: p.max}
+		}
+		result = append(result, series)
+	}
+	return result, nil
+}
+
+// aggregate returns the summarized points of a level in [minT, maxT],
+// ordered by series key. The newest buckets of a rollup that haven't been
+// rolled up yet are computed from the level below.
+func (db *DB) aggregate(tiers []*tier, level int, m Matcher, minT, maxT int64) ([]*aggSeries, error) {
+	if level == 0 {
+		return db.queryRaw(m, minT, maxT)
+	}
+
+	t := tiers[level-1]
+	watermark := t.watermark.Load()
+	var result []*aggSeries
+	if minT < watermark {
+		end := maxT
+		if end >= watermark {
+			end = watermark - 1
+		}
+		rolled, err := t.query(m, minT, end)
+		if err != nil {
+			return nil, err
+		}
+		result = rolled
+	}
+	if maxT >= watermark {
+		start := minT
+		if start < watermark {
+			start = watermark
+		}
+		finer, err := db.aggregate(tiers, level-1, m, start, maxT)
+		if err != nil {
+			return nil, err
+		}
+		result = mergeSeries(result, bucketize(finer, t.resolution.Milliseconds()))
+	}
+	return result, nil
+}
+
+// queryRaw returns the raw points of the series selected by m in
+// [minT, maxT], leaving out series without any
+func (db *DB) queryRaw(m Matcher, minT, maxT int64) ([]*aggSeries, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	selected := db.match(m)
+	wanted := make(map[uint64]bool, len(selected))
+	for _, s := range selected {
+		wanted[s.id] = true
+	}
+
+	points := make(map[uint64][]point)
+	for _, seg := range db.segments {
+		if len(seg.blocks) == 0 || seg.maxT < minT || seg.minT > maxT {
+			continue
+		}
+		if err := readSegment(seg, wanted, minT, maxT, points); err != nil {
+			return nil, err
+		}
+	}
+	for id, head := range db.head {
+		if !wanted[id] {
+			continue
+		}
+		for _, p := range head {
+			if p.t >= minT && p.t <= maxT {
+				points[id] = append(points[id], p)
+			}
+		}
+	}
+
+	var result []*aggSeries
+	for _, s := range selected {
+		ps := points[s.id]
+		if len(ps) == 0 {
+			continue
+		}
+		sort.SliceStable(ps, func(i, j int) bool { return ps[i].t < ps[j].t })
+		series := &aggSeries{name: s.name, labels: copyLabels(s.labels), points: make([]aggPoint, len(ps))}
+		for i, p := range ps {
+			series.points[i] = aggPoint{p.t, p.v, p.v, p.v, 1}
+		}
+		result = append(result, series)
+	}
+	return result, nil
+}
+
+// readSegment adds the points of the wanted series in [minT, maxT] from a
+// segment's blocks
+func readSegment(seg *segment, wanted map[uint64]bool, minT, maxT int64, points map[uint64][]point) error {
+	var file *os.File
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	for _, ref := range seg.blocks {
+		if !wanted[ref.series] || ref.maxT < minT || ref.minT > maxT {
+			continue
+		}
+		if file == nil {
+			var err error
+			if file, err = os.Open(seg.path); err != nil {
+				return err
+			}
+		}
+		payload, err := readFrameAt(file, ref.offset)
+		if err != nil {
+			return fmt.Errorf("failed to read block of %s: %w", seg.path, err)
+		}
+		id, block, err := decodeBlock(payload)
+		if err != nil || id != ref.series {
+			return fmt.Errorf("failed to read block of %s: %w", seg.path, errCorrupt)
+		}
+		for _, p := range block {
+			if p.t >= minT && p.t <= maxT {
+				points[id] = append(points[id], p)
+			}
+		}
+	}
+	return nil
+}
+
+// bucketize summarizes points into buckets of the given width, each
+// stamped with its start
+func bucketize(series []*aggSeries, width int64) []*aggSeries {
+	result := make([]*aggSeries, 0, len(series))
+	for _, s := range series {
+		bucketed := &aggSeries{name: s.name, labels: s.labels}
+		for _, p := range s.points {
+			p.t = floorTime(p.t, width)
+			if n := len(bucketed.points); n > 0 && bucketed.points[n-1].t == p.t {
+				bucketed.points[n-1].merge(p)
+				continue
+			}
+			bucketed.points = append(bucketed.points, p)
+		}
+		result = append(result, bucketed)
+	}
+	return result
+}
+
+// mergeSeries appends the points of b to those of the same series in a.
+// Both are ordered by series key, and b's points follow a's in time.
+func mergeSeries(a, b []*aggSeries) []*aggSeries {
+	byKey := make(map[string]*aggSeries, len(a))
+	for _, s := range a {
+		byKey[seriesKey(s.name, s.labels)] = s
+	}
+	for _, s := range b {
+		if existing, ok := byKey[seriesKey(s.name, s.labels)]; ok {
+			existing.points = append(existing.points, s.points...)
+			continue
+		}
+		a = append(a, s)
+	}
+	sort.Slice(a, func(i, j int) bool {
+		return seriesKey(a[i].name, a[i].labels) < seriesKey(a[j].name, a[j].labels)
+	})
+	return a
+}
+
+// floorTime rounds t down to a multiple of width
+func floorTime(t, width int64) int64 {
+	r := t % width
+	if r < 0 {
+		r += width
+	}
+	return t - r
+}
+
+// match returns the series selected by m, ordered by their key
+func (db *DB) match(m Matcher) []*series {
+	var selected []*series
+	for _, s := range db.series {
+		if m.matches(s) {
+			selected = append(selected, s)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return seriesKey(selected[i].name, selected[i].labels) < seriesKey(selected[j].name, selected[j].labels)
+	})
+	return selected
+}
+
+// Key returns the series' identity, e.g. disk.used_percent{device="sda"}
+func (s Series) Key() string {
+	return seriesKey(s.Name, s.Labels)
+}
+
+func copyLabels(labels Labels) Labels {
+	c := make(Labels, len(labels))
+	for k, v := range labels {
+		c[k] = v
+	}
+	return c
+}
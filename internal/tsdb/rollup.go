@@ -0,0 +1,294 @@
+package tsdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Rollup keeps a series' min, average and max per interval for longer than
+// the raw points
+type Rollup struct {
+	Resolution time.Duration // each a multiple of the previous rollup's
+	Retention  time.Duration // 0 keeps everything
+}
+
+// Rollups are stored as stores of their own in subdirectories named after
+// their resolution, e.g. rollup-1m
+const rollupDirPrefix = "rollup-"
+
+// aggLabel tells apart the series a rollup keeps for each source series
+const aggLabel = "__agg"
+
+// Aggregates kept per bucket. The average is derived from sum and count,
+// so coarser rollups can be computed from finer ones exactly.
+var aggregates = []string{"min", "max", "sum", "count"}
+
+// Background rollup timing
+const (
+	rollupInterval = time.Minute
+	// rollupDelay leaves time for samples to arrive before a bucket is
+	// considered complete
+	rollupDelay = 30 * time.Second
+)
+
+// tier is a rollup with its store
+type tier struct {
+	resolution time.Duration
+	db         *DB
+	// watermark is the end of the rolled up range: buckets before it are
+	// complete
+	watermark atomic.Int64
+}
+
+func rollupDir(dir string, resolution time.Duration) string {
+	name := resolution.String()
+	switch {
+	case resolution%time.Hour == 0:
+		name = strconv.FormatInt(int64(resolution/time.Hour), 10) + "h"
+	case resolution%time.Minute == 0:
+		name = strconv.FormatInt(int64(resolution/time.Minute), 10) + "m"
+	}
+	return filepath.Join(dir, rollupDirPrefix+name)
+}
+
+// openTiers opens the configured rollups, or for a read-only store the
+// ones found on disk
+func (db *DB) openTiers() error {
+	rollups := db.options.Rollups
+	if db.options.ReadOnly {
+		rollups = nil
+		dirs, err := filepath.Glob(filepath.Join(db.dir, rollupDirPrefix+"*"))
+		if err != nil {
+			return err
+		}
+		for _, dir := range dirs {
+			resolution, err := time.ParseDuration(strings.TrimPrefix(filepath.Base(dir), rollupDirPrefix))
+			if err == nil && resolution > 0 {
+				rollups = append(rollups, Rollup{Resolution: resolution})
+			}
+		}
+	}
+	sort.Slice(rollups, func(i, j int) bool { return rollups[i].Resolution < rollups[j].Resolution })
+
+	for i, r := range rollups {
+		if r.Resolution < time.Second {
+			return fmt.Errorf("rollup resolution %s is below a second", r.Resolution)
+		}
+		if i > 0 && r.Resolution%rollups[i-1].Resolution != 0 {
+			return fmt.Errorf("rollup resolution %s must be a multiple of %s", r.Resolution, rollups[i-1].Resolution)
+		}
+
+		// A block per hundred buckets keeps blocks reasonably sized
+		block := 100 * r.Resolution
+		segment := 8 * block
+		if segment < DefaultSegmentDuration {
+			segment = DefaultSegmentDuration
+		}
+		dir := rollupDir(db.dir, r.Resolution)
+		tierDB, err := Open(dir, Options{
+			ReadOnly:        db.options.ReadOnly,
+			BlockDuration:   block,
+			SegmentDuration: segment,
+			Retention:       r.Retention,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to open %s rollup: %w", r.Resolution, err)
+		}
+		t := &tier{resolution: r.Resolution, db: tierDB}
+		db.tiers = append(db.tiers, t)
+
+		watermark, err := readWatermark(dir)
+		if err != nil {
+			return err
+		}
+		t.watermark.Store(watermark)
+	}
+	return nil
+}
+
+// rollUpLoop rolls up new data in the background until the store is closed
+func (db *DB) rollUpLoop() {
+	defer close(db.rollupsDone)
+
+	ticker := time.NewTicker(rollupInterval)
+	defer ticker.Stop()
+	for {
+		db.rollUp(time.Now())
+		select {
+		case <-ticker.C:
+		case <-db.stopRollups:
+			return
+		}
+	}
+}
+
+// rollUp brings every rollup up to date, finest first
+func (db *DB) rollUp(now time.Time) {
+	for i := range db.tiers {
+		if err := db.rollUpTier(i, now); err != nil {
+			logrus.WithError(err).WithField("resolution", db.tiers[i].resolution).Error("Failed to roll up history")
+			return
+		}
+	}
+}
+
+// rollUpTier adds the buckets of a rollup that are complete in the level
+// below, a few dozen at a time
+func (db *DB) rollUpTier(i int, now time.Time) error {
+	t := db.tiers[i]
+	width := t.resolution.Milliseconds()
+
+	limit := now.Add(-rollupDelay).UnixMilli()
+	if i > 0 {
+		limit = db.tiers[i-1].watermark.Load()
+	}
+	end := floorTime(limit, width)
+
+	start := t.watermark.Load()
+	if start == 0 {
+		earliest, ok := db.levelEarliest(db.tiers, i)
+		if !ok {
+			return nil
+		}
+		start = floorTime(earliest, width)
+	}
+
+	for start < end {
+		stop := start + 60*width
+		if stop > end {
+			stop = end
+		}
+		source, err := db.aggregate(db.tiers, i, Matcher{}, start, stop-1)
+		if err != nil {
+			return err
+		}
+		if err := t.append(bucketize(source, width)); err != nil {
+			return err
+		}
+		if err := t.db.Flush(); err != nil {
+			return err
+		}
+		if err := writeWatermark(t.db.dir, stop); err != nil {
+			return err
+		}
+		t.watermark.Store(stop)
+		start = stop
+	}
+	return nil
+}
+
+// append stores buckets, one append per bucket time
+func (t *tier) append(series []*aggSeries) error {
+	byTime := make(map[int64][]Sample)
+	for _, s := range series {
+		for _, p := range s.points {
+			values := map[string]float64{"min": p.min, "max": p.max, "sum": p.sum, "count": p.count}
+			for _, agg := range aggregates {
+				labels := copyLabels(s.labels)
+				labels[aggLabel] = agg
+				byTime[p.t] = append(byTime[p.t], Sample{Name: s.name, Labels: labels, Value: values[agg]})
+			}
+		}
+	}
+
+	times := make([]int64, 0, len(byTime))
+	for bucket := range byTime {
+		times = append(times, bucket)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	for _, bucket := range times {
+		if err := t.db.Append(time.UnixMilli(bucket), byTime[bucket]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// query returns a rollup's buckets in [minT, maxT], with the aggregates of
+// each source series joined back together
+func (t *tier) query(m Matcher, minT, maxT int64) ([]*aggSeries, error) {
+	stored, err := t.db.queryRaw(m, minT, maxT)
+	if err != nil {
+		return nil, err
+	}
+
+	bySeries := make(map[string]*aggSeries)
+	buckets := make(map[string]map[int64]*aggPoint)
+	for _, s := range stored {
+		agg := s.labels[aggLabel]
+		labels := copyLabels(s.labels)
+		delete(labels, aggLabel)
+		key := seriesKey(s.name, labels)
+		if _, exists := bySeries[key]; !exists {
+			bySeries[key] = &aggSeries{name: s.name, labels: labels}
+			buckets[key] = make(map[int64]*aggPoint)
+		}
+		for _, p := range s.points {
+			bucket, exists := buckets[key][p.t]
+			if !exists {
+				bucket = &aggPoint{t: p.t}
+				buckets[key][p.t] = bucket
+			}
+			// Raw points of the rollup's store carry the value in sum
+			switch agg {
+			case "min":
+				bucket.min = p.sum
+			case "max":
+				bucket.max = p.sum
+			case "sum":
+				bucket.sum = p.sum
+			case "count":
+				bucket.count = p.sum
+			}
+		}
+	}
+
+	result := make([]*aggSeries, 0, len(bySeries))
+	for key, s := range bySeries {
+		for _, bucket := range buckets[key] {
+			if bucket.count > 0 {
+				s.points = append(s.points, *bucket)
+			}
+		}
+		sort.Slice(s.points, func(i, j int) bool { return s.points[i].t < s.points[j].t })
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return seriesKey(result[i].name, result[i].labels) < seriesKey(result[j].name, result[j].labels)
+	})
+	return result, nil
+}
+
+// The watermark is kept in a small text file, replaced atomically
+const watermarkFile = "watermark"
+
+func readWatermark(dir string) (int64, error) {
+	data, err := os.ReadFile(filepath.Join(dir, watermarkFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	watermark, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rollup watermark in %s: %w", dir, err)
+	}
+	return watermark, nil
+}
+
+func writeWatermark(dir string, watermark int64) error {
+	path := filepath.Join(dir, watermarkFile)
+	if err := os.WriteFile(path+".tmp", []byte(strconv.FormatInt(watermark, 10)+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,216 @@
+package tsdb
+
+import (
+	"testing"
+	"time"
+)
+
+var testRollups = []Rollup{{Resolution: time.Minute}, {Resolution: time.Hour}}
+
+// writeRaw stores a point every 10s in [from, to) without rollups, so none
+// are rolled up while they are appended, and returns their values by time
+func writeRaw(t *testing.T, dir string, from, to time.Time) map[int64]float64 {
+	t.Helper()
+	db, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[int64]float64)
+	for at, i := from, 0; at.Before(to); at, i = at.Add(10*time.Second), i+1 {
+		value := float64(i%7) - 2
+		appendValue(t, db, at, value)
+		values[at.UnixMilli()] = value
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+// rollUpAll opens dir with rollups and waits for the background rollup to
+// catch up with what is complete
+func rollUpAll(t *testing.T, dir string) {
+	t.Helper()
+	db, err := Open(dir, Options{Rollups: testRollups})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	now := time.Now().Add(-rollupDelay).UnixMilli()
+	deadline := time.Now().Add(10 * time.Second)
+	for _, tier := range db.tiers {
+		want := floorTime(now, tier.resolution.Milliseconds())
+		for tier.watermark.Load() < want {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s rollup at %d, want %d", tier.resolution, tier.watermark.Load(), want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+// expectedBuckets sums raw values per bucket of width before end
+func expectedBuckets(values map[int64]float64, width, end int64) map[int64]aggPoint {
+	buckets := make(map[int64]aggPoint)
+	for at, value := range values {
+		bucket := floorTime(at, width)
+		if bucket+width > end {
+			continue
+		}
+		p, exists := buckets[bucket]
+		if !exists {
+			p = aggPoint{t: bucket, min: value, max: value}
+		}
+		p.merge(aggPoint{min: value, max: value, sum: value, count: 1})
+		buckets[bucket] = p
+	}
+	return buckets
+}
+
+func TestRollupsAreExact(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	values := writeRaw(t, dir, now.Add(-3*time.Hour), now.Add(-time.Hour))
+	rollUpAll(t, dir)
+
+	db, err := Open(dir, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if len(db.tiers) != 2 {
+		t.Fatalf("%d rollups found, want 2", len(db.tiers))
+	}
+
+	// The hourly buckets are computed from the minute ones, which must add
+	// up to exactly the raw points
+	for _, tier := range db.tiers {
+		width := tier.resolution.Milliseconds()
+		watermark := tier.watermark.Load()
+		stored, err := tier.query(Matcher{}, 0, watermark-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := expectedBuckets(values, width, watermark)
+		if len(stored) != 1 || len(stored[0].points) != len(want) {
+			t.Fatalf("%s rollup holds %+v, want %d buckets", tier.resolution, stored, len(want))
+		}
+		for _, got := range stored[0].points {
+			if got != want[got.t] {
+				t.Errorf("%s bucket %s = %+v, want %+v", tier.resolution, time.UnixMilli(got.t), got, want[got.t])
+			}
+		}
+	}
+}
+
+func TestRollupWatermarkSurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	values := writeRaw(t, dir, now.Add(-2*time.Hour), now.Add(-30*time.Minute))
+	rollUpAll(t, dir)
+
+	db, err := Open(dir, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var watermarks []int64
+	for _, tier := range db.tiers {
+		persisted, err := readWatermark(rollupDir(dir, tier.resolution))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if persisted == 0 || persisted != tier.watermark.Load() {
+			t.Errorf("%s watermark %d, persisted %d", tier.resolution, tier.watermark.Load(), persisted)
+		}
+		watermarks = append(watermarks, persisted)
+	}
+	db.Close()
+
+	// Reopening continues from the watermark rather than rolling up the
+	// same buckets again
+	rollUpAll(t, dir)
+	db, err = Open(dir, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for i, tier := range db.tiers {
+		if tier.watermark.Load() < watermarks[i] {
+			t.Errorf("%s watermark went back from %d to %d", tier.resolution, watermarks[i], tier.watermark.Load())
+		}
+		stored, err := tier.query(Matcher{}, 0, tier.watermark.Load()-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var count float64
+		for _, p := range stored[0].points {
+			count += p.count
+		}
+		if want := expectedBuckets(values, tier.resolution.Milliseconds(), tier.watermark.Load()); count != sumCounts(want) {
+			t.Errorf("%s rollup counts %v points after reopening, want %v", tier.resolution, count, sumCounts(want))
+		}
+	}
+}
+
+func sumCounts(buckets map[int64]aggPoint) float64 {
+	var count float64
+	for _, p := range buckets {
+		count += p.count
+	}
+	return count
+}
+
+func TestQueryAcrossWatermark(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	values := writeRaw(t, dir, now.Add(-90*time.Minute), now.Add(-20*time.Minute))
+	rollUpAll(t, dir)
+
+	// Points newer than the minute rollup's watermark are only raw
+	db, err := Open(dir, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	watermark := db.tiers[0].watermark.Load()
+	db.Close()
+	for at, value := range writeRaw(t, dir, time.UnixMilli(watermark), time.UnixMilli(watermark).Add(2*time.Minute)) {
+		values[at] = value
+	}
+
+	db, err = Open(dir, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// Buckets are stamped with their start, so the range covers whole hours
+	hour := time.Hour.Milliseconds()
+	from, to := floorTime(now.Add(-2*time.Hour).UnixMilli(), hour), floorTime(now.UnixMilli(), hour)+2*hour-1
+	aggregated, err := db.aggregate(db.tiers, 1, Matcher{}, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := expectedBuckets(values, time.Minute.Milliseconds(), to+1)
+	if len(aggregated) != 1 || len(aggregated[0].points) != len(want) {
+		t.Fatalf("aggregate() = %+v, want %d buckets", aggregated, len(want))
+	}
+	for _, got := range aggregated[0].points {
+		if got != want[got.t] {
+			t.Errorf("bucket %s = %+v, want %+v", time.UnixMilli(got.t), got, want[got.t])
+		}
+	}
+
+	// The hourly level below its watermark comes from the minute level,
+	// itself split at its own watermark
+	hourly, err := db.aggregate(db.tiers, 2, Matcher{}, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count float64
+	for _, p := range hourly[0].points {
+		count += p.count
+	}
+	if count != float64(len(values)) {
+		t.Errorf("hourly buckets count %v points, want %d", count, len(values))
+	}
+}
@@ -0,0 +1,190 @@
+package tsdb
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File names of a segment: the blocks, and the index written once it is
+// sealed
+const (
+	segmentExt = ".seg"
+	indexExt   = ".idx"
+)
+
+// segmentTimeFormat names segments after their first point, in UTC
+const segmentTimeFormat = "20060102-150405"
+
+// segment is a file of blocks, each holding a run of points of one series.
+// The active segment is appended to; sealed ones get an index file listing
+// their blocks, so they don't have to be scanned on open.
+type segment struct {
+	path   string
+	minT   int64 // milliseconds since the epoch
+	maxT   int64
+	blocks []blockRef
+}
+
+// blockRef locates a block in its segment
+type blockRef struct {
+	series uint64
+	offset int64
+	minT   int64
+	maxT   int64
+}
+
+func (s *segment) indexPath() string {
+	return strings.TrimSuffix(s.path, segmentExt) + indexExt
+}
+
+func (s *segment) add(ref blockRef) {
+	if len(s.blocks) == 0 || ref.minT < s.minT {
+		s.minT = ref.minT
+	}
+	if len(s.blocks) == 0 || ref.maxT > s.maxT {
+		s.maxT = ref.maxT
+	}
+	s.blocks = append(s.blocks, ref)
+}
+
+// loadSegment reads a segment's block list from its index, or by scanning
+// it if there is no index yet. The returned size is where intact data ends.
+func loadSegment(path string) (*segment, int64, error) {
+	s := &segment{path: path}
+
+	if file, err := os.Open(s.indexPath()); err == nil {
+		defer file.Close()
+		_, err := readFrames(file, func(_ int64, payload []byte) error {
+			d := decoder{buf: payload}
+			ref := blockRef{series: d.uvarint(), offset: int64(d.uvarint()), minT: d.varint(), maxT: d.varint()}
+			if d.err != nil {
+				return d.err
+			}
+			s.add(ref)
+			return nil
+		})
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read index of %s: %w", filepath.Base(path), err)
+		}
+		return s, -1, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	size, err := readFrames(file, func(offset int64, payload []byte) error {
+		series, points, err := decodeBlock(payload)
+		if err != nil {
+			return err
+		}
+		if len(points) > 0 {
+			s.add(blockRef{series, offset, points[0].t, points[len(points)-1].t})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to scan %s: %w", filepath.Base(path), err)
+	}
+	return s, size, nil
+}
+
+// writeIndex seals a segment by writing its block list next to it
+func (s *segment) writeIndex() error {
+	tmp := s.indexPath() + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	for _, ref := range s.blocks {
+		var e encoder
+		e.uvarint(ref.series)
+		e.uvarint(uint64(ref.offset))
+		e.varint(ref.minT)
+		e.varint(ref.maxT)
+		if _, err := appendFrame(w, e.buf); err != nil {
+			file.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write index of %s: %w", filepath.Base(s.path), err)
+	}
+	return os.Rename(tmp, s.indexPath())
+}
+
+// remove deletes a segment and its index
+func (s *segment) remove() error {
+	if err := os.Remove(s.indexPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(s.path)
+}
+
+// encodeBlock encodes points of one series, in time order, as varints. The
+// first timestamp is stored as is, the others as the delta to the previous
+// one. Each value's bits are XORed with the previous value's and reversed
+// byte-wise, so a repeated value takes a single byte and a whole number a
+// few; values that differ in their low mantissa bytes take up to ten.
+func encodeBlock(series uint64, points []point) []byte {
+	var e encoder
+	e.uvarint(series)
+	e.uvarint(uint64(len(points)))
+	var prevT int64
+	var prevV uint64
+	for i, p := range points {
+		if i == 0 {
+			e.varint(p.t)
+		} else {
+			e.varint(p.t - prevT)
+		}
+		v := math.Float64bits(p.v)
+		// Whole numbers differ in the sign, exponent and high mantissa
+		// bytes only; reversed, those become the varint's low bytes
+		e.uvarint(bits.ReverseBytes64(v ^ prevV))
+		prevT, prevV = p.t, v
+	}
+	return e.buf
+}
+
+func decodeBlock(payload []byte) (uint64, []point, error) {
+	d := decoder{buf: payload}
+	series := d.uvarint()
+	count := d.uvarint()
+	if d.err != nil || count > uint64(len(payload)) {
+		return 0, nil, errCorrupt
+	}
+	points := make([]point, count)
+	var prevT int64
+	var prevV uint64
+	for i := range points {
+		t := d.varint()
+		if i > 0 {
+			t += prevT
+		}
+		v := bits.ReverseBytes64(d.uvarint()) ^ prevV
+		points[i] = point{t, math.Float64frombits(v)}
+		prevT, prevV = t, v
+	}
+	if d.err != nil {
+		return 0, nil, d.err
+	}
+	return series, points, nil
+}
@@ -0,0 +1,633 @@
+// Package tsdb is a small embedded time-series store for the monitor's
+// metric history.
+//
+// Points are appended to a write-ahead log and kept in memory (the head)
+// until the head spans BlockDuration. The head is then written to the active
+// segment as one block per series, and the log is cleared. Segments are
+// sealed after SegmentDuration by writing an index of their blocks, so a
+// query only reads the blocks of the series and time range it asks for.
+// Every file is made of checksummed frames; a torn write at the end of a
+// file after a crash is detected and discarded on open.
+//
+// Rollups keep the min, average and max of every series per minute, hour
+// or other interval for longer than the raw points. They are computed in
+// the background and queries pick the finest resolution that covers the
+// requested range.
+//
+// A store has a single writer. Any number of read-only instances, e.g. a
+// CLI query, can open it alongside and see the data written up to then.
+package tsdb
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// File names in the store's directory
+const (
+	seriesFile = "series"
+	walFile    = "wal"
+)
+
+// Defaults for unset Options
+const (
+	DefaultBlockDuration   = 10 * time.Minute
+	DefaultSegmentDuration = 24 * time.Hour
+)
+
+// Options configures a store. Zero values select the defaults.
+type Options struct {
+	ReadOnly        bool
+	BlockDuration   time.Duration // how much is kept in memory before being written as blocks
+	SegmentDuration time.Duration // how much a segment spans before it is sealed
+	Retention       time.Duration // sealed segments older than this are deleted; 0 keeps them
+	Rollups         []Rollup      // ignored when read-only, which uses the rollups on disk
+}
+
+// Labels identify a series along with its name, e.g. {"device": "sda"}
+type Labels map[string]string
+
+// Sample is a value of a series to be appended
+type Sample struct {
+	Name   string
+	Labels Labels
+	Value  float64
+}
+
+// Point is a value of a series at a time. For a rollup, Value is the
+// average over the interval starting at Time; Min and Max equal Value for
+// raw points.
+type Point struct {
+	Time  time.Time
+	Value float64
+	Min   float64
+	Max   float64
+}
+
+// Series is a series with its points in a queried time range
+type Series struct {
+	Name       string
+	Labels     Labels
+	Resolution time.Duration // 0 for raw points
+	Points     []Point
+}
+
+// series is a known series. IDs are assigned in order of first appearance.
+type series struct {
+	id     uint64
+	name   string
+	labels Labels
+}
+
+type point struct {
+	t int64 // milliseconds since the epoch
+	v float64
+}
+
+// DB is an open store
+type DB struct {
+	dir     string
+	options Options
+	mu      sync.RWMutex
+
+	series []*series
+	byKey  map[string]*series
+
+	segments []*segment // ordered by time; the last one is active
+
+	head    map[uint64][]point
+	headMin int64
+
+	// Open files of a writable store
+	seriesOut *os.File
+	wal       *os.File
+	walBuf    *bufio.Writer
+	active    *os.File
+	activeEnd int64
+
+	tiers       []*tier // rollups, finest first
+	stopRollups chan struct{}
+	rollupsDone chan struct{}
+}
+
+// Open opens the store in dir, creating it unless read-only
+func Open(dir string, options Options) (*DB, error) {
+	if options.BlockDuration <= 0 {
+		options.BlockDuration = DefaultBlockDuration
+	}
+	if options.SegmentDuration <= 0 {
+		options.SegmentDuration = DefaultSegmentDuration
+	}
+	if !options.ReadOnly {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create store directory: %w", err)
+		}
+	}
+
+	db := &DB{
+		dir:     dir,
+		options: options,
+		byKey:   make(map[string]*series),
+		head:    make(map[uint64][]point),
+	}
+	if err := db.load(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := db.openTiers(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if !options.ReadOnly && len(db.tiers) > 0 {
+		db.stopRollups = make(chan struct{})
+		db.rollupsDone = make(chan struct{})
+		go db.rollUpLoop()
+	}
+	return db, nil
+}
+
+// load reads the series, segments and write-ahead log, discarding torn
+// writes at their ends if writable
+func (db *DB) load() error {
+	size, err := db.readFile(seriesFile, func(_ int64, payload []byte) error {
+		d := decoder{buf: payload}
+		s := &series{id: d.uvarint(), name: d.string(), labels: Labels{}}
+		for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+			k := d.string()
+			s.labels[k] = d.string()
+		}
+		if d.err != nil || s.id != uint64(len(db.series)) {
+			return fmt.Errorf("series file: %w", errCorrupt)
+		}
+		db.series = append(db.series, s)
+		db.byKey[seriesKey(s.name, s.labels)] = s
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if db.seriesOut, err = db.openForAppend(seriesFile, size); err != nil {
+		return err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(db.dir, "*"+segmentExt))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	for i, path := range paths {
+		seg, size, err := loadSegment(path)
+		if err != nil {
+			return err
+		}
+		if size >= 0 && !db.options.ReadOnly {
+			if i < len(paths)-1 {
+				// A crash between sealing and starting the next segment
+				if err := seg.writeIndex(); err != nil {
+					return err
+				}
+			} else {
+				if db.active, err = db.openForAppend(filepath.Base(path), size); err != nil {
+					return err
+				}
+				db.activeEnd = size
+			}
+		}
+		db.segments = append(db.segments, seg)
+	}
+
+	// Replay the points not yet written to a segment
+	var entries []walEntry
+	size, err = db.readFile(walFile, func(_ int64, payload []byte) error {
+		t, samples, err := decodeWAL(payload)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, walEntry{t, samples})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	flushed, err := db.flushedPoints(entries)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		for _, s := range entry.samples {
+			if s.series >= uint64(len(db.series)) {
+				continue
+			}
+			p := point{entry.t, s.value}
+			key := flushedPoint{s.series, p.t, math.Float64bits(p.v)}
+			if flushed[key] > 0 {
+				flushed[key]--
+				continue
+			}
+			db.addToHead(s.series, p)
+		}
+	}
+	if db.wal, err = db.openForAppend(walFile, size); err != nil {
+		return err
+	}
+	if db.wal != nil {
+		db.walBuf = bufio.NewWriter(db.wal)
+	}
+
+	return db.applyRetention(time.Now())
+}
+
+// flushedPoint identifies a point written to a segment
+type flushedPoint struct {
+	series uint64
+	t      int64
+	bits   uint64
+}
+
+// flushedPoints counts the points of the log's series and time range that
+// segments already hold. A crash between writing the head to a segment and
+// clearing the log leaves points in both; late points in the same range
+// that were never written aren't among them.
+func (db *DB) flushedPoints(entries []walEntry) (map[flushedPoint]int, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	wanted := make(map[uint64]bool)
+	minT, maxT := entries[0].t, entries[0].t
+	for _, entry := range entries {
+		minT, maxT = min(minT, entry.t), max(maxT, entry.t)
+		for _, s := range entry.samples {
+			wanted[s.series] = true
+		}
+	}
+
+	points := make(map[uint64][]point)
+	for _, seg := range db.segments {
+		if len(seg.blocks) == 0 || seg.maxT < minT || seg.minT > maxT {
+			continue
+		}
+		if err := readSegment(seg, wanted, minT, maxT, points); err != nil {
+			return nil, err
+		}
+	}
+	flushed := make(map[flushedPoint]int)
+	for id, ps := range points {
+		for _, p := range ps {
+			flushed[flushedPoint{id, p.t, math.Float64bits(p.v)}]++
+		}
+	}
+	return flushed, nil
+}
+
+// readFile reads the frames of a file in the store, if it exists
+func (db *DB) readFile(name string, fn func(offset int64, payload []byte) error) (int64, error) {
+	file, err := os.Open(filepath.Join(db.dir, name))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	size, err := readFrames(file, fn)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return size, nil
+}
+
+// openForAppend opens a file of a writable store for appending after
+// truncating it to its intact size. Read-only stores get nil.
+func (db *DB) openForAppend(name string, size int64) (*os.File, error) {
+	if db.options.ReadOnly {
+		return nil, nil
+	}
+	file, err := os.OpenFile(filepath.Join(db.dir, name), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to truncate %s: %w", name, err)
+	}
+	if _, err := file.Seek(size, 0); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// Append adds samples taken at t. Points reach the write-ahead log on the
+// next Flush.
+func (db *DB) Append(t time.Time, samples []Sample) error {
+	if db.options.ReadOnly {
+		return fmt.Errorf("store is read-only")
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ms := t.UnixMilli()
+	if len(db.head) > 0 && ms-db.headMin >= db.options.BlockDuration.Milliseconds() {
+		if err := db.cut(); err != nil {
+			return err
+		}
+	}
+
+	entries := make([]walSample, 0, len(samples))
+	for _, sample := range samples {
+		s, err := db.getOrCreate(sample.Name, sample.Labels)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, walSample{s.id, sample.Value})
+	}
+
+	if _, err := appendFrame(db.walBuf, encodeWAL(ms, entries)); err != nil {
+		return fmt.Errorf("failed to write to log: %w", err)
+	}
+	for _, entry := range entries {
+		db.addToHead(entry.series, point{ms, entry.value})
+	}
+	return nil
+}
+
+func (db *DB) getOrCreate(name string, labels Labels) (*series, error) {
+	key := seriesKey(name, labels)
+	if s, exists := db.byKey[key]; exists {
+		return s, nil
+	}
+
+	s := &series{id: uint64(len(db.series)), name: name, labels: Labels{}}
+	for k, v := range labels {
+		s.labels[k] = v
+	}
+
+	var e encoder
+	e.uvarint(s.id)
+	e.string(s.name)
+	e.uvarint(uint64(len(s.labels)))
+	for _, k := range sortedKeys(s.labels) {
+		e.string(k)
+		e.string(s.labels[k])
+	}
+	// Written straight away, ahead of the log entries referring to it
+	if _, err := appendFrame(db.seriesOut, e.buf); err != nil {
+		return nil, fmt.Errorf("failed to add series %s: %w", key, err)
+	}
+
+	db.series = append(db.series, s)
+	db.byKey[key] = s
+	return s, nil
+}
+
+func (db *DB) addToHead(id uint64, p point) {
+	if len(db.head) == 0 || p.t < db.headMin {
+		db.headMin = p.t
+	}
+	db.head[id] = append(db.head[id], p)
+}
+
+// Flush makes the appended points durable
+func (db *DB) Flush() error {
+	if db.options.ReadOnly {
+		return nil
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.seriesOut.Sync(); err != nil {
+		return fmt.Errorf("failed to sync series: %w", err)
+	}
+	if err := db.walBuf.Flush(); err != nil {
+		return fmt.Errorf("failed to write to log: %w", err)
+	}
+	if err := db.wal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync log: %w", err)
+	}
+	return nil
+}
+
+// cut writes the head to the active segment as one block per series, then
+// clears the head and the write-ahead log
+func (db *DB) cut() error {
+	if err := db.startSegment(); err != nil {
+		return err
+	}
+	seg := db.segments[len(db.segments)-1]
+
+	if err := db.seriesOut.Sync(); err != nil {
+		return fmt.Errorf("failed to sync series: %w", err)
+	}
+
+	ids := make([]uint64, 0, len(db.head))
+	for id := range db.head {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	w := bufio.NewWriter(db.active)
+	offset := db.activeEnd
+	var refs []blockRef
+	for _, id := range ids {
+		points := db.head[id]
+		sort.SliceStable(points, func(i, j int) bool { return points[i].t < points[j].t })
+		n, err := appendFrame(w, encodeBlock(id, points))
+		if err != nil {
+			return fmt.Errorf("failed to write block: %w", err)
+		}
+		refs = append(refs, blockRef{id, offset, points[0].t, points[len(points)-1].t})
+		offset += n
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write block: %w", err)
+	}
+	if err := db.active.Sync(); err != nil {
+		return fmt.Errorf("failed to sync segment: %w", err)
+	}
+	for _, ref := range refs {
+		seg.add(ref)
+	}
+	db.activeEnd = offset
+	db.head = make(map[uint64][]point)
+
+	// Points found in the segment are skipped on replay, so a crash before
+	// the log is cleared doesn't duplicate them
+	db.walBuf.Reset(db.wal)
+	if err := db.wal.Truncate(0); err != nil {
+		return fmt.Errorf("failed to clear log: %w", err)
+	}
+	if _, err := db.wal.Seek(0, 0); err != nil {
+		return err
+	}
+
+	return db.applyRetention(time.Now())
+}
+
+// startSegment makes sure there is an active segment for the head, sealing
+// the current one once it spans SegmentDuration
+func (db *DB) startSegment() error {
+	if db.active != nil {
+		seg := db.segments[len(db.segments)-1]
+		if len(seg.blocks) == 0 || db.headMin-seg.minT < db.options.SegmentDuration.Milliseconds() {
+			return nil
+		}
+		if err := seg.writeIndex(); err != nil {
+			return err
+		}
+		if err := db.active.Close(); err != nil {
+			return err
+		}
+		db.active = nil
+	}
+
+	name := time.UnixMilli(db.headMin).UTC().Format(segmentTimeFormat)
+	path := filepath.Join(db.dir, name+segmentExt)
+	for i := 1; fileExists(path); i++ {
+		path = filepath.Join(db.dir, fmt.Sprintf("%s-%d%s", name, i, segmentExt))
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create segment: %w", err)
+	}
+	db.active = file
+	db.activeEnd = 0
+	db.segments = append(db.segments, &segment{path: path})
+	return nil
+}
+
+// applyRetention deletes sealed segments whose newest point is past the
+// retention period
+func (db *DB) applyRetention(now time.Time) error {
+	if db.options.ReadOnly || db.options.Retention <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-db.options.Retention).UnixMilli()
+
+	kept := db.segments[:0]
+	for i, seg := range db.segments {
+		active := i == len(db.segments)-1 && db.active != nil
+		if !active && seg.maxT < cutoff {
+			if err := seg.remove(); err != nil {
+				return fmt.Errorf("failed to remove expired segment: %w", err)
+			}
+			continue
+		}
+		kept = append(kept, seg)
+	}
+	db.segments = kept
+	return nil
+}
+
+// Close writes the head to a segment and closes the store
+func (db *DB) Close() error {
+	if db.stopRollups != nil {
+		close(db.stopRollups)
+		<-db.rollupsDone
+		db.stopRollups = nil
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// Queries hold on to the rollups they started with, see rollups
+	var err error
+	for _, t := range db.tiers {
+		if closeErr := t.db.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	db.tiers = nil
+
+	if db.wal != nil && len(db.head) > 0 {
+		if cutErr := db.cut(); err == nil {
+			err = cutErr
+		}
+	}
+	for _, file := range []*os.File{db.active, db.wal, db.seriesOut} {
+		if file == nil {
+			continue
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	db.active, db.wal, db.seriesOut = nil, nil, nil
+	return err
+}
+
+// walEntry is the samples appended at one time
+type walEntry struct {
+	t       int64
+	samples []walSample
+}
+
+// walSample is a value of a series in a log entry
+type walSample struct {
+	series uint64
+	value  float64
+}
+
+// encodeWAL encodes the samples appended at one time
+func encodeWAL(t int64, samples []walSample) []byte {
+	var e encoder
+	e.varint(t)
+	e.uvarint(uint64(len(samples)))
+	for _, s := range samples {
+		e.uvarint(s.series)
+		e.float(s.value)
+	}
+	return e.buf
+}
+
+func decodeWAL(payload []byte) (int64, []walSample, error) {
+	d := decoder{buf: payload}
+	t := d.varint()
+	count := d.uvarint()
+	if d.err != nil || count > uint64(len(payload)) {
+		return 0, nil, errCorrupt
+	}
+	samples := make([]walSample, count)
+	for i := range samples {
+		samples[i] = walSample{d.uvarint(), d.float()}
+	}
+	return t, samples, d.err
+}
+
+// seriesKey identifies a series, e.g. disk.used_percent{device="sda"}
+func seriesKey(name string, labels Labels) string {
+	if len(labels) == 0 {
+		return name
+	}
+	pairs := make([]string, 0, len(labels))
+	for _, k := range sortedKeys(labels) {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, labels[k]))
+	}
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+func sortedKeys(labels Labels) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
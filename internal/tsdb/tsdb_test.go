@@ -0,0 +1,129 @@
+package tsdb
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testStart = time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+
+func appendValue(t *testing.T, db *DB, at time.Time, value float64) {
+	t.Helper()
+	if err := db.Append(at, []Sample{{Name: "cpu.overall_percent", Value: value}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// queryValues returns the raw values of the test series over the day
+func queryValues(t *testing.T, db *DB) []float64 {
+	t.Helper()
+	series, err := db.QueryResolution(Matcher{}, testStart.Add(-time.Hour), testStart.Add(time.Hour), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values []float64
+	for _, s := range series {
+		for _, p := range s.Points {
+			values = append(values, p.Value)
+		}
+	}
+	return values
+}
+
+func TestReplayKeepsLatePoints(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendValue(t, db, testStart.Add(10*time.Second), 1)
+	appendValue(t, db, testStart.Add(20*time.Second), 2)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A point older than the newest one in the segment, e.g. after the
+	// clock was set back, then a crash before it was written out
+	db, err = Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendValue(t, db, testStart.Add(15*time.Second), 3)
+	if err := db.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(dir, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if values := queryValues(t, reopened); len(values) != 3 || values[1] != 3 {
+		t.Errorf("values after replay = %v, want [1 3 2]", values)
+	}
+}
+
+func TestReplaySkipsFlushedPoints(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendValue(t, db, testStart.Add(10*time.Second), 1)
+	appendValue(t, db, testStart.Add(20*time.Second), 2)
+	if err := db.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	wal, err := os.ReadFile(filepath.Join(dir, walFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash after the head was written out but before the log was
+	// cleared leaves its points in both
+	if err := os.WriteFile(filepath.Join(dir, walFile), wal, 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err = Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if values := queryValues(t, db); len(values) != 2 {
+		t.Errorf("values after replay = %v, want [1 2]", values)
+	}
+}
+
+func TestCloseWhileQuerying(t *testing.T) {
+	db, err := Open(t.TempDir(), Options{Rollups: []Rollup{{Resolution: time.Minute}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Recent points, so the background rollup is quick to catch up
+	start := time.Now().Add(-5 * time.Minute)
+	for i := 0; i < 100; i++ {
+		appendValue(t, db, start.Add(time.Duration(i)*time.Second), float64(i))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				db.Resolutions()
+				db.Query(Matcher{}, start, start.Add(time.Hour))
+			}
+		}()
+	}
+	if err := db.Close(); err != nil {
+		t.Error(err)
+	}
+	wg.Wait()
+}
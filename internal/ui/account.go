@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/steam-os-monitor/monitor/internal/collector"
+	"github.com/steam-os-monitor/monitor/internal/steamapi"
+)
+
+// accountRefreshInterval is how often the Steam account data is refreshed.
+// The API client serves cached responses in between.
+const accountRefreshInterval = 15 * time.Minute
+
+// setupWebAPI starts refreshing the Steam account data if an API key is
+// configured
+func (w *Window) setupWebAPI() {
+	if w.config.Steam.APIKey == "" {
+		return
+	}
+
+	w.webAPI = steamapi.NewClient(w.config.Steam.APIKey, steamapi.DefaultCacheDir())
+	if w.config.Steam.APIURL != "" {
+		w.webAPI.BaseURL = w.config.Steam.APIURL
+	}
+	w.stopAccount = make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(accountRefreshInterval)
+		defer ticker.Stop()
+		for {
+			w.refreshAccount()
+			select {
+			case <-ticker.C:
+			case <-w.stopAccount:
+				return
+			}
+		}
+	}()
+}
+
+// refreshAccount fetches the owned and recently played games and profile
+// of the configured or most recently logged in user
+func (w *Window) refreshAccount() {
+	steamID := w.config.Steam.SteamID
+	if steamID == "" {
+		id, err := collector.SteamUserID()
+		if err != nil {
+			return
+		}
+		steamID = id
+	}
+	steamID, err := steamapi.SteamID64(steamID)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	owned, err := w.webAPI.GetOwnedGames(ctx, steamID)
+	if err == nil {
+		// Lets sessions of games that aren't installed here, e.g. streamed
+		// ones, carry a name
+		names := make(map[string]string, len(owned.Games))
+		for _, game := range owned.Games {
+			names[strconv.Itoa(game.AppID)] = game.Name
+		}
+		w.gameCollector.SetAppNames(names)
+	}
+	recent, _ := w.webAPI.GetRecentlyPlayedGames(ctx, steamID)
+
+	var player *steamapi.PlayerSummary
+	if summaries, err := w.webAPI.GetPlayerSummaries(ctx, steamID); err == nil && len(summaries.Players) > 0 {
+		player = &summaries.Players[0]
+	}
+
+	if w.config.Widgets.ShowSteam {
+		w.steamWidget.UpdateAccount(player, owned, recent)
+	}
+}
@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"time"
+
+	"github.com/steam-os-monitor/monitor/internal/tsdb"
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// Recent FPS shown in the game widget, read from the metric history
+const (
+	gameHistorySpan     = 15 * time.Minute
+	gameHistoryInterval = 15 * time.Second
+)
+
+// updateGameHistory shows the running game's FPS over the last minutes.
+// The store is only queried every few seconds.
+func (w *Window) updateGameHistory(stats *metrics.GamePerformanceStats) {
+	if w.store == nil {
+		return
+	}
+	now := time.Now()
+	if now.Sub(w.storeQueried) < gameHistoryInterval {
+		return
+	}
+	w.storeQueried = now
+
+	if stats.AppID == "" {
+		w.gameWidget.UpdateHistory(gameHistorySpan, 0, 0, 0)
+		return
+	}
+
+	series, err := w.store.Query(tsdb.Matcher{
+		Name:   "game_performance.fps",
+		Labels: tsdb.Labels{"app_id": stats.AppID},
+	}, now.Add(-gameHistorySpan), now)
+	if err != nil || len(series) == 0 {
+		return
+	}
+
+	var sum, low float64
+	var samples int
+	for _, p := range series[0].Points {
+		// Samples without a frame time source report no FPS
+		if p.Value <= 0 {
+			continue
+		}
+		if samples == 0 || p.Min < low {
+			low = p.Min
+		}
+		sum += p.Value
+		samples++
+	}
+	if samples == 0 {
+		w.gameWidget.UpdateHistory(gameHistorySpan, 0, 0, 0)
+		return
+	}
+	w.gameWidget.UpdateHistory(gameHistorySpan, sum/float64(samples), low, samples)
+}
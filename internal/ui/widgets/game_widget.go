@@ -2,6 +2,7 @@ package widgets
 
 import (
 	"fmt"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -14,29 +15,38 @@ import (
 // GameWidget displays game performance metrics
 type GameWidget struct {
 	widget.BaseWidget
-	stats     *metrics.GamePerformanceStats
-	theme     *theme.Theme
-	title     *canvas.Text
-	gameName  *canvas.Text
-	fpsText   *canvas.Text
-	frameTimeText *canvas.Text
-	container *fyne.Container
+	stats          *metrics.GamePerformanceStats
+	theme          *theme.Theme
+	title          *canvas.Text
+	gameName       *canvas.Text
+	fpsText        *canvas.Text
+	frameTimeText  *canvas.Text
+	percentileText *canvas.Text
+	lowsText       *canvas.Text
+	historyText    *canvas.Text
+	container      *fyne.Container
 }
 
 // NewGameWidget creates a new game widget
 func NewGameWidget(theme *theme.Theme) *GameWidget {
 	w := &GameWidget{
-		theme: theme,
-		title: canvas.NewText("Game Performance", theme.TextColor),
-		gameName: canvas.NewText("Game: None", theme.TextColor),
-		fpsText: canvas.NewText("FPS: 0", theme.TextColor),
-		frameTimeText: canvas.NewText("Frame Time: 0.00 ms", theme.TextColor),
+		theme:          theme,
+		title:          canvas.NewText("Game Performance", theme.TextColor),
+		gameName:       canvas.NewText("Game: None", theme.TextColor),
+		fpsText:        canvas.NewText("FPS: 0", theme.TextColor),
+		frameTimeText:  canvas.NewText("Frame Time: 0.00 ms", theme.TextColor),
+		percentileText: canvas.NewText("Median: 0.00 ms, P95: 0.00 ms, P99: 0.00 ms, StdDev: 0.00 ms", theme.TextColor),
+		lowsText:       canvas.NewText("1% Low: 0.0 FPS, 0.1% Low: 0.0 FPS", theme.TextColor),
+		historyText:    canvas.NewText("", theme.TextColor),
 	}
 	w.title.TextStyle = fyne.TextStyle{Bold: true}
 	w.title.TextSize = 16
 	w.gameName.TextSize = 14
 	w.fpsText.TextSize = 14
 	w.frameTimeText.TextSize = 12
+	w.percentileText.TextSize = 12
+	w.lowsText.TextSize = 12
+	w.historyText.TextSize = 12
 	w.ExtendBaseWidget(w)
 	return w
 }
@@ -48,6 +58,9 @@ func (w *GameWidget) CreateRenderer() fyne.WidgetRenderer {
 		w.gameName,
 		w.fpsText,
 		w.frameTimeText,
+		w.percentileText,
+		w.lowsText,
+		w.historyText,
 	)
 
 	return &gameWidgetRenderer{
@@ -64,7 +77,17 @@ func (w *GameWidget) Update(stats *metrics.GamePerformanceStats) {
 	}
 
 	if stats.GameName != "" {
-		w.gameName.Text = fmt.Sprintf("Game: %s", stats.GameName)
+		runtime := "Native"
+		if stats.CompatTool != "" {
+			runtime = stats.CompatTool
+			if stats.CompatToolVersion != "" && stats.CompatToolVersion != stats.CompatTool {
+				runtime += " " + stats.CompatToolVersion
+			}
+		} else if stats.Proton {
+			runtime = "Proton"
+		}
+		w.gameName.Text = fmt.Sprintf("Game: %s (App %s, PID %d, %s)",
+			stats.GameName, stats.AppID, stats.PID, runtime)
 	} else {
 		w.gameName.Text = "Game: None"
 	}
@@ -73,9 +96,34 @@ func (w *GameWidget) Update(stats *metrics.GamePerformanceStats) {
 	w.fpsText.Text = fmt.Sprintf("FPS: %.1f", stats.FPS)
 	w.fpsText.Refresh()
 
-	w.frameTimeText.Text = fmt.Sprintf("Frame Time: %.2f ms (Min: %.2f ms, Max: %.2f ms)",
-		stats.FrameTime, stats.FrameTimeMin, stats.FrameTimeMax)
+	w.frameTimeText.Text = fmt.Sprintf("Frame Time: %.2f ms (Min: %.2f ms, Max: %.2f ms, Mean: %.2f ms)",
+		stats.FrameTime, stats.FrameTimeMin, stats.FrameTimeMax, stats.FrameTimeMean)
 	w.frameTimeText.Refresh()
+
+	w.percentileText.Text = fmt.Sprintf("Median: %.2f ms, P95: %.2f ms, P99: %.2f ms, StdDev: %.2f ms",
+		stats.FrameTimeMedian, stats.FrameTimeP95, stats.FrameTimeP99, stats.FrameTimeStdDev)
+	w.percentileText.Refresh()
+
+	// Without MangoHud the lows are those of the FPS readings, not of frames
+	if stats.FrameTimeSource == metrics.FrameTimeSourceMangoHud {
+		w.lowsText.Text = fmt.Sprintf("1%% Low: %.1f FPS, 0.1%% Low: %.1f FPS (%d frames)",
+			stats.FPS1PercentLow, stats.FPS01PercentLow, stats.FrameSamples)
+	} else {
+		w.lowsText.Text = fmt.Sprintf("1%% Low: %.1f FPS, 0.1%% Low: %.1f FPS (of %d sampled FPS readings)",
+			stats.FPS1PercentLow, stats.FPS01PercentLow, stats.FrameSamples)
+	}
+	w.lowsText.Refresh()
+}
+
+// UpdateHistory shows the average and lowest FPS of the running game over
+// the given span. Without samples the line is cleared.
+func (w *GameWidget) UpdateHistory(span time.Duration, avg, low float64, samples int) {
+	if samples == 0 {
+		w.historyText.Text = ""
+	} else {
+		w.historyText.Text = fmt.Sprintf("Last %s: Avg %.1f FPS, Min %.1f FPS", span, avg, low)
+	}
+	w.historyText.Refresh()
 }
 
 type gameWidgetRenderer struct {
@@ -100,4 +148,3 @@ func (r *gameWidgetRenderer) Objects() []fyne.CanvasObject {
 }
 
 func (r *gameWidgetRenderer) Destroy() {}
-
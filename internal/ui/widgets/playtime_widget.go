@@ -0,0 +1,120 @@
+package widgets
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/steam-os-monitor/monitor/internal/session"
+	"github.com/steam-os-monitor/monitor/internal/theme"
+)
+
+// maxPlaytimeRows limits how many games the playtime view lists
+const maxPlaytimeRows = 15
+
+// PlaytimeWidget displays daily and weekly playtime per game
+type PlaytimeWidget struct {
+	widget.BaseWidget
+	theme     *theme.Theme
+	title     *canvas.Text
+	totalText *canvas.Text
+	gamesBox  *fyne.Container
+	container *fyne.Container
+}
+
+// NewPlaytimeWidget creates a new playtime widget
+func NewPlaytimeWidget(theme *theme.Theme) *PlaytimeWidget {
+	w := &PlaytimeWidget{
+		theme:     theme,
+		title:     canvas.NewText("Playtime", theme.TextColor),
+		totalText: canvas.NewText("Today: 0.0 h, This Week: 0.0 h", theme.TextColor),
+		gamesBox:  container.NewVBox(),
+	}
+	w.title.TextStyle = fyne.TextStyle{Bold: true}
+	w.title.TextSize = 16
+	w.totalText.TextSize = 14
+	w.ExtendBaseWidget(w)
+	return w
+}
+
+// CreateRenderer creates the renderer for the widget
+func (w *PlaytimeWidget) CreateRenderer() fyne.WidgetRenderer {
+	w.container = container.NewVBox(
+		w.title,
+		w.totalText,
+		w.gamesBox,
+	)
+
+	return &playtimeWidgetRenderer{
+		widget:    w,
+		container: w.container,
+	}
+}
+
+// Update updates the widget with per-game playtime, most recently played first
+func (w *PlaytimeWidget) Update(games []session.GamePlaytime) {
+	var today, week time.Duration
+	for i := range games {
+		today += games[i].Today()
+		week += games[i].ThisWeek()
+	}
+	w.totalText.Text = fmt.Sprintf("Today: %.1f h, This Week: %.1f h", today.Hours(), week.Hours())
+	w.totalText.Refresh()
+
+	now := time.Now()
+	w.gamesBox.RemoveAll()
+	for i := range games {
+		if i == maxPlaytimeRows {
+			break
+		}
+		g := &games[i]
+
+		summary := fmt.Sprintf("  %s: today %.1f h, this week %.1f h", g.Name, g.Today().Hours(), g.ThisWeek().Hours())
+		if g.SteamTotal > 0 {
+			summary += fmt.Sprintf(", Steam total %.1f h", g.SteamTotal.Hours())
+		}
+		if !g.LastPlayed.IsZero() {
+			summary += fmt.Sprintf(", last played %s", g.LastPlayed.Format("2006-01-02"))
+		}
+		line := canvas.NewText(summary, w.theme.TextColor)
+		line.TextSize = 12
+		w.gamesBox.Add(line)
+
+		days := make([]string, len(g.Daily))
+		for d, played := range g.Daily {
+			day := now.AddDate(0, 0, d-len(g.Daily)+1).Weekday().String()[:3]
+			days[d] = fmt.Sprintf("%s %.1f", day, played.Hours())
+		}
+		daily := canvas.NewText("    "+strings.Join(days, "  "), w.theme.TextColor)
+		daily.TextSize = 11
+		w.gamesBox.Add(daily)
+	}
+	w.gamesBox.Refresh()
+}
+
+type playtimeWidgetRenderer struct {
+	widget    *PlaytimeWidget
+	container *fyne.Container
+}
+
+func (r *playtimeWidgetRenderer) Layout(size fyne.Size) {
+	r.container.Resize(size)
+}
+
+func (r *playtimeWidgetRenderer) MinSize() fyne.Size {
+	return r.container.MinSize()
+}
+
+func (r *playtimeWidgetRenderer) Refresh() {
+	r.container.Refresh()
+}
+
+func (r *playtimeWidgetRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.container}
+}
+
+func (r *playtimeWidgetRenderer) Destroy() {}
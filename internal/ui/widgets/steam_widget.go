@@ -2,11 +2,14 @@ package widgets
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/widget"
+	"github.com/steam-os-monitor/monitor/internal/steamapi"
 	"github.com/steam-os-monitor/monitor/internal/theme"
 	"github.com/steam-os-monitor/monitor/pkg/metrics"
 )
@@ -14,25 +17,38 @@ import (
 // SteamWidget displays Steam-specific metrics
 type SteamWidget struct {
 	widget.BaseWidget
-	stats     *metrics.SteamStats
-	theme     *theme.Theme
-	title     *canvas.Text
-	downloadText *canvas.Text
-	uploadText   *canvas.Text
-	libraryText  *canvas.Text
+	stats         *metrics.SteamStats
+	inventory     *metrics.SteamInventory
+	theme         *theme.Theme
+	title         *canvas.Text
+	downloadText  *canvas.Text
+	uploadText    *canvas.Text
+	libraryText   *canvas.Text
 	downloadsText *canvas.Text
-	container *fyne.Container
+	progressBox   *fyne.Container
+	librariesBox  *fyne.Container
+	shortcutsText *canvas.Text
+	shortcutsBox  *fyne.Container
+	accountText   *canvas.Text
+	recentBox     *fyne.Container
+	container     *fyne.Container
 }
 
 // NewSteamWidget creates a new Steam widget
 func NewSteamWidget(theme *theme.Theme) *SteamWidget {
 	w := &SteamWidget{
-		theme: theme,
-		title: canvas.NewText("Steam", theme.TextColor),
-		downloadText: canvas.NewText("Download: 0 MB/s", theme.TextColor),
-		uploadText: canvas.NewText("Upload: 0 MB/s", theme.TextColor),
-		libraryText: canvas.NewText("Library: 0 games, 0 GB", theme.TextColor),
+		theme:         theme,
+		title:         canvas.NewText("Steam", theme.TextColor),
+		downloadText:  canvas.NewText("Download: 0 MB/s", theme.TextColor),
+		uploadText:    canvas.NewText("Upload: 0 MB/s", theme.TextColor),
+		libraryText:   canvas.NewText("Library: 0 games, 0 GB", theme.TextColor),
 		downloadsText: canvas.NewText("Active Downloads: 0", theme.TextColor),
+		progressBox:   container.NewVBox(),
+		librariesBox:  container.NewVBox(),
+		shortcutsText: canvas.NewText("Non-Steam Games: 0", theme.TextColor),
+		shortcutsBox:  container.NewVBox(),
+		accountText:   canvas.NewText("", theme.TextColor),
+		recentBox:     container.NewVBox(),
 	}
 	w.title.TextStyle = fyne.TextStyle{Bold: true}
 	w.title.TextSize = 16
@@ -40,6 +56,8 @@ func NewSteamWidget(theme *theme.Theme) *SteamWidget {
 	w.uploadText.TextSize = 14
 	w.libraryText.TextSize = 12
 	w.downloadsText.TextSize = 12
+	w.shortcutsText.TextSize = 12
+	w.accountText.TextSize = 12
 	w.ExtendBaseWidget(w)
 	return w
 }
@@ -51,7 +69,13 @@ func (w *SteamWidget) CreateRenderer() fyne.WidgetRenderer {
 		w.downloadText,
 		w.uploadText,
 		w.libraryText,
+		w.librariesBox,
+		w.shortcutsText,
+		w.shortcutsBox,
 		w.downloadsText,
+		w.progressBox,
+		w.accountText,
+		w.recentBox,
 	)
 
 	return &steamWidgetRenderer{
@@ -60,18 +84,33 @@ func (w *SteamWidget) CreateRenderer() fyne.WidgetRenderer {
 	}
 }
 
-// Update updates the widget with new Steam stats
-func (w *SteamWidget) Update(stats *metrics.SteamStats) {
+// Update updates the widget with new Steam stats and the inventory
+// collected with them
+func (w *SteamWidget) Update(stats *metrics.SteamStats, inventory *metrics.SteamInventory) {
 	w.stats = stats
-	if stats == nil {
+	w.inventory = inventory
+	if stats == nil || inventory == nil {
 		return
 	}
 
 	downloadMB := stats.DownloadSpeed / (1024 * 1024)
+	diskWriteMB := stats.DiskWriteSpeed / (1024 * 1024)
 	uploadMB := stats.UploadSpeed / (1024 * 1024)
 	libraryGB := float64(stats.LibrarySize) / (1024 * 1024 * 1024)
 
-	w.downloadText.Text = fmt.Sprintf("Download: %.2f MB/s", downloadMB)
+	// Look up app names from the manifests
+	names := make(map[string]string, len(inventory.Apps))
+	for _, app := range inventory.Apps {
+		names[app.AppID] = app.Name
+	}
+	appName := func(appID string) string {
+		if name := names[appID]; name != "" {
+			return name
+		}
+		return "App " + appID
+	}
+
+	w.downloadText.Text = fmt.Sprintf("Download: %.2f MB/s (Disk Write: %.2f MB/s)", downloadMB, diskWriteMB)
 	w.downloadText.Refresh()
 
 	w.uploadText.Text = fmt.Sprintf("Upload: %.2f MB/s", uploadMB)
@@ -80,13 +119,91 @@ func (w *SteamWidget) Update(stats *metrics.SteamStats) {
 	w.libraryText.Text = fmt.Sprintf("Library: %d games, %.2f GB", stats.InstalledGames, libraryGB)
 	w.libraryText.Refresh()
 
-	w.downloadsText.Text = fmt.Sprintf("Active Downloads: %d", stats.ActiveDownloads)
+	// Show a breakdown per library folder
+	w.librariesBox.RemoveAll()
+	for _, library := range stats.Libraries {
+		device := library.Device
+		if device == "" {
+			device = "unknown device"
+		}
+		line := canvas.NewText(fmt.Sprintf("  %s (%s): %d games, %.2f GB, %.2f GB free",
+			library.Path, device, library.GameCount,
+			float64(library.Size)/(1024*1024*1024),
+			float64(library.Free)/(1024*1024*1024)), w.theme.TextColor)
+		line.TextSize = 12
+		w.librariesBox.Add(line)
+	}
+	w.librariesBox.Refresh()
+
+	// List non-Steam games with what they launch
+	w.shortcutsText.Text = fmt.Sprintf("Non-Steam Games: %d", len(inventory.Shortcuts))
+	w.shortcutsText.Refresh()
+	w.shortcutsBox.RemoveAll()
+	for _, shortcut := range inventory.Shortcuts {
+		line := canvas.NewText(fmt.Sprintf("  %s (App %s): %s", shortcut.Name, shortcut.AppID, shortcut.Exe), w.theme.TextColor)
+		line.TextSize = 12
+		w.shortcutsBox.Add(line)
+	}
+	w.shortcutsBox.Refresh()
+
+	downloadsText := fmt.Sprintf("Active Downloads: %d", stats.ActiveDownloads)
+	if stats.UpdatingAppID != "" {
+		downloadsText += fmt.Sprintf(" (updating %s)", appName(stats.UpdatingAppID))
+	}
+	if len(stats.UpdateQueue) > 0 {
+		queued := make([]string, len(stats.UpdateQueue))
+		for i, appID := range stats.UpdateQueue {
+			queued[i] = appName(appID)
+		}
+		downloadsText += fmt.Sprintf(", Queued: %s", strings.Join(queued, ", "))
+	}
+	w.downloadsText.Text = downloadsText
 	w.downloadsText.Refresh()
 
-	// Add download progress if available
-	if len(stats.DownloadProgress) > 0 {
-		// Could add progress bars here for each download
+	// Show download progress per app
+	appIDs := make([]string, 0, len(stats.DownloadProgress))
+	for appID := range stats.DownloadProgress {
+		appIDs = append(appIDs, appID)
 	}
+	sort.Strings(appIDs)
+
+	w.progressBox.RemoveAll()
+	for _, appID := range appIDs {
+		line := canvas.NewText(fmt.Sprintf("  %s: %.1f%%", appName(appID), stats.DownloadProgress[appID]), w.theme.TextColor)
+		line.TextSize = 12
+		w.progressBox.Add(line)
+	}
+	w.progressBox.Refresh()
+}
+
+// UpdateAccount shows the user's profile, library size and recently played
+// games from the Steam Web API. Any of them may be nil when unavailable.
+func (w *SteamWidget) UpdateAccount(player *steamapi.PlayerSummary, owned *steamapi.OwnedGames, recent *steamapi.RecentlyPlayedGames) {
+	account := "Account"
+	if player != nil {
+		account = fmt.Sprintf("Account: %s", player.PersonaName)
+	}
+	if owned != nil {
+		account += fmt.Sprintf(", %d games owned", owned.GameCount)
+		if owned.Stale {
+			account += fmt.Sprintf(" (offline, as of %s)", owned.FetchedAt.Format("2006-01-02 15:04"))
+		}
+	} else if player == nil {
+		account += ": unavailable"
+	}
+	w.accountText.Text = account
+	w.accountText.Refresh()
+
+	w.recentBox.RemoveAll()
+	if recent != nil {
+		for _, game := range recent.Games {
+			line := canvas.NewText(fmt.Sprintf("  %s: %.1f h in the last 2 weeks, %.1f h total",
+				game.Name, float64(game.Playtime2Weeks)/60, float64(game.PlaytimeForever)/60), w.theme.TextColor)
+			line.TextSize = 12
+			w.recentBox.Add(line)
+		}
+	}
+	w.recentBox.Refresh()
 }
 
 type steamWidgetRenderer struct {
@@ -111,4 +228,3 @@ func (r *steamWidgetRenderer) Objects() []fyne.CanvasObject {
 }
 
 func (r *steamWidgetRenderer) Destroy() {}
-
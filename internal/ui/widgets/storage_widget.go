@@ -0,0 +1,156 @@
+package widgets
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/steam-os-monitor/monitor/internal/theme"
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
+)
+
+// maxStorageRows limits how many games the storage view lists
+const maxStorageRows = 20
+
+// StorageWidget displays per-game disk usage sorted by total footprint
+type StorageWidget struct {
+	widget.BaseWidget
+	stats       *metrics.SteamStats
+	inventory   *metrics.SteamInventory
+	theme       *theme.Theme
+	title       *canvas.Text
+	totalText   *canvas.Text
+	gamesBox    *fyne.Container
+	orphansText *canvas.Text
+	orphansBox  *fyne.Container
+	container   *fyne.Container
+}
+
+// NewStorageWidget creates a new storage widget
+func NewStorageWidget(theme *theme.Theme) *StorageWidget {
+	w := &StorageWidget{
+		theme:       theme,
+		title:       canvas.NewText("Storage", theme.TextColor),
+		totalText:   canvas.NewText("Total: 0 GB", theme.TextColor),
+		gamesBox:    container.NewVBox(),
+		orphansText: canvas.NewText("Leftovers: none", theme.TextColor),
+		orphansBox:  container.NewVBox(),
+	}
+	w.title.TextStyle = fyne.TextStyle{Bold: true}
+	w.title.TextSize = 16
+	w.totalText.TextSize = 14
+	w.orphansText.TextSize = 14
+	w.ExtendBaseWidget(w)
+	return w
+}
+
+// CreateRenderer creates the renderer for the widget
+func (w *StorageWidget) CreateRenderer() fyne.WidgetRenderer {
+	w.container = container.NewVBox(
+		w.title,
+		w.totalText,
+		w.gamesBox,
+		w.orphansText,
+		w.orphansBox,
+	)
+
+	return &storageWidgetRenderer{
+		widget:    w,
+		container: w.container,
+	}
+}
+
+// Update updates the widget with the storage breakdown of new Steam stats
+// and the inventory collected with them
+func (w *StorageWidget) Update(stats *metrics.SteamStats, inventory *metrics.SteamInventory) {
+	w.stats = stats
+	w.inventory = inventory
+	if stats == nil || inventory == nil {
+		return
+	}
+
+	var install, shaders, prefixes, workshop uint64
+	for _, app := range inventory.Storage {
+		install += app.InstallBytes
+		shaders += app.ShaderCacheBytes
+		prefixes += app.CompatDataBytes
+		workshop += app.WorkshopBytes
+	}
+	w.totalText.Text = fmt.Sprintf("Total: %.2f GB (Install: %.2f GB, Shader Cache: %.2f GB, Proton Prefixes: %.2f GB, Workshop: %.2f GB)",
+		toGB(install+shaders+prefixes+workshop), toGB(install), toGB(shaders), toGB(prefixes), toGB(workshop))
+	w.totalText.Refresh()
+
+	w.gamesBox.RemoveAll()
+	for i, app := range inventory.Storage {
+		if i == maxStorageRows {
+			break
+		}
+		name := app.Name
+		if name == "" {
+			name = "App " + app.AppID
+		}
+		line := canvas.NewText(fmt.Sprintf("  %s: %.2f GB (Install: %.2f GB, Shaders: %.2f GB, Prefix: %.2f GB, Workshop: %.2f GB)",
+			name, toGB(app.TotalBytes), toGB(app.InstallBytes), toGB(app.ShaderCacheBytes),
+			toGB(app.CompatDataBytes), toGB(app.WorkshopBytes)), w.theme.TextColor)
+		line.TextSize = 12
+		w.gamesBox.Add(line)
+	}
+	w.gamesBox.Refresh()
+
+	w.updateOrphans(stats, inventory)
+}
+
+// updateOrphans lists prefixes and shader caches left behind by
+// uninstalled apps
+func (w *StorageWidget) updateOrphans(stats *metrics.SteamStats, inventory *metrics.SteamInventory) {
+	if len(inventory.Unreachable) > 0 {
+		w.orphansText.Text = fmt.Sprintf("Leftovers: not checked, %d libraries unreachable", len(inventory.Unreachable))
+	} else if len(inventory.Orphaned) == 0 {
+		w.orphansText.Text = "Leftovers: none"
+	} else {
+		w.orphansText.Text = fmt.Sprintf("Leftovers of uninstalled apps: %.2f GB in %d directories",
+			toGB(stats.OrphanedBytes), len(inventory.Orphaned))
+	}
+	w.orphansText.Refresh()
+
+	w.orphansBox.RemoveAll()
+	for i, orphan := range inventory.Orphaned {
+		if i == maxStorageRows {
+			break
+		}
+		line := canvas.NewText(fmt.Sprintf("  %s: %.2f GB", orphan.Path, toGB(orphan.Bytes)), w.theme.TextColor)
+		line.TextSize = 12
+		w.orphansBox.Add(line)
+	}
+	w.orphansBox.Refresh()
+}
+
+// toGB converts bytes to gigabytes
+func toGB(bytes uint64) float64 {
+	return float64(bytes) / (1024 * 1024 * 1024)
+}
+
+type storageWidgetRenderer struct {
+	widget    *StorageWidget
+	container *fyne.Container
+}
+
+func (r *storageWidgetRenderer) Layout(size fyne.Size) {
+	r.container.Resize(size)
+}
+
+func (r *storageWidgetRenderer) MinSize() fyne.Size {
+	return r.container.MinSize()
+}
+
+func (r *storageWidgetRenderer) Refresh() {
+	r.container.Refresh()
+}
+
+func (r *storageWidgetRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.container}
+}
+
+func (r *storageWidgetRenderer) Destroy() {}
@@ -1,6 +1,8 @@
 package ui
 
 import (
+	"fmt"
+	"path/filepath"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -9,18 +11,26 @@ import (
 	"github.com/steam-os-monitor/monitor/internal/collector"
 	"github.com/steam-os-monitor/monitor/internal/config"
 	"github.com/steam-os-monitor/monitor/internal/logger"
+	"github.com/steam-os-monitor/monitor/internal/session"
+	"github.com/steam-os-monitor/monitor/internal/steamapi"
 	"github.com/steam-os-monitor/monitor/internal/theme"
+	"github.com/steam-os-monitor/monitor/internal/tsdb"
 	"github.com/steam-os-monitor/monitor/internal/ui/widgets"
+	"github.com/steam-os-monitor/monitor/pkg/metrics"
 )
 
+// inventoryLogInterval is how often the Steam inventory is logged when it
+// doesn't change, so every log holds a recent copy
+const inventoryLogInterval = time.Hour
+
 // Window represents the main application window
 type Window struct {
-	app        fyne.App
-	window     fyne.Window
-	config     *config.Config
-	logger     *logger.Logger
-	theme      *theme.Theme
-	
+	app    fyne.App
+	window fyne.Window
+	config *config.Config
+	logger *logger.Logger
+	theme  *theme.Theme
+
 	// Collectors
 	cpuCollector     *collector.CPUCollector
 	memoryCollector  *collector.MemoryCollector
@@ -28,33 +38,53 @@ type Window struct {
 	networkCollector *collector.NetworkCollector
 	gameCollector    *collector.GameCollector
 	steamCollector   *collector.SteamCollector
-	
+	sensorCollector  *collector.SensorCollector
+
+	// When the Steam inventory was last logged
+	inventoryLogged time.Time
+
+	// Session recording
+	sessions *session.Recorder
+	history  []metrics.SessionSummary // sessions recorded before this run
+
+	// Metric history, nil if disabled
+	store        *tsdb.DB
+	storeQueried time.Time
+
+	// Steam Web API, nil without an API key
+	webAPI      *steamapi.Client
+	stopAccount chan struct{}
+
 	// Widgets
-	cpuWidget     *widgets.CPUWidget
-	memoryWidget *widgets.MemoryWidget
-	diskWidget   *widgets.DiskWidget
-	networkWidget *widgets.NetworkWidget
-	gameWidget   *widgets.GameWidget
-	steamWidget  *widgets.SteamWidget
-	
+	cpuWidget      *widgets.CPUWidget
+	memoryWidget   *widgets.MemoryWidget
+	diskWidget     *widgets.DiskWidget
+	networkWidget  *widgets.NetworkWidget
+	gameWidget     *widgets.GameWidget
+	steamWidget    *widgets.SteamWidget
+	storageWidget  *widgets.StorageWidget
+	playtimeWidget *widgets.PlaytimeWidget
+
 	// Container
 	content *container.Scroll
-	
+
 	// Update ticker
 	ticker *time.Ticker
 }
 
-// NewWindow creates a new application window
-func NewWindow(cfg *config.Config, log *logger.Logger) (*Window, error) {
+// NewWindow creates a new application window. The metric history store is
+// optional.
+func NewWindow(cfg *config.Config, log *logger.Logger, store *tsdb.DB) (*Window, error) {
 	application := app.NewWithID("steam-os-monitor")
-	
+
 	w := &Window{
 		app:    application,
 		config: cfg,
 		logger: log,
 		theme:  theme.DefaultTheme(),
+		store:  store,
 	}
-	
+
 	// Initialize collectors
 	w.cpuCollector = collector.NewCPUCollector()
 	w.memoryCollector = collector.NewMemoryCollector()
@@ -62,15 +92,24 @@ func NewWindow(cfg *config.Config, log *logger.Logger) (*Window, error) {
 	w.networkCollector = collector.NewNetworkCollector()
 	w.gameCollector = collector.NewGameCollector()
 	w.steamCollector = collector.NewSteamCollector()
-	
+	w.sensorCollector = collector.NewSensorCollector()
+	historyPath := filepath.Join(cfg.LogDir, session.HistoryFile)
+	w.sessions = session.NewRecorder(log, historyPath)
+	history, err := session.OpenHistory(historyPath, filepath.Join(cfg.LogDir, "sessions.log"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session history: %w", err)
+	}
+	w.history = history
+
 	// Apply theme
 	theme.ApplyTheme(application, w.theme)
-	
+
 	// Create window
 	w.window = application.NewWindow("SteamOS System Monitor")
 	w.window.Resize(fyne.NewSize(1200, 800))
 	w.window.CenterOnScreen()
-	
+	w.window.SetOnClosed(w.stop)
+
 	// Create widgets
 	w.cpuWidget = widgets.NewCPUWidget(w.theme)
 	w.memoryWidget = widgets.NewMemoryWidget(w.theme)
@@ -78,20 +117,23 @@ func NewWindow(cfg *config.Config, log *logger.Logger) (*Window, error) {
 	w.networkWidget = widgets.NewNetworkWidget(w.theme)
 	w.gameWidget = widgets.NewGameWidget(w.theme)
 	w.steamWidget = widgets.NewSteamWidget(w.theme)
-	
+	w.storageWidget = widgets.NewStorageWidget(w.theme)
+	w.playtimeWidget = widgets.NewPlaytimeWidget(w.theme)
+
 	// Create layout
 	w.setupLayout()
-	
+
 	// Setup update loop
 	w.setupUpdateLoop()
-	
+	w.setupWebAPI()
+
 	return w, nil
 }
 
 // setupLayout creates the window layout
 func (w *Window) setupLayout() {
 	var widgetContainers []fyne.CanvasObject
-	
+
 	if w.config.Widgets.ShowCPU {
 		widgetContainers = append(widgetContainers, w.cpuWidget)
 	}
@@ -110,7 +152,13 @@ func (w *Window) setupLayout() {
 	if w.config.Widgets.ShowSteam {
 		widgetContainers = append(widgetContainers, w.steamWidget)
 	}
-	
+	if w.config.Widgets.ShowStorage {
+		widgetContainers = append(widgetContainers, w.storageWidget)
+	}
+	if w.config.Widgets.ShowPlaytime {
+		widgetContainers = append(widgetContainers, w.playtimeWidget)
+	}
+
 	// Create scrollable container with grid layout
 	content := container.NewVBox(widgetContainers...)
 	w.content = container.NewScroll(content)
@@ -121,19 +169,32 @@ func (w *Window) setupLayout() {
 func (w *Window) setupUpdateLoop() {
 	refreshDuration := time.Duration(w.config.RefreshRate) * time.Millisecond
 	w.ticker = time.NewTicker(refreshDuration)
-	
+
 	go func() {
 		for range w.ticker.C {
 			w.updateMetrics()
 		}
 	}()
-	
+
 	// Initial update
 	w.updateMetrics()
 }
 
 // updateMetrics collects and updates all metrics
 func (w *Window) updateMetrics() {
+	w.logger.NextTick()
+
+	// Game and sensor metrics are always collected since session recording
+	// depends on them. They go first so the session is known before logging.
+	gameStats, gameErr := w.gameCollector.Collect()
+	sensorStats, sensorErr := w.sensorCollector.Collect()
+	if gameErr == nil {
+		w.sessions.Observe(gameStats, w.gameCollector.FrameTimes(), sensorStats)
+	}
+	if sensorErr == nil {
+		w.logger.LogSensors(sensorStats)
+	}
+
 	// Collect CPU metrics
 	if w.config.Widgets.ShowCPU {
 		cpuStats, err := w.cpuCollector.Collect()
@@ -142,7 +203,7 @@ func (w *Window) updateMetrics() {
 			w.logger.LogCPU(cpuStats)
 		}
 	}
-	
+
 	// Collect Memory metrics
 	if w.config.Widgets.ShowMemory {
 		memStats, err := w.memoryCollector.Collect()
@@ -151,7 +212,7 @@ func (w *Window) updateMetrics() {
 			w.logger.LogMemory(memStats)
 		}
 	}
-	
+
 	// Collect Disk metrics
 	if w.config.Widgets.ShowDisk {
 		diskStats, err := w.diskCollector.Collect()
@@ -162,7 +223,7 @@ func (w *Window) updateMetrics() {
 			}
 		}
 	}
-	
+
 	// Collect Network metrics
 	if w.config.Widgets.ShowNetwork {
 		netStats, err := w.networkCollector.Collect()
@@ -173,39 +234,81 @@ func (w *Window) updateMetrics() {
 			}
 		}
 	}
-	
-	// Collect Game metrics
-	if w.config.Widgets.ShowGame {
-		gameStats, err := w.gameCollector.Collect()
-		if err == nil {
+
+	// Update Game metrics
+	if gameErr == nil {
+		if w.config.Widgets.ShowGame {
 			w.gameWidget.Update(gameStats)
-			w.logger.LogGamePerformance(gameStats)
+			w.updateGameHistory(gameStats)
 		}
+		w.logger.LogGamePerformance(gameStats)
 	}
-	
-	// Collect Steam metrics
-	if w.config.Widgets.ShowSteam {
+
+	// Collect Steam metrics, along with the inventory that carries the
+	// storage breakdown and Steam's playtime records
+	if w.config.Widgets.ShowSteam || w.config.Widgets.ShowStorage || w.config.Widgets.ShowPlaytime {
 		steamStats, err := w.steamCollector.Collect()
 		if err == nil {
-			w.steamWidget.Update(steamStats)
+			inventory, changed := w.steamCollector.Inventory()
+			if w.config.Widgets.ShowSteam {
+				w.steamWidget.Update(steamStats, inventory)
+			}
+			if w.config.Widgets.ShowStorage {
+				w.storageWidget.Update(steamStats, inventory)
+			}
+			if w.config.Widgets.ShowPlaytime {
+				w.updatePlaytime(inventory)
+			}
 			w.logger.LogSteam(steamStats)
+			if changed || time.Since(w.inventoryLogged) >= inventoryLogInterval {
+				w.logger.LogSteamInventory(inventory)
+				w.inventoryLogged = time.Now()
+			}
 		}
 	}
 }
 
+// updatePlaytime combines past and current sessions with Steam's playtime
+// records for the playtime view
+func (w *Window) updatePlaytime(inventory *metrics.SteamInventory) {
+	now := time.Now()
+	sessions := append(append([]metrics.SessionSummary(nil), w.history...), w.sessions.Sessions(now)...)
+
+	names := make(map[string]string)
+	for _, app := range inventory.Apps {
+		names[app.AppID] = app.Name
+	}
+	for _, shortcut := range inventory.Shortcuts {
+		names[shortcut.AppID] = shortcut.Name
+	}
+
+	w.playtimeWidget.Update(session.SummarizePlaytime(sessions, inventory.Playtime, names, now))
+}
+
 // ShowAndRun shows the window and runs the application
 func (w *Window) ShowAndRun() {
 	w.window.ShowAndRun()
 }
 
-// Close closes the window and cleans up resources
-func (w *Window) Close() {
+// stop halts metric updates and ends the running session
+func (w *Window) stop() {
 	if w.ticker != nil {
 		w.ticker.Stop()
 	}
+	if w.sessions != nil {
+		w.sessions.Close()
+	}
+	if w.stopAccount != nil {
+		close(w.stopAccount)
+		w.stopAccount = nil
+	}
+}
+
+// Close closes the window and cleans up resources
+func (w *Window) Close() {
+	w.stop()
 	if w.logger != nil {
 		w.logger.Close()
 	}
 	w.window.Close()
 }
-
@@ -0,0 +1,144 @@
+package vdf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+// binary VDF node types
+const (
+	binaryMap     = 0x00
+	binaryString  = 0x01
+	binaryInt32   = 0x02
+	binaryFloat32 = 0x03
+	binaryPointer = 0x04
+	binaryWString = 0x05
+	binaryColor   = 0x06
+	binaryUint64  = 0x07
+	binaryEnd     = 0x08
+	binaryInt64   = 0x0A
+)
+
+// ParseBinaryFile parses a binary VDF file such as userdata/<id>/config/shortcuts.vdf
+func ParseBinaryFile(path string) (*KeyValues, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	root, err := ParseBinary(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return root, nil
+}
+
+// ParseBinary parses a binary VDF document. Numbers are converted to their
+// decimal string form so both formats share the KeyValues accessors.
+func ParseBinary(r io.Reader) (*KeyValues, error) {
+	p := &binaryParser{reader: bufio.NewReader(r)}
+	root := &KeyValues{}
+	if err := p.parseChildren(root, false); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+type binaryParser struct {
+	reader *bufio.Reader
+	offset int64
+}
+
+// parseChildren reads nodes into parent until an end marker, or until end
+// of input for the document root
+func (p *binaryParser) parseChildren(parent *KeyValues, nested bool) error {
+	for {
+		kind, err := p.reader.ReadByte()
+		if err == io.EOF && !nested {
+			return nil
+		}
+		if err != nil {
+			return p.errorf("unexpected end of input")
+		}
+		p.offset++
+
+		if kind == binaryEnd {
+			return nil
+		}
+
+		key, err := p.readString()
+		if err != nil {
+			return err
+		}
+		node := &KeyValues{Key: key}
+
+		switch kind {
+		case binaryMap:
+			if err := p.parseChildren(node, true); err != nil {
+				return err
+			}
+		case binaryString:
+			if node.Value, err = p.readString(); err != nil {
+				return err
+			}
+		case binaryInt32, binaryPointer, binaryColor:
+			var v int32
+			if err := p.read(&v); err != nil {
+				return err
+			}
+			node.Value = strconv.FormatInt(int64(v), 10)
+		case binaryFloat32:
+			var v uint32
+			if err := p.read(&v); err != nil {
+				return err
+			}
+			node.Value = strconv.FormatFloat(float64(math.Float32frombits(v)), 'g', -1, 32)
+		case binaryUint64:
+			var v uint64
+			if err := p.read(&v); err != nil {
+				return err
+			}
+			node.Value = strconv.FormatUint(v, 10)
+		case binaryInt64:
+			var v int64
+			if err := p.read(&v); err != nil {
+				return err
+			}
+			node.Value = strconv.FormatInt(v, 10)
+		default:
+			// Wide strings and unknown types have no size to skip by
+			return p.errorf("unsupported node type 0x%02x for key %q", kind, key)
+		}
+
+		parent.Children = append(parent.Children, node)
+	}
+}
+
+// readString reads a null-terminated string
+func (p *binaryParser) readString() (string, error) {
+	s, err := p.reader.ReadString(0)
+	if err != nil {
+		return "", p.errorf("unterminated string")
+	}
+	p.offset += int64(len(s))
+	return s[:len(s)-1], nil
+}
+
+// read reads a little-endian fixed-size value
+func (p *binaryParser) read(v interface{}) error {
+	if err := binary.Read(p.reader, binary.LittleEndian, v); err != nil {
+		return p.errorf("unexpected end of input")
+	}
+	p.offset += int64(binary.Size(v))
+	return nil
+}
+
+func (p *binaryParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("offset %d: %s", p.offset, fmt.Sprintf(format, args...))
+}
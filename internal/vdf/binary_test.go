@@ -0,0 +1,141 @@
+package vdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+// binaryDoc builds a binary VDF document
+type binaryDoc struct {
+	bytes.Buffer
+}
+
+func (d *binaryDoc) node(kind byte, key string) {
+	d.WriteByte(kind)
+	d.WriteString(key)
+	d.WriteByte(0)
+}
+
+func (d *binaryDoc) open(key string) { d.node(binaryMap, key) }
+
+func (d *binaryDoc) end() { d.WriteByte(binaryEnd) }
+
+func (d *binaryDoc) str(key, value string) {
+	d.node(binaryString, key)
+	d.WriteString(value)
+	d.WriteByte(0)
+}
+
+func (d *binaryDoc) number(kind byte, key string, value interface{}) {
+	d.node(kind, key)
+	binary.Write(d, binary.LittleEndian, value)
+}
+
+// shortcutsDoc is laid out like userdata/<id>/config/shortcuts.vdf
+func shortcutsDoc() []byte {
+	var d binaryDoc
+	d.open("shortcuts")
+	d.open("0")
+	d.number(binaryInt32, "appid", int32(-1294967296))
+	d.str("AppName", "RetroArch")
+	d.str("Exe", `"/usr/bin/retroarch"`)
+	d.number(binaryInt32, "LastPlayTime", int32(1712345678))
+	d.open("tags")
+	d.str("0", "favorite")
+	d.end()
+	d.end()
+	d.open("1")
+	d.number(binaryInt32, "appid", int32(-1))
+	d.str("AppName", "Heroic")
+	d.number(binaryFloat32, "scale", math.Float32bits(1.5))
+	d.number(binaryUint64, "size", uint64(math.MaxUint64))
+	d.number(binaryInt64, "offset", int64(-42))
+	d.end()
+	d.end()
+	d.end()
+	return d.Bytes()
+}
+
+func TestParseBinaryNested(t *testing.T) {
+	root, err := ParseBinary(bytes.NewReader(shortcutsDoc()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path []string
+		want string
+	}{
+		{[]string{"shortcuts", "0", "AppName"}, "RetroArch"},
+		{[]string{"shortcuts", "0", "exe"}, `"/usr/bin/retroarch"`},
+		{[]string{"shortcuts", "0", "LastPlayTime"}, "1712345678"},
+		{[]string{"shortcuts", "0", "tags", "0"}, "favorite"},
+		{[]string{"shortcuts", "1", "AppName"}, "Heroic"},
+		{[]string{"shortcuts", "1", "scale"}, "1.5"},
+		{[]string{"shortcuts", "1", "size"}, "18446744073709551615"},
+		{[]string{"shortcuts", "1", "offset"}, "-42"},
+	}
+	for _, tt := range tests {
+		if got := root.GetString(tt.path...); got != tt.want {
+			t.Errorf("GetString(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	if n := len(root.Get("shortcuts").Children); n != 2 {
+		t.Errorf("%d shortcuts, want 2", n)
+	}
+}
+
+func TestParseBinaryNegativeInt32(t *testing.T) {
+	root, err := ParseBinary(bytes.NewReader(shortcutsDoc()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Shortcut app IDs have the top bit set, so they come out negative
+	tests := []struct {
+		entry string
+		want  int64
+	}{
+		{"0", -1294967296},
+		{"1", -1},
+	}
+	for _, tt := range tests {
+		if got := root.GetInt("shortcuts", tt.entry, "appid"); got != tt.want {
+			t.Errorf("GetInt(shortcuts/%s/appid) = %d, want %d", tt.entry, got, tt.want)
+		}
+		if got := root.GetUint("shortcuts", tt.entry, "appid"); got != 0 {
+			t.Errorf("GetUint(shortcuts/%s/appid) = %d, want 0 for a negative value", tt.entry, got)
+		}
+	}
+}
+
+func TestParseBinaryTruncated(t *testing.T) {
+	doc := shortcutsDoc()
+	// Every cut before the end of the shortcuts map is incomplete; only the
+	// root's own end marker may be missing
+	for n := 1; n < len(doc)-1; n++ {
+		if _, err := ParseBinary(bytes.NewReader(doc[:n])); err == nil {
+			t.Errorf("ParseBinary() of the first %d of %d bytes succeeded", n, len(doc))
+		}
+	}
+
+	root, err := ParseBinary(bytes.NewReader(nil))
+	if err != nil || len(root.Children) != 0 {
+		t.Errorf("ParseBinary() of empty input = %+v, %v, want an empty document", root, err)
+	}
+}
+
+func TestParseBinaryUnsupportedType(t *testing.T) {
+	var d binaryDoc
+	d.node(binaryWString, "name")
+	d.WriteString("n\x00a\x00\x00\x00")
+
+	_, err := ParseBinary(bytes.NewReader(d.Bytes()))
+	if err == nil || !strings.Contains(err.Error(), "unsupported node type 0x05") {
+		t.Errorf("ParseBinary() error = %v, want unsupported node type", err)
+	}
+}
@@ -0,0 +1,261 @@
+// Package vdf reads Valve's KeyValues (VDF) files in text and binary form
+package vdf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// KeyValues is a node of a VDF document. A node has either a string Value
+// or Children, never both. The root returned by the parsers has no key and
+// holds the document's top-level nodes as children.
+type KeyValues struct {
+	Key      string
+	Value    string
+	Children []*KeyValues
+}
+
+// Get returns the child with the given key, compared case-insensitively as
+// Steam does, or nil if there is none. Several keys descend into the tree.
+func (kv *KeyValues) Get(keys ...string) *KeyValues {
+	node := kv
+	for _, key := range keys {
+		if node == nil {
+			return nil
+		}
+		var next *KeyValues
+		for _, child := range node.Children {
+			if strings.EqualFold(child.Key, key) {
+				next = child
+				break
+			}
+		}
+		node = next
+	}
+	return node
+}
+
+// GetString returns the value at the given path, or "" if it doesn't exist
+func (kv *KeyValues) GetString(keys ...string) string {
+	node := kv.Get(keys...)
+	if node == nil {
+		return ""
+	}
+	return node.Value
+}
+
+// GetUint returns the value at the given path as an unsigned integer, or 0
+// if it doesn't exist or isn't a number
+func (kv *KeyValues) GetUint(keys ...string) uint64 {
+	value, err := strconv.ParseUint(kv.GetString(keys...), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// GetInt returns the value at the given path as a signed integer, or 0 if
+// it doesn't exist or isn't a number
+func (kv *KeyValues) GetInt(keys ...string) int64 {
+	value, err := strconv.ParseInt(kv.GetString(keys...), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// ParseFile parses a text VDF file such as an appmanifest_<id>.acf
+func ParseFile(path string) (*KeyValues, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	root, err := Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return root, nil
+}
+
+// Parse parses a text VDF document
+func Parse(r io.Reader) (*KeyValues, error) {
+	p := &textParser{reader: bufio.NewReader(r), line: 1}
+	root := &KeyValues{}
+	if err := p.parseChildren(root, false); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// token kinds produced by the text tokenizer
+const (
+	tokenString = iota
+	tokenOpen
+	tokenClose
+	tokenEOF
+)
+
+type textParser struct {
+	reader *bufio.Reader
+	line   int
+}
+
+// parseChildren reads key/value pairs into parent until the closing brace,
+// or until end of input for the document root
+func (p *textParser) parseChildren(parent *KeyValues, nested bool) error {
+	for {
+		kind, key, err := p.next()
+		if err != nil {
+			return err
+		}
+
+		switch kind {
+		case tokenEOF:
+			if nested {
+				return p.errorf("unexpected end of input, missing '}'")
+			}
+			return nil
+		case tokenClose:
+			if !nested {
+				return p.errorf("unexpected '}'")
+			}
+			return nil
+		case tokenOpen:
+			return p.errorf("unexpected '{', expected a key")
+		}
+
+		kind, value, err := p.next()
+		if err != nil {
+			return err
+		}
+
+		node := &KeyValues{Key: key}
+		switch kind {
+		case tokenString:
+			node.Value = value
+		case tokenOpen:
+			if err := p.parseChildren(node, true); err != nil {
+				return err
+			}
+		default:
+			return p.errorf("missing value for key %q", key)
+		}
+		parent.Children = append(parent.Children, node)
+	}
+}
+
+// next returns the next token, skipping whitespace, comments and
+// platform conditionals
+func (p *textParser) next() (int, string, error) {
+	for {
+		c, err := p.reader.ReadByte()
+		if err == io.EOF {
+			return tokenEOF, "", nil
+		}
+		if err != nil {
+			return 0, "", err
+		}
+
+		switch {
+		case c == '\n':
+			p.line++
+		case c == ' ' || c == '\t' || c == '\r':
+		case c == '{':
+			return tokenOpen, "", nil
+		case c == '}':
+			return tokenClose, "", nil
+		case c == '/':
+			if next, _ := p.reader.Peek(1); len(next) == 1 && next[0] == '/' {
+				p.skipLine()
+				continue
+			}
+			return tokenString, p.readUnquoted(c), nil
+		case c == '"':
+			value, err := p.readQuoted()
+			return tokenString, value, err
+		case c == '[':
+			// Platform conditionals like [$WIN32] are ignored
+			p.readUntil(']')
+		default:
+			return tokenString, p.readUnquoted(c), nil
+		}
+	}
+}
+
+// readQuoted reads a quoted string whose opening quote was consumed
+func (p *textParser) readQuoted() (string, error) {
+	var sb strings.Builder
+	for {
+		c, err := p.reader.ReadByte()
+		if err != nil {
+			return "", p.errorf("unterminated string")
+		}
+		switch c {
+		case '"':
+			return sb.String(), nil
+		case '\n':
+			p.line++
+			sb.WriteByte(c)
+		case '\\':
+			escaped, err := p.reader.ReadByte()
+			if err != nil {
+				return "", p.errorf("unterminated string")
+			}
+			switch escaped {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case '\\', '"':
+				sb.WriteByte(escaped)
+			default:
+				// Paths in Steam files are not always escaped
+				sb.WriteByte('\\')
+				sb.WriteByte(escaped)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+// readUnquoted reads a bare token starting with first
+func (p *textParser) readUnquoted(first byte) string {
+	var sb strings.Builder
+	sb.WriteByte(first)
+	for {
+		next, err := p.reader.Peek(1)
+		if err != nil || strings.IndexByte(" \t\r\n{}\"", next[0]) >= 0 {
+			return sb.String()
+		}
+		c, _ := p.reader.ReadByte()
+		sb.WriteByte(c)
+	}
+}
+
+func (p *textParser) readUntil(end byte) {
+	for {
+		c, err := p.reader.ReadByte()
+		if err != nil || c == end {
+			return
+		}
+		if c == '\n' {
+			p.line++
+		}
+	}
+}
+
+func (p *textParser) skipLine() {
+	p.readUntil('\n')
+	p.line++
+}
+
+func (p *textParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,81 @@
+package vdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseText(t *testing.T) {
+	root, err := Parse(strings.NewReader(`// Written by Steam
+"AppState"
+{
+	"appid"		"620"
+	"name"		"Portal 2"
+	"SizeOnDisk"		"12884901888"
+	"UserConfig"
+	{
+		"language"		"english"
+	}
+	"InstalledDepots" {
+		"621" { "manifest" "-7113862426442512384" "size" "11811160064" }
+	}
+	"LaunchOptions"		"PROTON_LOG=1 \"%command%\"\n"
+	"installdir"		"C:\Games\Portal 2"
+	unquoted	value
+	"Windows"	"1"	[$WIN32]
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path []string
+		want string
+	}{
+		{[]string{"AppState", "appid"}, "620"},
+		{[]string{"appstate", "NAME"}, "Portal 2"},
+		{[]string{"AppState", "UserConfig", "language"}, "english"},
+		{[]string{"AppState", "InstalledDepots", "621", "size"}, "11811160064"},
+		{[]string{"AppState", "LaunchOptions"}, "PROTON_LOG=1 \"%command%\"\n"},
+		{[]string{"AppState", "installdir"}, `C:\Games\Portal 2`},
+		{[]string{"AppState", "unquoted"}, "value"},
+		{[]string{"AppState", "Windows"}, "1"},
+		{[]string{"AppState", "missing"}, ""},
+		{[]string{"AppState", "UserConfig", "language", "deeper"}, ""},
+	}
+	for _, tt := range tests {
+		if got := root.GetString(tt.path...); got != tt.want {
+			t.Errorf("GetString(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	if got := root.GetUint("AppState", "SizeOnDisk"); got != 12884901888 {
+		t.Errorf("GetUint(SizeOnDisk) = %d, want 12884901888", got)
+	}
+	if got := root.GetInt("AppState", "InstalledDepots", "621", "manifest"); got != -7113862426442512384 {
+		t.Errorf("GetInt(manifest) = %d, want -7113862426442512384", got)
+	}
+	if got := root.GetUint("AppState", "name"); got != 0 {
+		t.Errorf("GetUint(name) = %d, want 0", got)
+	}
+}
+
+func TestParseTextErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"\"AppState\"\n{\n\t\"appid\" \"620\"\n", "line 4: unexpected end of input, missing '}'"},
+		{"\"appid\" \"620\"\n}\n", "line 2: unexpected '}'"},
+		{"{ \"appid\" \"620\" }", "line 1: unexpected '{', expected a key"},
+		{"\"AppState\"\n{\n\t\"name\" \"Portal 2\n", "line 4: unterminated string"},
+		{"\"AppState\" {\n\t\"appid\" }", "line 2: missing value for key \"appid\""},
+	}
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.input))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.input, err, tt.want)
+		}
+	}
+}
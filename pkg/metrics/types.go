@@ -53,22 +53,191 @@ type NetworkStats struct {
 
 // GamePerformanceStats represents game performance metrics
 type GamePerformanceStats struct {
-	FPS         float64   `json:"fps"`
-	FrameTime   float64   `json:"frame_time_ms"`
-	FrameTimeMin float64  `json:"frame_time_min_ms"`
-	FrameTimeMax float64  `json:"frame_time_max_ms"`
-	GameName    string    `json:"game_name"`
-	Timestamp   time.Time `json:"timestamp"`
+	FPS               float64   `json:"fps"`
+	FrameTime         float64   `json:"frame_time_ms"`
+	FrameTimeMin      float64   `json:"frame_time_min_ms"`
+	FrameTimeMax      float64   `json:"frame_time_max_ms"`
+	FrameTimeMean     float64   `json:"frame_time_mean_ms"`
+	FrameTimeMedian   float64   `json:"frame_time_median_ms"`
+	FrameTimeP95      float64   `json:"frame_time_p95_ms"`
+	FrameTimeP99      float64   `json:"frame_time_p99_ms"`
+	FrameTimeStdDev   float64   `json:"frame_time_stddev_ms"`
+	FPS1PercentLow    float64   `json:"fps_1pct_low"`
+	FPS01PercentLow   float64   `json:"fps_0_1pct_low"`
+	FrameSamples      int       `json:"frame_samples"`     // frame times in the statistics window
+	FrameTimeSource   string    `json:"frame_time_source"` // "mangohud" per rendered frame or "sampled" from the FPS per collection
+	GameName          string    `json:"game_name"`
+	AppID             string    `json:"app_id"`
+	PID               int       `json:"pid"`
+	Proton            bool      `json:"proton"`      // running through Proton/Wine
+	CompatTool        string    `json:"compat_tool"` // e.g. "Proton 9.0" or "GE-Proton9-7"
+	CompatToolVersion string    `json:"compat_tool_version"`
+	Timestamp         time.Time `json:"timestamp"`
 }
 
-// SteamStats represents Steam-specific metrics
+// Sources of the frame times in GamePerformanceStats
+const (
+	// FrameTimeSourceMangoHud is one frame time per rendered frame, read
+	// from the game's MangoHud log
+	FrameTimeSourceMangoHud = "mangohud"
+	// FrameTimeSourceSampled is one frame time per collection, derived from
+	// the FPS reading; percentiles and lows are then those of sampled FPS
+	FrameTimeSourceSampled = "sampled"
+)
+
+// SteamStats represents Steam-specific metrics. Only library folders and
+// apps being downloaded are listed; the state of every app is in
+// SteamInventory.
 type SteamStats struct {
-	DownloadSpeed    float64   `json:"download_speed_bytes_per_sec"`
-	UploadSpeed      float64   `json:"upload_speed_bytes_per_sec"`
-	ActiveDownloads  int       `json:"active_downloads"`
-	LibrarySize      uint64    `json:"library_size_bytes"`
-	InstalledGames   int       `json:"installed_games"`
-	DownloadProgress map[string]float64 `json:"download_progress"` // game_id -> progress percentage
-	Timestamp        time.Time `json:"timestamp"`
+	DownloadSpeed    float64             `json:"download_speed_bytes_per_sec"`
+	UploadSpeed      float64             `json:"upload_speed_bytes_per_sec"`
+	DiskWriteSpeed   float64             `json:"disk_write_speed_bytes_per_sec"`
+	ActiveDownloads  int                 `json:"active_downloads"`
+	UpdatingAppID    string              `json:"updating_app_id"`
+	UpdateQueue      []string            `json:"update_queue"` // app IDs waiting to update, in order
+	LibrarySize      uint64              `json:"library_size_bytes"`
+	InstalledGames   int                 `json:"installed_games"`
+	DownloadProgress map[string]float64  `json:"download_progress"` // game_id -> progress percentage
+	Libraries        []SteamLibraryStats `json:"libraries"`
+	OrphanedBytes    uint64              `json:"orphaned_bytes"`
+	Timestamp        time.Time           `json:"timestamp"`
+}
+
+// SteamInventory represents the per-app state of the Steam installation.
+// It grows with the library and rarely changes, so it is logged apart from
+// SteamStats and only when it changes.
+type SteamInventory struct {
+	Apps      []SteamAppStats   `json:"apps"`
+	Storage   []AppStorage      `json:"storage"`   // sorted by total footprint, largest first
+	Shortcuts []SteamShortcut   `json:"shortcuts"` // non-Steam games added to the library
+	Playtime  []AppPlaytime     `json:"playtime"`  // most recently played first
+	Orphaned  []OrphanedStorage `json:"orphaned"`  // sorted by size, largest first
+	Timestamp time.Time         `json:"timestamp"`
+
+	// Unreachable are configured libraries that couldn't be read, e.g. a
+	// removed microSD card. Orphaned is left empty while there are any,
+	// since their apps can't be told apart from uninstalled ones.
+	Unreachable []string `json:"unreachable_libraries,omitempty"`
+}
+
+// SteamAppStats represents the install state of a single Steam app as
+// recorded in its appmanifest
+type SteamAppStats struct {
+	AppID             string   `json:"app_id"`
+	Name              string   `json:"name"`
+	LibraryPath       string   `json:"library_path"`
+	InstallDir        string   `json:"install_dir"`
+	SizeOnDisk        uint64   `json:"size_on_disk_bytes"`
+	StateFlags        int64    `json:"state_flags"`
+	State             []string `json:"state"` // names of the set state flags
+	BytesDownloaded   uint64   `json:"bytes_downloaded"`
+	BytesToDownload   uint64   `json:"bytes_to_download"`
+	BytesStaged       uint64   `json:"bytes_staged"`
+	BytesToStage      uint64   `json:"bytes_to_stage"`
+	CompatTool        string   `json:"compat_tool,omitempty"` // tool selected for the app in Steam's settings
+	CompatToolVersion string   `json:"compat_tool_version,omitempty"`
+}
+
+// SensorStats represents thermal, clock, power and battery metrics
+type SensorStats struct {
+	CPUTemp        float64   `json:"cpu_temp_celsius"`
+	GPUTemp        float64   `json:"gpu_temp_celsius"`
+	CPUClock       float64   `json:"cpu_clock_mhz"` // average over all cores
+	GPUClock       float64   `json:"gpu_clock_mhz"`
+	APUPower       float64   `json:"apu_power_watts"`
+	HasBattery     bool      `json:"has_battery"`
+	BatteryPercent float64   `json:"battery_percent"`
+	BatteryPower   float64   `json:"battery_power_watts"`
+	BatteryStatus  string    `json:"battery_status"` // e.g. "Charging", "Discharging"
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// SessionSummary represents a single play session of a game
+type SessionSummary struct {
+	SessionID         string        `json:"session_id"`
+	AppID             string        `json:"app_id"`
+	GameName          string        `json:"game_name"`
+	Proton            bool          `json:"proton"`
+	CompatTool        string        `json:"compat_tool"`
+	CompatToolVersion string        `json:"compat_tool_version"`
+	StartTime         time.Time     `json:"start_time"`
+	EndTime           time.Time     `json:"end_time"`
+	Duration          time.Duration `json:"duration_ns"`
+	Samples           int           `json:"samples"`
+	AvgFPS            float64       `json:"avg_fps"`
+	FPS1PercentLow    float64       `json:"fps_1pct_low"`
+	PeakCPUTemp       float64       `json:"peak_cpu_temp_celsius"`
+	PeakGPUTemp       float64       `json:"peak_gpu_temp_celsius"`
+	AvgAPUPower       float64       `json:"avg_apu_power_watts"`
+	BatteryStart      float64       `json:"battery_start_percent"`
+	BatteryEnd        float64       `json:"battery_end_percent"`
+	BatteryDrain      float64       `json:"battery_drain_percent"` // percent points lost while discharging
+	DrainPerHour      float64       `json:"battery_drain_percent_per_hour"`
+}
+
+// SteamLibraryStats represents one Steam library folder, e.g. on the
+// internal SSD or a microSD card
+type SteamLibraryStats struct {
+	Path       string `json:"path"`
+	Device     string `json:"device"`
+	MountPoint string `json:"mount_point"`
+	GameCount  int    `json:"game_count"`
+	Size       uint64 `json:"size_bytes"` // sum of the apps' install sizes
+	Free       uint64 `json:"free_bytes"`
+	Total      uint64 `json:"total_bytes"`
+}
+
+// AppStorage represents the disk usage attributed to a single Steam app
+type AppStorage struct {
+	AppID            string `json:"app_id"`
+	Name             string `json:"name"`
+	LibraryPath      string `json:"library_path"`
+	InstallBytes     uint64 `json:"install_bytes"`      // steamapps/common/<installdir>
+	ShaderCacheBytes uint64 `json:"shader_cache_bytes"` // steamapps/shadercache/<appid>
+	CompatDataBytes  uint64 `json:"compat_data_bytes"`  // steamapps/compatdata/<appid>
+	WorkshopBytes    uint64 `json:"workshop_bytes"`     // steamapps/workshop/content/<appid>
+	TotalBytes       uint64 `json:"total_bytes"`
+}
+
+// OrphanedStorage is a Proton prefix or shader cache left behind by an app
+// that no longer has an appmanifest in any library
+type OrphanedStorage struct {
+	Kind        string `json:"kind"` // "compatdata" or "shadercache"
+	AppID       string `json:"app_id"`
+	LibraryPath string `json:"library_path"`
+	Path        string `json:"path"`
+	Bytes       uint64 `json:"bytes"`
+}
+
+// SteamShortcut is a non-Steam game, e.g. an emulator or another launcher,
+// added to a user's library
+type SteamShortcut struct {
+	AppID         string `json:"app_id"`
+	Name          string `json:"name"`
+	Exe           string `json:"exe"`
+	StartDir      string `json:"start_dir"`
+	LaunchOptions string `json:"launch_options"`
+	UserID        string `json:"user_id"` // userdata directory the shortcut belongs to
+}
+
+// AppPlaytime is the playtime Steam recorded for an app in a user's
+// localconfig.vdf
+type AppPlaytime struct {
+	AppID          string        `json:"app_id"`
+	UserID         string        `json:"user_id"`
+	Playtime       time.Duration `json:"playtime_ns"`
+	Playtime2Weeks time.Duration `json:"playtime_2weeks_ns"`
+	LastPlayed     time.Time     `json:"last_played"` // zero if unknown
+}
+
+// SystemInfo identifies the device and OS the metrics are collected on
+type SystemInfo struct {
+	Host      string `json:"host"`
+	Vendor    string `json:"vendor"`      // e.g. "Valve"
+	Model     string `json:"model"`       // DMI product name, e.g. "Jupiter" or "Galileo"
+	ModelName string `json:"model_name"`  // e.g. "Steam Deck OLED"
+	OSName    string `json:"os_name"`     // e.g. "SteamOS"
+	OSVersion string `json:"os_version"`  // e.g. "3.6.19"
+	OSBuildID string `json:"os_build_id"` // e.g. "20241016.1"
 }
 